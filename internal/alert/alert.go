@@ -0,0 +1,171 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+)
+
+const (
+	FIELD_TEMP                 string = "temp"
+	FIELD_FEELS_LIKE           string = "feels_like"
+	FIELD_HUMIDITY             string = "humidity"
+	FIELD_PRESSURE             string = "pressure"
+	FIELD_WIND_SPEED           string = "wind_speed"
+	FIELD_WIND_GUST            string = "wind_gust"
+	FIELD_CLOUDS               string = "clouds"
+	FIELD_PRECIPITATION_CHANCE string = "precipitation_chance"
+
+	OPERATOR_LT  string = "<"
+	OPERATOR_LTE string = "<="
+	OPERATOR_GT  string = ">"
+	OPERATOR_GTE string = ">="
+
+	// A rule fires when any of its conditions
+	// hold, or only when all of them do
+	MATCH_ANY string = "any"
+	MATCH_ALL string = "all"
+
+	MAX_CONDITIONS int = 10
+)
+
+// Fields a condition can check, read from the normalized
+// forecast, so temperatures are in Celsius and speeds
+// in meters per second whatever the provider.
+var fields = map[string]func(model.ForecastEntry) float64{
+	FIELD_TEMP:                 func(e model.ForecastEntry) float64 { return float64(e.Temp) },
+	FIELD_FEELS_LIKE:           func(e model.ForecastEntry) float64 { return float64(e.FeelsLike) },
+	FIELD_HUMIDITY:             func(e model.ForecastEntry) float64 { return float64(e.Humidity) },
+	FIELD_PRESSURE:             func(e model.ForecastEntry) float64 { return float64(e.Pressure) },
+	FIELD_WIND_SPEED:           func(e model.ForecastEntry) float64 { return float64(e.WindSpeed) },
+	FIELD_WIND_GUST:            func(e model.ForecastEntry) float64 { return float64(e.WindGust) },
+	FIELD_CLOUDS:               func(e model.ForecastEntry) float64 { return float64(e.Clouds) },
+	FIELD_PRECIPITATION_CHANCE: func(e model.ForecastEntry) float64 { return float64(e.PrecipitationChance) },
+}
+
+var (
+	ErrRuleNotFound = errors.New("alert rule not found")
+	ErrInvalidRule  = errors.New("invalid alert rule")
+)
+
+// A rule to notify a webhook when a city's weather
+// crosses a threshold, e.g. temp < 0 or wind_gust > 20.
+// The secret signs every delivery. Like api keys it is
+// only shown when the rule is created.
+// Rules belong to the api key that created them.
+type Rule struct {
+	Id         string      `json:"id"`
+	Owner      string      `json:"owner"`
+	City       string      `json:"city"`
+	Conditions []Condition `json:"conditions"`
+	Match      string      `json:"match"`
+	WebhookURL string      `json:"webhook_url"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	Secret     string      `json:"-"`
+}
+
+type Condition struct {
+	Field    string  `json:"field"`
+	Operator string  `json:"operator"`
+	Value    float64 `json:"value"`
+}
+
+// Check a rule is complete before it is saved,
+// filling in the default match
+func (r *Rule) Validate() error {
+	if err := r.validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRule, err)
+	}
+	return nil
+}
+
+func (r *Rule) validate() error {
+	r.City = strings.ToLower(strings.TrimSpace(r.City))
+	if r.City == "" {
+		return errors.New("city is required")
+	}
+	if len(r.Conditions) == 0 {
+		return errors.New("at least one condition is required")
+	}
+	if len(r.Conditions) > MAX_CONDITIONS {
+		return fmt.Errorf("a rule can have at most %d conditions", MAX_CONDITIONS)
+	}
+	for _, condition := range r.Conditions {
+		if err := condition.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if r.Match == "" {
+		r.Match = MATCH_ANY
+	}
+	if r.Match != MATCH_ANY && r.Match != MATCH_ALL {
+		return fmt.Errorf("match must be %q or %q", MATCH_ANY, MATCH_ALL)
+	}
+
+	webhook, err := url.Parse(r.WebhookURL)
+	if err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") || webhook.Host == "" {
+		return errors.New("webhook_url must be an absolute http or https url")
+	}
+	if err := checkWebhookHost(webhook.Hostname()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c Condition) Validate() error {
+	if _, ok := fields[c.Field]; !ok {
+		return fmt.Errorf("unknown condition field: %s", c.Field)
+	}
+	switch c.Operator {
+	case OPERATOR_LT, OPERATOR_LTE, OPERATOR_GT, OPERATOR_GTE:
+		return nil
+	default:
+		return fmt.Errorf("unknown condition operator: %s", c.Operator)
+	}
+}
+
+// Check the condition against one forecast entry
+func (c Condition) Matches(entry model.ForecastEntry) bool {
+	field, ok := fields[c.Field]
+	if !ok {
+		return false
+	}
+	value := field(entry)
+
+	switch c.Operator {
+	case OPERATOR_LT:
+		return value < c.Value
+	case OPERATOR_LTE:
+		return value <= c.Value
+	case OPERATOR_GT:
+		return value > c.Value
+	case OPERATOR_GTE:
+		return value >= c.Value
+	default:
+		return false
+	}
+}
+
+// Check the rule against one forecast entry.
+// Returns the conditions that held, and whether
+// that is enough for the rule to fire.
+func (r Rule) Evaluate(entry model.ForecastEntry) ([]Condition, bool) {
+	matched := []Condition{}
+	for _, condition := range r.Conditions {
+		if condition.Matches(entry) {
+			matched = append(matched, condition)
+		}
+	}
+
+	if r.Match == MATCH_ALL {
+		return matched, len(matched) == len(r.Conditions)
+	}
+	return matched, len(matched) > 0
+}
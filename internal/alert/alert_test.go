@@ -0,0 +1,150 @@
+package alert_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/alert"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockStore struct {
+	alert.StoreImplementor
+	mock.Mock
+}
+
+type MockDispatcher struct {
+	mock.Mock
+}
+
+func (ms *MockStore) ListByCity(ctx context.Context, city string) ([]alert.Rule, error) {
+	args := ms.Called(ctx, city)
+	return args.Get(0).([]alert.Rule), args.Error(1)
+}
+
+func (ms *MockStore) MarkFiring(ctx context.Context, id string) (bool, error) {
+	args := ms.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (ms *MockStore) ClearFiring(ctx context.Context, id string) error {
+	args := ms.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (ms *MockStore) PushDeadLetter(ctx context.Context, delivery alert.Delivery) error {
+	args := ms.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (md *MockDispatcher) Enqueue(ctx context.Context, delivery alert.Delivery) {
+	md.Called(ctx, delivery)
+}
+
+// Chicago at -3.15C with 25 m/s gusts
+func freezingWeather() model.CachedWeather {
+	return model.NewCachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "chicago",
+		},
+		List: []model.List{
+			{
+				Dt: 123,
+				Main: model.Main{
+					Temp: 270,
+				},
+				Wind: model.Wind{
+					Gust: 25,
+				},
+			},
+		},
+	}, "mock", time.Now(), time.Minute)
+}
+
+func TestValidateRule(t *testing.T) {
+	valid := alert.Rule{
+		City:       " Chicago ",
+		Conditions: []alert.Condition{{Field: alert.FIELD_TEMP, Operator: alert.OPERATOR_LT, Value: 0}},
+		WebhookURL: "https://example.com/hook",
+	}
+	assert.Nil(t, valid.Validate())
+	assert.EqualValues(t, "chicago", valid.City)
+	assert.EqualValues(t, alert.MATCH_ANY, valid.Match)
+
+	tests := []struct {
+		name string
+		edit func(*alert.Rule)
+	}{
+		{"no city", func(r *alert.Rule) { r.City = "" }},
+		{"no conditions", func(r *alert.Rule) { r.Conditions = nil }},
+		{"unknown field", func(r *alert.Rule) { r.Conditions = []alert.Condition{{Field: "snow", Operator: "<"}} }},
+		{"unknown operator", func(r *alert.Rule) { r.Conditions = []alert.Condition{{Field: "temp", Operator: "=="}} }},
+		{"unknown match", func(r *alert.Rule) { r.Match = "most" }},
+		{"relative webhook", func(r *alert.Rule) { r.WebhookURL = "/hook" }},
+		{"non http webhook", func(r *alert.Rule) { r.WebhookURL = "ftp://example.com/hook" }},
+		{"localhost webhook", func(r *alert.Rule) { r.WebhookURL = "http://localhost:8080/hook" }},
+		{"loopback webhook", func(r *alert.Rule) { r.WebhookURL = "http://127.0.0.1/hook" }},
+		{"private webhook", func(r *alert.Rule) { r.WebhookURL = "http://10.0.0.5/hook" }},
+		{"metadata webhook", func(r *alert.Rule) { r.WebhookURL = "http://169.254.169.254/latest/meta-data" }},
+		{"ipv6 loopback webhook", func(r *alert.Rule) { r.WebhookURL = "http://[::1]/hook" }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := valid
+			test.edit(&rule)
+			assert.ErrorIs(t, rule.Validate(), alert.ErrInvalidRule)
+		})
+	}
+}
+
+func TestRuleEvaluateAnyAndAll(t *testing.T) {
+	entry := model.ForecastEntry{Temp: -3, WindGust: 10}
+	conditions := []alert.Condition{
+		{Field: alert.FIELD_TEMP, Operator: alert.OPERATOR_LT, Value: 0},
+		{Field: alert.FIELD_WIND_GUST, Operator: alert.OPERATOR_GT, Value: 20},
+	}
+
+	matched, fired := alert.Rule{Conditions: conditions, Match: alert.MATCH_ANY}.Evaluate(entry)
+	assert.True(t, fired)
+	assert.EqualValues(t, conditions[:1], matched)
+
+	_, fired = alert.Rule{Conditions: conditions, Match: alert.MATCH_ALL}.Evaluate(entry)
+	assert.False(t, fired)
+}
+
+func TestEvaluateNotifiesOnlyWhenRuleStartsFiring(t *testing.T) {
+	ctx := context.Background()
+	store := &MockStore{}
+	dispatcher := &MockDispatcher{}
+	evaluator := alert.NewEvaluator(store, dispatcher, 1)
+	freezing := alert.Rule{
+		Id:         "freezing",
+		City:       "chicago",
+		Conditions: []alert.Condition{{Field: alert.FIELD_TEMP, Operator: alert.OPERATOR_LT, Value: 0}},
+		Match:      alert.MATCH_ANY,
+		WebhookURL: "https://example.com/hook",
+		Secret:     "whsec_test",
+	}
+	already := freezing
+	already.Id = "already"
+	hot := freezing
+	hot.Id = "hot"
+	hot.Conditions = []alert.Condition{{Field: alert.FIELD_TEMP, Operator: alert.OPERATOR_GT, Value: 30}}
+
+	store.On("ListByCity", ctx, "chicago").Return([]alert.Rule{freezing, already, hot}, nil).Once()
+	store.On("MarkFiring", ctx, "freezing").Return(true, nil).Once()
+	store.On("MarkFiring", ctx, "already").Return(false, nil).Once()
+	store.On("ClearFiring", ctx, "hot").Return(nil).Once()
+	dispatcher.On("Enqueue", ctx, mock.MatchedBy(func(delivery alert.Delivery) bool {
+		return delivery.RuleId == "freezing" && delivery.Secret == "whsec_test" && delivery.WebhookURL == freezing.WebhookURL
+	})).Once()
+
+	err := evaluator.Evaluate(ctx, "Chicago", freezingWeather())
+
+	assert.Nil(t, err)
+	store.AssertExpectations(t)
+	dispatcher.AssertExpectations(t)
+}
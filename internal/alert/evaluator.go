@@ -0,0 +1,154 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/model"
+)
+
+const (
+	EVENT_ALERT_TRIGGERED string = "alert.triggered"
+)
+
+// Body of a webhook delivery
+type Event struct {
+	Id          string              `json:"id"`
+	Type        string              `json:"type"`
+	Rule        Rule                `json:"rule"`
+	City        string              `json:"city"`
+	Matched     []Condition         `json:"matched"`
+	Forecast    model.ForecastEntry `json:"forecast"`
+	FetchedAt   time.Time           `json:"fetched_at"`
+	TriggeredAt time.Time           `json:"triggered_at"`
+}
+
+type weatherUpdate struct {
+	city    string
+	weather model.CachedWeather
+}
+
+// Checks a city's alert rules each time its weather is
+// fetched. Rules are checked against the nearest forecast
+// entry, and only notify when their conditions start to
+// hold, not on every fetch while they still do.
+type Evaluator struct {
+	Store      StoreImplementor
+	Dispatcher DispatcherImplementor
+	updates    chan weatherUpdate
+}
+
+func NewEvaluator(store StoreImplementor, dispatcher DispatcherImplementor, queueSize int) *Evaluator {
+	return &Evaluator{
+		Store:      store,
+		Dispatcher: dispatcher,
+		updates:    make(chan weatherUpdate, queueSize),
+	}
+}
+
+// Queue weather to be checked, without blocking
+// the request that fetched it. If we are too far
+// behind the update is dropped.
+func (e *Evaluator) ObserveWeather(ctx context.Context, city string, weather model.CachedWeather) {
+	select {
+	case e.updates <- weatherUpdate{city: city, weather: weather}:
+	default:
+		logger.FromContext(ctx).WarnContext(ctx, "Alert evaluation queue is full, dropping weather update", "city", city)
+	}
+}
+
+// Start checking queued weather. This blocks until the
+// context is cancelled, so it should be run on its own go-routine.
+func (e *Evaluator) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-e.updates:
+			if err := e.Evaluate(ctx, update.city, update.weather); err != nil {
+				logger.FromContext(ctx).ErrorContext(ctx, "Failed to evaluate alert rules", "city", update.city, "error", err)
+			}
+		}
+	}
+}
+
+// Check every rule for a city against its weather,
+// queueing a delivery for each rule that starts firing
+func (e *Evaluator) Evaluate(ctx context.Context, city string, weather model.CachedWeather) error {
+	city = strings.ToLower(city)
+	rules, err := e.Store.ListByCity(ctx, city)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	forecast := weather.Weather.Normalize(weather.Provider)
+	if len(forecast.Entries) == 0 {
+		return nil
+	}
+	entry := forecast.Entries[0]
+
+	for _, rule := range rules {
+		matched, fired := rule.Evaluate(entry)
+		if !fired {
+			if err := e.Store.ClearFiring(ctx, rule.Id); err != nil {
+				return err
+			}
+			continue
+		}
+
+		first, err := e.Store.MarkFiring(ctx, rule.Id)
+		if err != nil {
+			return err
+		}
+		if !first {
+			continue
+		}
+
+		delivery, err := newDelivery(rule, city, matched, entry, weather.FetchedAt)
+		if err != nil {
+			return err
+		}
+		e.Dispatcher.Enqueue(ctx, delivery)
+	}
+
+	return nil
+}
+
+func newDelivery(rule Rule, city string, matched []Condition, entry model.ForecastEntry, fetchedAt time.Time) (Delivery, error) {
+	id, err := randomHex(12)
+	if err != nil {
+		return Delivery{}, err
+	}
+	now := time.Now().UTC()
+
+	payload, err := json.Marshal(Event{
+		Id:          id,
+		Type:        EVENT_ALERT_TRIGGERED,
+		Rule:        rule,
+		City:        city,
+		Matched:     matched,
+		Forecast:    entry,
+		FetchedAt:   fetchedAt,
+		TriggeredAt: now,
+	})
+	if err != nil {
+		return Delivery{}, fmt.Errorf("failed to encode alert event: %w", err)
+	}
+
+	return Delivery{
+		Id:         id,
+		RuleId:     rule.Id,
+		Owner:      rule.Owner,
+		WebhookURL: rule.WebhookURL,
+		Payload:    payload,
+		CreatedAt:  now,
+		Secret:     rule.Secret,
+	}, nil
+}
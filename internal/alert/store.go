@@ -0,0 +1,259 @@
+package alert
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ALERT_INDEX      string = "alerts"
+	ALERT_RECORD     string = "alert:record:"
+	ALERT_CITY_INDEX string = "alert:city:"
+	ALERT_FIRING     string = "alert:firing:"
+	DEAD_LETTER_KEY  string = "alerts:deadletter"
+	SECRET_PREFIX    string = "whsec_"
+)
+
+type StoreImplementor interface {
+	Create(context.Context, Rule) (Rule, error)
+	Get(context.Context, string) (Rule, error)
+	List(context.Context) ([]Rule, error)
+	ListByCity(context.Context, string) ([]Rule, error)
+	Update(context.Context, Rule) (Rule, error)
+	Delete(context.Context, string) error
+	MarkFiring(context.Context, string) (bool, error)
+	ClearFiring(context.Context, string) error
+	PushDeadLetter(context.Context, Delivery) error
+	DeadLetters(context.Context, int) ([]Delivery, error)
+}
+
+// Stores alert rules in redis. Each rule has a record by id,
+// and is in a set of every id and a set for its city, so the
+// evaluator only loads the rules for the city it is checking.
+// Failed deliveries are kept in a capped dead-letter list.
+type RedisStore struct {
+	Client        *redis.Client
+	DeadLetterMax int64
+}
+
+// Stored form of a rule, keeping the secret
+// that is left out of the json we return
+type storedRule struct {
+	Rule
+	Secret string `json:"secret"`
+}
+
+func NewRedisStore(rds *redis.Client, deadLetterMax int64) *RedisStore {
+	return &RedisStore{
+		Client:        rds,
+		DeadLetterMax: deadLetterMax,
+	}
+}
+
+// Validate and save a new rule with a generated id and
+// signing secret. The returned rule has the secret set.
+func (rs *RedisStore) Create(ctx context.Context, rule Rule) (Rule, error) {
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return Rule{}, err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule.Id = id
+	rule.Secret = SECRET_PREFIX + secret
+	rule.CreatedAt = time.Now().UTC()
+	rule.UpdatedAt = rule.CreatedAt
+
+	record, err := json.Marshal(storedRule{Rule: rule, Secret: rule.Secret})
+	if err != nil {
+		return Rule{}, err
+	}
+
+	pipe := rs.Client.TxPipeline()
+	pipe.Set(ctx, ALERT_RECORD+id, record, 0)
+	pipe.SAdd(ctx, ALERT_INDEX, id)
+	pipe.SAdd(ctx, ALERT_CITY_INDEX+rule.City, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Rule{}, fmt.Errorf("failed to save alert rule to redis: %w", err)
+	}
+
+	return rule, nil
+}
+
+// Get a rule by id
+func (rs *RedisStore) Get(ctx context.Context, id string) (Rule, error) {
+	record, err := rs.Client.Get(ctx, ALERT_RECORD+id).Bytes()
+	if err == redis.Nil {
+		return Rule{}, ErrRuleNotFound
+	} else if err != nil {
+		return Rule{}, fmt.Errorf("failed to find alert rule in redis: %w", err)
+	}
+
+	stored := storedRule{}
+	if err := json.Unmarshal(record, &stored); err != nil {
+		return Rule{}, fmt.Errorf("failed to decode alert rule: %w", err)
+	}
+	stored.Rule.Secret = stored.Secret
+
+	return stored.Rule, nil
+}
+
+// List every rule
+func (rs *RedisStore) List(ctx context.Context) ([]Rule, error) {
+	return rs.listIndex(ctx, ALERT_INDEX)
+}
+
+// List the rules watching a city
+func (rs *RedisStore) ListByCity(ctx context.Context, city string) ([]Rule, error) {
+	return rs.listIndex(ctx, ALERT_CITY_INDEX+city)
+}
+
+// Replace a rule's city, conditions and webhook.
+// Its id, secret and creation time are kept, and it
+// starts over as not firing since the conditions changed.
+func (rs *RedisStore) Update(ctx context.Context, rule Rule) (Rule, error) {
+	existing, err := rs.Get(ctx, rule.Id)
+	if err != nil {
+		return Rule{}, err
+	}
+	if err := rule.Validate(); err != nil {
+		return Rule{}, err
+	}
+	rule.Owner = existing.Owner
+	rule.Secret = existing.Secret
+	rule.CreatedAt = existing.CreatedAt
+	rule.UpdatedAt = time.Now().UTC()
+
+	record, err := json.Marshal(storedRule{Rule: rule, Secret: rule.Secret})
+	if err != nil {
+		return Rule{}, err
+	}
+
+	pipe := rs.Client.TxPipeline()
+	pipe.Set(ctx, ALERT_RECORD+rule.Id, record, 0)
+	pipe.SRem(ctx, ALERT_CITY_INDEX+existing.City, rule.Id)
+	pipe.SAdd(ctx, ALERT_CITY_INDEX+rule.City, rule.Id)
+	pipe.Del(ctx, ALERT_FIRING+rule.Id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Rule{}, fmt.Errorf("failed to update alert rule in redis: %w", err)
+	}
+
+	return rule, nil
+}
+
+// Delete a rule by id
+func (rs *RedisStore) Delete(ctx context.Context, id string) error {
+	rule, err := rs.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	pipe := rs.Client.TxPipeline()
+	pipe.Del(ctx, ALERT_RECORD+id, ALERT_FIRING+id)
+	pipe.SRem(ctx, ALERT_INDEX, id)
+	pipe.SRem(ctx, ALERT_CITY_INDEX+rule.City, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to delete alert rule from redis: %w", err)
+	}
+
+	return nil
+}
+
+// Mark a rule as firing. Returns true only if it wasn't
+// already, so across every replica a rule notifies once
+// each time its conditions start to hold.
+func (rs *RedisStore) MarkFiring(ctx context.Context, id string) (bool, error) {
+	ok, err := rs.Client.SetNX(ctx, ALERT_FIRING+id, time.Now().UTC().Format(time.RFC3339), 0).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark alert rule as firing in redis: %w", err)
+	}
+	return ok, nil
+}
+
+// Mark a rule as no longer firing
+func (rs *RedisStore) ClearFiring(ctx context.Context, id string) error {
+	if err := rs.Client.Del(ctx, ALERT_FIRING+id).Err(); err != nil {
+		return fmt.Errorf("failed to clear alert rule firing in redis: %w", err)
+	}
+	return nil
+}
+
+// Keep a delivery that ran out of attempts. Only the
+// newest DeadLetterMax are kept.
+func (rs *RedisStore) PushDeadLetter(ctx context.Context, delivery Delivery) error {
+	record, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	pipe := rs.Client.TxPipeline()
+	pipe.LPush(ctx, DEAD_LETTER_KEY, record)
+	if rs.DeadLetterMax > 0 {
+		pipe.LTrim(ctx, DEAD_LETTER_KEY, 0, rs.DeadLetterMax-1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save dead letter to redis: %w", err)
+	}
+
+	return nil
+}
+
+// Get the n newest failed deliveries, newest first
+func (rs *RedisStore) DeadLetters(ctx context.Context, n int) ([]Delivery, error) {
+	deliveries := []Delivery{}
+	if n <= 0 {
+		return deliveries, nil
+	}
+
+	records, err := rs.Client.LRange(ctx, DEAD_LETTER_KEY, 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letters from redis: %w", err)
+	}
+	for _, record := range records {
+		delivery := Delivery{}
+		if err := json.Unmarshal([]byte(record), &delivery); err != nil {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+func (rs *RedisStore) listIndex(ctx context.Context, index string) ([]Rule, error) {
+	ids, err := rs.Client.SMembers(ctx, index).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules from redis: %w", err)
+	}
+
+	rules := []Rule{}
+	for _, id := range ids {
+		rule, err := rs.Get(ctx, id)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate alert rule id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
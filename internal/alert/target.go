@@ -0,0 +1,64 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrPrivateTarget = errors.New("webhooks can't be sent to private or local addresses")
+
+// Whether webhooks must not reach an address: loopback, private,
+// link-local (which includes cloud metadata at 169.254.169.254),
+// unspecified or multicast
+func isPrivateAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+// Turn away webhook hosts we can tell are local without
+// resolving them. Names are checked again when we dial.
+func checkWebhookHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateTarget
+	}
+	if ip := net.ParseIP(host); ip != nil && isPrivateAddress(ip) {
+		return ErrPrivateTarget
+	}
+	return nil
+}
+
+// Check the address a webhook is about to connect to. This runs
+// after DNS resolution, so a name that resolves (or later
+// rebinds) to a private address is refused too.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid webhook address %q: %w", address, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isPrivateAddress(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, host)
+	}
+	return nil
+}
+
+// Transport for webhooks that can only dial public addresses.
+// Proxies are not used, since they would dial for us.
+func newWebhookTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   checkDialAddress,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,217 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/metrics"
+	"github.com/bengimbel/go_redis_api/pkg/webhookSignature"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	EVENT_HEADER      string        = "X-Weather-Event"
+	DELIVERY_HEADER   string        = "X-Weather-Delivery"
+	MAX_BACKOFF       time.Duration = time.Minute
+	DEAD_LETTER_WRITE time.Duration = 5 * time.Second
+)
+
+// A webhook call for a rule that fired. The secret is
+// only needed to sign it, so it isn't kept in dead letters.
+type Delivery struct {
+	Id         string          `json:"id"`
+	RuleId     string          `json:"rule_id"`
+	Owner      string          `json:"owner,omitempty"`
+	WebhookURL string          `json:"webhook_url"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	LastError  string          `json:"last_error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FailedAt   *time.Time      `json:"failed_at,omitempty"`
+	Secret     string          `json:"-"`
+}
+
+type DeadLetterImplementor interface {
+	PushDeadLetter(context.Context, Delivery) error
+}
+
+type DispatcherImplementor interface {
+	Enqueue(context.Context, Delivery)
+}
+
+// Delivers webhooks from a queue on a few workers. Each delivery
+// is retried with exponential backoff, and once it runs out of
+// attempts (or the receiver rejects it) it goes on the dead-letter
+// list. Delivery is at least once, so receivers should ignore
+// a delivery id they have already seen.
+type Dispatcher struct {
+	Client      *http.Client
+	Store       DeadLetterImplementor
+	Workers     int
+	MaxAttempts int
+	Backoff     time.Duration
+	queue       chan Delivery
+}
+
+// Error for a response that retrying won't fix
+type permanentError struct {
+	err error
+}
+
+func (pe *permanentError) Error() string {
+	return pe.err.Error()
+}
+
+func NewDispatcher(store DeadLetterImplementor, cfg config.AlertsConfig) *Dispatcher {
+	return &Dispatcher{
+		Client: &http.Client{
+			Timeout:   cfg.WebhookTimeout,
+			Transport: otelhttp.NewTransport(newWebhookTransport()),
+		},
+		Store:       store,
+		Workers:     cfg.Workers,
+		MaxAttempts: cfg.MaxAttempts,
+		Backoff:     cfg.RetryBackoff,
+		queue:       make(chan Delivery, cfg.QueueSize),
+	}
+}
+
+// Queue a delivery without blocking. If the queue is
+// full the delivery goes straight to the dead-letter list.
+func (d *Dispatcher) Enqueue(ctx context.Context, delivery Delivery) {
+	select {
+	case d.queue <- delivery:
+	default:
+		d.deadLetter(ctx, delivery, errors.New("webhook queue is full"))
+	}
+}
+
+// Start the workers. This blocks until the context is
+// cancelled, so it should be run on its own go-routine.
+// Deliveries still queued then are dead-lettered.
+func (d *Dispatcher) Start(ctx context.Context) {
+	var workers sync.WaitGroup
+	for i := 0; i < d.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case delivery := <-d.queue:
+					d.Deliver(ctx, delivery)
+				}
+			}
+		}()
+	}
+	workers.Wait()
+
+	for {
+		select {
+		case delivery := <-d.queue:
+			d.deadLetter(ctx, delivery, errors.New("shut down before delivering"))
+		default:
+			return
+		}
+	}
+}
+
+// Send a delivery, retrying until it succeeds, the receiver
+// rejects it, or it runs out of attempts
+func (d *Dispatcher) Deliver(ctx context.Context, delivery Delivery) {
+	for {
+		delivery.Attempts++
+		err := d.send(ctx, delivery)
+		if err == nil {
+			metrics.AlertDeliveries.WithLabelValues(metrics.DELIVERY_DELIVERED).Inc()
+			return
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || delivery.Attempts >= d.MaxAttempts {
+			d.deadLetter(ctx, delivery, err)
+			return
+		}
+
+		metrics.AlertDeliveries.WithLabelValues(metrics.DELIVERY_RETRIED).Inc()
+		logger.FromContext(ctx).WarnContext(ctx, "Webhook delivery failed, retrying", "delivery", delivery.Id, "attempt", delivery.Attempts, "error", err)
+		timer := time.NewTimer(d.backoff(delivery.Attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			d.deadLetter(ctx, delivery, fmt.Errorf("shut down before retrying: %w", err))
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Make one attempt. Any 2xx is a success. Other 4xx
+// responses, apart from 408 and 429, won't be retried.
+func (d *Dispatcher) send(ctx context.Context, delivery Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.WebhookURL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return &permanentError{err: fmt.Errorf("invalid webhook request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EVENT_HEADER, EVENT_ALERT_TRIGGERED)
+	req.Header.Set(DELIVERY_HEADER, delivery.Id)
+	req.Header.Set(webhookSignature.HEADER, webhookSignature.Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	res, err := d.Client.Do(req)
+	if errors.Is(err, ErrPrivateTarget) {
+		return &permanentError{err: fmt.Errorf("failed to call webhook: %w", err)}
+	} else if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded with %d", res.StatusCode)
+	if res.StatusCode >= 400 && res.StatusCode < 500 &&
+		res.StatusCode != http.StatusRequestTimeout && res.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{err: err}
+	}
+
+	return err
+}
+
+// Double the wait after every attempt, plus
+// up to half again so receivers aren't hit in bursts
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	backoff := d.Backoff << (attempt - 1)
+	if backoff <= 0 || backoff > MAX_BACKOFF {
+		backoff = MAX_BACKOFF
+	}
+	return backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+}
+
+func (d *Dispatcher) deadLetter(ctx context.Context, delivery Delivery, err error) {
+	metrics.AlertDeliveries.WithLabelValues(metrics.DELIVERY_DEAD_LETTERED).Inc()
+	logger.FromContext(ctx).ErrorContext(ctx, "Webhook delivery failed", "delivery", delivery.Id, "rule", delivery.RuleId, "attempts", delivery.Attempts, "error", err)
+
+	failedAt := time.Now().UTC()
+	delivery.LastError = err.Error()
+	delivery.FailedAt = &failedAt
+
+	// Save it even if we are shutting down
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DEAD_LETTER_WRITE)
+	defer cancel()
+	if err := d.Store.PushDeadLetter(ctx, delivery); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "Failed to save dead letter", "delivery", delivery.Id, "error", err)
+	}
+}
@@ -0,0 +1,118 @@
+package alert_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/alert"
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/pkg/webhookSignature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Test servers listen on loopback, which the
+// dispatcher's own client refuses to dial
+func newTestDispatcher(store *MockStore) *alert.Dispatcher {
+	dispatcher := newDispatcher(store)
+	dispatcher.Client = &http.Client{Timeout: time.Second}
+	return dispatcher
+}
+
+func newDispatcher(store *MockStore) *alert.Dispatcher {
+	return alert.NewDispatcher(store, config.AlertsConfig{
+		Workers:        1,
+		MaxAttempts:    3,
+		RetryBackoff:   time.Millisecond,
+		WebhookTimeout: time.Second,
+		QueueSize:      1,
+	})
+}
+
+func testDelivery(url string) alert.Delivery {
+	return alert.Delivery{
+		Id:         "delivery",
+		RuleId:     "rule",
+		WebhookURL: url,
+		Payload:    []byte(`{"type":"alert.triggered"}`),
+		CreatedAt:  time.Now(),
+		Secret:     "whsec_test",
+	}
+}
+
+func TestDeliverRetriesAndSigns(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Nil(t, webhookSignature.Verify("whsec_test", r.Header.Get(webhookSignature.HEADER), body, time.Minute))
+		assert.EqualValues(t, "delivery", r.Header.Get(alert.DELIVERY_HEADER))
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	store := &MockStore{}
+
+	newTestDispatcher(store).Deliver(context.Background(), testDelivery(server.URL))
+
+	assert.EqualValues(t, 3, calls.Load())
+	store.AssertNotCalled(t, "PushDeadLetter", mock.Anything, mock.Anything)
+}
+
+func TestDeliverDeadLettersWhenAttemptsRunOut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	store := &MockStore{}
+	store.On("PushDeadLetter", mock.Anything, mock.MatchedBy(func(delivery alert.Delivery) bool {
+		return delivery.Attempts == 3 && delivery.LastError == "webhook responded with 500" && delivery.FailedAt != nil
+	})).Return(nil).Once()
+
+	newTestDispatcher(store).Deliver(context.Background(), testDelivery(server.URL))
+
+	store.AssertExpectations(t)
+}
+
+func TestDeliverDoesNotRetryRejectedWebhook(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+	store := &MockStore{}
+	store.On("PushDeadLetter", mock.Anything, mock.MatchedBy(func(delivery alert.Delivery) bool {
+		return delivery.Attempts == 1
+	})).Return(nil).Once()
+
+	newTestDispatcher(store).Deliver(context.Background(), testDelivery(server.URL))
+
+	assert.EqualValues(t, 1, calls.Load())
+	store.AssertExpectations(t)
+}
+
+func TestDeliverRefusesPrivateAddresses(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+	store := &MockStore{}
+	store.On("PushDeadLetter", mock.Anything, mock.MatchedBy(func(delivery alert.Delivery) bool {
+		return delivery.Attempts == 1 && strings.Contains(delivery.LastError, alert.ErrPrivateTarget.Error())
+	})).Return(nil).Once()
+
+	newDispatcher(store).Deliver(context.Background(), testDelivery(server.URL))
+
+	assert.EqualValues(t, 0, calls.Load())
+	store.AssertExpectations(t)
+}
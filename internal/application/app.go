@@ -2,78 +2,347 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/bengimbel/go_redis_api/internal/alert"
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/internal/certs"
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/graph"
+	"github.com/bengimbel/go_redis_api/internal/health"
+	"github.com/bengimbel/go_redis_api/internal/lifecycle"
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/metrics"
+	"github.com/bengimbel/go_redis_api/internal/middleware"
+	"github.com/bengimbel/go_redis_api/internal/provider"
+	"github.com/bengimbel/go_redis_api/internal/refresher"
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/bengimbel/go_redis_api/internal/rpc"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/internal/stream"
+	"github.com/bengimbel/go_redis_api/internal/tracing"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
-)
-
-const (
-	REDIS_ADDR  string = "redis:6379"
-	SERVER_ADDR string = ":8080"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type App struct {
-	Router http.Handler
-	Rdb    *redis.Client
+	Router        http.Handler
+	Rdb           *redis.Client
+	Config        *config.Config
+	Logger        *slog.Logger
+	Repo          *repository.RedisRepo
+	RedisStatus   *repository.RedisStatus
+	Service       *service.WeatherService
+	Refresher     *refresher.Refresher
+	RateLimiter   *middleware.RateLimiter
+	KeyStore      *auth.RedisKeyStore
+	Authenticator *middleware.Authenticator
+	Health        *health.Health
+	GraphQL       *graph.GraphQL
+	GRPC          *rpc.Server
+	Hub           *stream.Hub
+	AlertStore    *alert.RedisStore
+	Alerts        *alert.Evaluator
+	Webhooks      *alert.Dispatcher
+	History       *repository.RedisHistory
+	Geo           *service.GeoService
 }
 
-// Create a new App instance
-func NewApp() *App {
+// Create a new App instance. Everything the app
+// creates logs through log rather than slog's default.
+func NewApp(cfg *config.Config, log *slog.Logger) (*App, error) {
 	app := &App{
 		Rdb: redis.NewClient(&redis.Options{
-			Addr: REDIS_ADDR,
+			Addr: cfg.RedisAddr,
 		}),
+		Config: cfg,
+		Logger: log,
+	}
+	// One weather service is shared by the handlers and
+	// background jobs so they use the same local cache.
+	app.RedisStatus = repository.NewRedisStatus(app.Rdb, cfg.RedisRetryInterval, log)
+	app.Repo = repository.NewRedisRepo(app.Rdb, app.RedisStatus, cfg.Refresher.PopularCitiesMax, cfg.Refresher.PopularCitiesWindow, cfg.Upstream.StaleTTL)
+	if cfg.History.Enabled {
+		app.History = repository.NewRedisHistory(app.Rdb, cfg.History.Retention, int64(cfg.History.MaxSnapshots))
+		app.Repo.History = app.History
+	}
+	weatherService, err := service.NewWeatherService(app.Repo, app.Rdb, cfg, log)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create weather service: %w", err)
+	}
+	app.Service = weatherService
+	app.Geo = app.newGeo()
+	app.GraphQL, err = graph.NewGraphQL(app.Service)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create graphql schema: %w", err)
+	}
+	// Alert rules are checked whenever the
+	// service fetches a city's weather
+	if cfg.Alerts.Enabled {
+		app.AlertStore = alert.NewRedisStore(app.Rdb, int64(cfg.Alerts.DeadLetterMax))
+		app.Webhooks = alert.NewDispatcher(app.AlertStore, cfg.Alerts)
+		app.Alerts = alert.NewEvaluator(app.AlertStore, app.Webhooks, cfg.Alerts.QueueSize)
+		app.Service.Observer = app.Alerts
 	}
+	if cfg.Stream.Enabled {
+		app.Hub = stream.NewHub(app.Rdb, cfg.Stream.MaxClients)
+	}
+	app.Refresher = refresher.NewRefresher(app.Rdb, app.Service, cfg.Refresher)
+	app.RateLimiter = middleware.NewRateLimiter(app.Rdb, cfg.RateLimit.Limit, cfg.RateLimit.Window, cfg.RateLimit.TrustedProxies, cfg.Auth.Tiers, log)
+	app.KeyStore = auth.NewRedisKeyStore(app.Rdb)
+	app.Authenticator = middleware.NewAuthenticator(app.KeyStore, cfg.Auth)
+	app.Health = app.newHealth()
+	// Create a span for every redis command
+	if err := redisotel.InstrumentTracing(app.Rdb); err != nil {
+		return nil, fmt.Errorf("Failed to instrument redis tracing: %w", err)
+	}
+
+	metrics.RegisterCacheStats(app.Repo.Cache)
+	metrics.RegisterRedisPoolStats(app.Rdb)
 	app.LoadApiRoutes()
 
-	return app
+	return app, nil
+}
+
+// Geocoding uses the first provider that supports it,
+// or is nil if none do
+func (a *App) newGeo() *service.GeoService {
+	for _, p := range []provider.WeatherProvider{a.Service.Provider, a.Service.Fallback} {
+		if geocoder, ok := p.(provider.Geocoder); ok {
+			return service.NewGeoService(a.Repo, geocoder)
+		}
+	}
+	return nil
 }
 
-// Start our App
+// Readiness needs at least one weather provider we can reach
+// to fill cache misses. We can serve without redis, so
+// losing it only marks us as degraded.
+func (a *App) newHealth() *health.Health {
+	h := health.NewHealth(a.Config.Health.Timeout)
+	h.AddNonCriticalCheck("redis", a.Config.Health.CacheTTL, a.RedisStatus.Check)
+
+	var upstreamChecks []health.CheckFunc
+	for _, p := range []provider.WeatherProvider{a.Service.Provider, a.Service.Fallback} {
+		if pinger, ok := p.(provider.Pinger); ok {
+			upstreamChecks = append(upstreamChecks, pinger.Ping)
+		}
+	}
+	if len(upstreamChecks) > 0 {
+		h.AddCheck("upstream", a.Config.Health.UpstreamCacheTTL, health.Any(upstreamChecks...))
+	}
+
+	return h
+}
+
+// Start our App. It serves until the context is cancelled (on
+// SIGINT or SIGTERM), then shuts down in order: fail readiness and
+// drain, stop taking requests, finish async cache writes, stop
+// background jobs, flush traces, and finally close redis.
 func (a *App) Start(ctx context.Context) error {
 	server := &http.Server{
-		Addr:    SERVER_ADDR,
-		Handler: a.Router,
+		Addr:              a.Config.ServerAddr,
+		Handler:           a.Router,
+		ReadTimeout:       a.Config.Server.ReadTimeout,
+		ReadHeaderTimeout: a.Config.Server.ReadHeaderTimeout,
+		WriteTimeout:      a.Config.Server.WriteTimeout,
+		IdleTimeout:       a.Config.Server.IdleTimeout,
+		MaxHeaderBytes:    a.Config.Server.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(a.Logger.Handler(), slog.LevelWarn),
 	}
+	// Background jobs log through the app's logger
+	ctx = logger.WithLogger(ctx, a.Logger)
 
-	// Ping Redis to make sure we are connected
-	err := a.Rdb.Ping(ctx).Err()
+	// Set up tracing. Spans are flushed on shutdown.
+	shutdownTracing, err := tracing.Setup(ctx, a.Config.Tracing)
 	if err != nil {
-		return fmt.Errorf("Server failed to connect to redis: %w", err)
+		return fmt.Errorf("Server failed to set up tracing: %w", err)
 	}
 
-	// Gracefully shut down redis.
-	defer func() {
-		if err := a.Rdb.Close(); err != nil {
-			log.Println("Failed to close redis", err)
+	// Background jobs get their own context, so on shutdown
+	// they keep running until the requests using them are done.
+	jobsCtx, stopJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopJobs()
+	var jobs sync.WaitGroup
+	runJob := func(job func(context.Context)) {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job(jobsCtx)
+		}()
+	}
+	// If we fail to start, stop the jobs already running before returning
+	stopStartedJobs := func(err error) error {
+		stopJobs()
+		jobs.Wait()
+		return err
+	}
+
+	// Ping Redis to see if we are connected. If not we start
+	// in degraded mode, serving from upstream and the local
+	// cache, and keep retrying in the background.
+	if err := a.RedisStatus.Check(ctx); err != nil {
+		a.Logger.Warn("Failed to connect to redis, starting in degraded mode", "error", err)
+	}
+	runJob(a.RedisStatus.Start)
+
+	// Start the popular city refresher. We are warm once the
+	// first refresh has filled the cache with the popular cities.
+	if a.Config.Refresher.Enabled {
+		a.Refresher.OnWarm = a.Health.MarkWarm
+		runJob(a.Refresher.Start)
+	} else {
+		a.Health.MarkWarm()
+	}
+
+	// Pick up rotated upstream api keys
+	for _, p := range []provider.WeatherProvider{a.Service.Provider, a.Service.Fallback} {
+		if watcher, ok := p.(provider.SecretWatcher); ok {
+			runJob(watcher.WatchSecrets)
 		}
-	}()
+	}
+
+	// Drop keys revoked on any replica from our cache of verified keys
+	if a.Config.Auth.Enabled {
+		runJob(func(ctx context.Context) {
+			a.KeyStore.WatchRevocations(ctx, a.Authenticator.Invalidate)
+		})
+	}
+
+	if a.Alerts != nil {
+		runJob(a.Alerts.Start)
+		runJob(a.Webhooks.Start)
+	}
+
+	// Fan weather updates from redis out to streaming clients.
+	// Open streams are ended as soon as shutdown starts,
+	// since they would never leave the server idle.
+	if a.Hub != nil {
+		runJob(a.Hub.Start)
+		server.RegisterOnShutdown(a.Hub.Close)
+	}
+
+	// Serve TLS if we have a certificate, reloading it when it changes
+	tlsEnabled := a.Config.Server.TLSCertFile != "" && a.Config.Server.TLSKeyFile != ""
+	var grpcOptions []grpc.ServerOption
+	if tlsEnabled {
+		reloader, err := certs.NewReloader(a.Config.Server.TLSCertFile, a.Config.Server.TLSKeyFile)
+		if err != nil {
+			return stopStartedJobs(fmt.Errorf("Server failed to load tls certificate: %w", err))
+		}
+		server.TLSConfig = reloader.TLSConfig()
+		grpcOptions = append(grpcOptions, grpc.Creds(credentials.NewTLS(reloader.TLSConfig())))
+		runJob(func(ctx context.Context) {
+			reloader.Start(ctx, a.Config.Server.TLSReloadInterval)
+		})
+	}
+
+	// The gRPC server shares the weather service, and its
+	// health follows our readiness checks
+	var grpcListener net.Listener
+	if a.Config.GRPC.Enabled {
+		var authenticator *middleware.Authenticator
+		if a.Config.Auth.Enabled {
+			authenticator = a.Authenticator
+		}
+		var limiter *middleware.RateLimiter
+		if a.Config.RateLimit.Enabled {
+			limiter = a.RateLimiter
+		}
+		a.GRPC = rpc.NewServer(a.Service, authenticator, limiter, a.Logger, grpcOptions...)
+		grpcListener, err = net.Listen("tcp", a.Config.GRPC.Addr)
+		if err != nil {
+			return stopStartedJobs(fmt.Errorf("Server failed to listen for grpc: %w", err))
+		}
+		runJob(func(ctx context.Context) {
+			a.GRPC.WatchHealth(ctx, a.Health, a.Config.Health.CacheTTL)
+		})
+	}
 
-	log.Println("Starting Server on port 8080")
+	shutdown := lifecycle.NewShutdown()
+	shutdown.Add("http server", server.Shutdown)
+	if a.GRPC != nil {
+		shutdown.Add("grpc server", a.GRPC.Stop)
+	}
+	shutdown.Add("async cache inserts", a.Service.WaitForInserts)
+	shutdown.Add("background jobs", func(ctx context.Context) error {
+		stopJobs()
+		return waitGroupWithContext(ctx, &jobs)
+	})
+	shutdown.Add("tracing", shutdownTracing)
+	shutdown.Add("redis", func(context.Context) error {
+		return a.Rdb.Close()
+	})
+
+	a.Logger.Info("Starting server", "addr", a.Config.ServerAddr, "tls", tlsEnabled)
 
-	// Using buffered channel, only 1 error can happen here
-	channel := make(chan error, 1)
+	// Using buffered channel, only 1 error can happen
+	// here from each of the http and grpc servers
+	channel := make(chan error, 2)
 
 	// Go routine to start server on another thread in case for gracefun shutdown
 	// Wont block main thread if fails
 	go func() {
-		if err := server.ListenAndServe(); err != nil {
+		var err error
+		if tlsEnabled {
+			// The certificate comes from TLSConfig
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			channel <- fmt.Errorf("Server failed to start: %w", err)
 		}
-		close(channel)
 	}()
+	if a.GRPC != nil {
+		a.Logger.Info("Starting grpc server", "addr", a.Config.GRPC.Addr, "tls", tlsEnabled)
+		go func() {
+			if err := a.GRPC.GRPC.Serve(grpcListener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				channel <- fmt.Errorf("gRPC server failed: %w", err)
+			}
+		}()
+	}
 
 	// If there is an error from channel, select it and
 	// handle it gracefully
 	select {
 	case err = <-channel:
-		return err
 	case <-ctx.Done():
-		timeout, cancel := context.WithTimeout(context.Background(), time.Second*10)
-		defer cancel()
-		return server.Shutdown(timeout)
+		// Fail readiness first and give load balancers
+		// time to notice before we stop taking connections
+		a.Health.MarkShuttingDown()
+		if a.GRPC != nil {
+			a.GRPC.Drain()
+		}
+		a.Logger.Info("Shutting down, draining connections", "delay", a.Config.Health.DrainDelay)
+		time.Sleep(a.Config.Health.DrainDelay)
+	}
+
+	timeout, cancel := context.WithTimeout(logger.WithLogger(context.Background(), a.Logger), a.Config.Server.ShutdownTimeout)
+	defer cancel()
+	return errors.Join(err, shutdown.Run(timeout))
+}
+
+// Wait for a WaitGroup, or until the context is done
+func waitGroupWithContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
@@ -1,24 +1,195 @@
 package application
 
 import (
+	"net/http"
+
+	"github.com/bengimbel/go_redis_api/internal/auth"
 	"github.com/bengimbel/go_redis_api/internal/handler"
-	"github.com/go-chi/chi/middleware"
+	"github.com/bengimbel/go_redis_api/internal/metrics"
+	appMiddleware "github.com/bengimbel/go_redis_api/internal/middleware"
+	"github.com/bengimbel/go_redis_api/internal/openapi"
+	"github.com/bengimbel/go_redis_api/pkg/render"
 	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // Load routes and bind them to our App struct.
 func (a *App) LoadApiRoutes() {
 	router := chi.NewRouter()
-	router.Use(middleware.Logger)
+	router.Use(appMiddleware.RequestID)
+	// Negotiate the response format before anything can
+	// fail, so every error is rendered in the same format
+	router.Use(appMiddleware.Negotiate(render.NewDefaultRegistry()))
+	// Start a server span for every request, continuing
+	// the caller's trace from its traceparent header.
+	// It runs before the request logger so access logs get the trace id.
+	router.Use(otelhttp.NewMiddleware("weather-api"))
+	router.Use(appMiddleware.RequestLogger(a.Logger))
+	router.Use(metrics.Middleware)
+	a.LoadMiddleware(router)
 
-	router.Route("/api", a.LoadWeatherRouteGroup)
+	healthHandler := handler.NewHealthHandler(a.Health)
+	router.Get("/healthz", healthHandler.HandleLiveness)
+	router.Get("/readyz", healthHandler.HandleReadiness)
+	router.Handle("/metrics", metrics.Handler())
+	if a.Config.DocsEnabled {
+		router.Get(openapi.SPEC_PATH, openapi.HandleSpec)
+		router.Get(openapi.DOCS_PATH, openapi.HandleDocs)
+	}
+	router.Route("/api", a.LoadApiRouteGroup)
+	if a.Config.GraphQLEnabled {
+		router.Route("/graphql", a.LoadGraphQLRouteGroup)
+	}
 
 	a.Router = router
 }
 
+// The standard middleware stack, each part toggled by config.
+// Recovery runs inside the logger and metrics so panics are
+// recorded as 500s, and CORS runs before auth so browser
+// preflights, which never carry an api key, are answered.
+func (a *App) LoadMiddleware(router chi.Router) {
+	cfg := a.Config.Middleware
+	if cfg.RecoverEnabled {
+		router.Use(appMiddleware.Recoverer)
+	}
+	if cfg.SecurityHeadersEnabled {
+		router.Use(appMiddleware.SecurityHeaders)
+	}
+	if cfg.CORSEnabled {
+		router.Use(appMiddleware.NewCORS(cfg.CORSAllowedOrigins, cfg.CORSMaxAge).Handler)
+	}
+	if cfg.CompressionEnabled {
+		router.Use(appMiddleware.Compress(cfg.CompressionLevel))
+	}
+	if cfg.MaxBodyBytes > 0 {
+		router.Use(appMiddleware.MaxBodyBytes(cfg.MaxBodyBytes))
+	}
+}
+
+// Timeout middleware for a route, looked up by its full
+// pattern and falling back to the default request timeout
+func (a *App) RouteTimeout(pattern string) func(http.Handler) http.Handler {
+	timeout, ok := a.Config.Middleware.RouteTimeouts[pattern]
+	if !ok {
+		timeout = a.Config.Middleware.RequestTimeout
+	}
+	if timeout <= 0 {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+	return appMiddleware.Timeout(timeout)
+}
+
+// Clients are authenticated (when enabled) then
+// rate limited, so keys get their tier's limit.
+// Failed authentication is limited per IP before
+// that, so keys can't be guessed for free.
+func (a *App) LoadClientMiddleware(router chi.Router) {
+	if a.Config.Auth.Enabled && a.Config.RateLimit.Enabled {
+		router.Use(a.RateLimiter.AuthFailureHandler)
+	}
+	if a.Config.Auth.Enabled {
+		router.Use(a.Authenticator.Handler)
+	}
+	if a.Config.RateLimit.Enabled {
+		router.Use(a.RateLimiter.Handler)
+	}
+}
+
+// Everything under /api goes through the client middleware
+func (a *App) LoadApiRouteGroup(router chi.Router) {
+	a.LoadClientMiddleware(router)
+
+	router.Group(a.LoadWeatherRouteGroup)
+	if a.Geo != nil {
+		router.Route("/geo", a.LoadGeoRouteGroup)
+	}
+
+	// Alert rules belong to the key that made them and the admin
+	// api manages keys and the cache, so both are only served
+	// when auth is on
+	if a.Config.Auth.Enabled {
+		if a.AlertStore != nil {
+			router.Route("/alerts", a.LoadAlertRouteGroup)
+		}
+		router.Route("/admin", a.LoadAdminRouteGroup)
+	}
+}
+
 func (a *App) LoadWeatherRouteGroup(router chi.Router) {
-	handler := handler.NewWeatherHandler(a.Rdb)
+	weatherHandler := handler.NewWeatherHandler(a.Service)
+	if a.Geo != nil {
+		weatherHandler.Geo = a.Geo
+	}
+
+	if a.Config.Auth.Enabled {
+		router.Use(appMiddleware.RequireScope(auth.SCOPE_WEATHER_READ))
+	}
+
+	router.With(a.RouteTimeout("/api/weather")).Get("/weather", weatherHandler.HandleRetrieveWeather)
+	router.With(a.RouteTimeout("/api/weather/cached")).Get("/weather/cached", weatherHandler.HandleRetrieveCachedWeather)
+
+	if a.History != nil {
+		historyHandler := handler.NewHistoryHandler(a.History)
+		router.With(a.RouteTimeout("/api/weather/history")).Get("/weather/history", historyHandler.HandleRetrieveHistory)
+	}
+
+	// Streams stay open, so they get no timeout
+	if a.Hub != nil {
+		streamHandler := handler.NewStreamHandler(a.Service, a.Hub, a.Config.Stream.HeartbeatInterval)
+		router.Get("/weather/stream", streamHandler.HandleStreamWeather)
+	}
+}
+
+// Geocoding is read only, so it needs the weather scope
+func (a *App) LoadGeoRouteGroup(router chi.Router) {
+	handler := handler.NewGeoHandler(a.Geo)
+
+	if a.Config.Auth.Enabled {
+		router.Use(appMiddleware.RequireScope(auth.SCOPE_WEATHER_READ))
+	}
+
+	router.With(a.RouteTimeout("/api/geo/search")).Get("/search", handler.HandleSearchLocations)
+	router.With(a.RouteTimeout("/api/geo/reverse")).Get("/reverse", handler.HandleReverseGeocode)
+}
+
+func (a *App) LoadAlertRouteGroup(router chi.Router) {
+	handler := handler.NewAlertHandler(a.AlertStore)
+
+	router.Use(appMiddleware.RequireScope(auth.SCOPE_ALERTS_MANAGE))
+
+	router.With(a.RouteTimeout("/api/alerts")).Post("/", handler.HandleCreateRule)
+	router.With(a.RouteTimeout("/api/alerts")).Get("/", handler.HandleListRules)
+	router.With(a.RouteTimeout("/api/alerts/dead-letters")).Get("/dead-letters", handler.HandleListDeadLetters)
+	router.With(a.RouteTimeout("/api/alerts/{id}")).Get("/{id}", handler.HandleGetRule)
+	router.With(a.RouteTimeout("/api/alerts/{id}")).Put("/{id}", handler.HandleUpdateRule)
+	router.With(a.RouteTimeout("/api/alerts/{id}")).Delete("/{id}", handler.HandleDeleteRule)
+}
+
+// Graphql reads weather, so it needs the same
+// client middleware and scope as the weather routes
+func (a *App) LoadGraphQLRouteGroup(router chi.Router) {
+	handler := handler.NewGraphQLHandler(a.GraphQL)
+
+	a.LoadClientMiddleware(router)
+	if a.Config.Auth.Enabled {
+		router.Use(appMiddleware.RequireScope(auth.SCOPE_WEATHER_READ))
+	}
+
+	router.With(a.RouteTimeout("/graphql")).Get("/", handler.HandleQuery)
+	router.With(a.RouteTimeout("/graphql")).Post("/", handler.HandleQuery)
+}
+
+func (a *App) LoadAdminRouteGroup(router chi.Router) {
+	handler := handler.NewAdminHandler(a.KeyStore, a.Service)
+	handler.OnRevoke = a.Authenticator.Invalidate
 
-	router.Get("/weather", handler.HandleRetrieveWeather)
-	router.Get("/weather/cached", handler.HandleRetrieveCachedWeather)
+	router.With(appMiddleware.RequireScope(auth.SCOPE_KEYS_ADMIN)).Route("/keys", func(router chi.Router) {
+		router.With(a.RouteTimeout("/api/admin/keys")).Post("/", handler.HandleCreateKey)
+		router.With(a.RouteTimeout("/api/admin/keys")).Get("/", handler.HandleListKeys)
+		router.With(a.RouteTimeout("/api/admin/keys/{id}")).Delete("/{id}", handler.HandleRevokeKey)
+	})
+	router.With(appMiddleware.RequireScope(auth.SCOPE_CACHE_ADMIN), a.RouteTimeout("/api/admin/cache")).Delete("/cache", handler.HandleInvalidateCache)
 }
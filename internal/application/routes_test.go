@@ -0,0 +1,47 @@
+package application_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/application"
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/openapi"
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/internal/stream"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Every weather and geo route must be documented, so the spec
+// and the client generated from it stay complete
+func TestWeatherRoutesAreInOpenApiSpec(t *testing.T) {
+	doc, err := openapi.Load(context.Background())
+	assert.Nil(t, err)
+	app := &application.App{
+		Config:  &config.Config{},
+		Hub:     stream.NewHub(nil, 0),
+		History: repository.NewRedisHistory(nil, 0, 0),
+		Geo:     service.NewGeoService(nil, nil),
+	}
+	router := chi.NewRouter()
+	router.Route("/api", func(router chi.Router) {
+		router.Group(app.LoadWeatherRouteGroup)
+		router.Route("/geo", app.LoadGeoRouteGroup)
+	})
+
+	routes := 0
+	err = chi.Walk(router, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		routes++
+		path := doc.Paths.Find(route)
+		if assert.NotNil(t, path, "route %s is not in the openapi spec", route) {
+			assert.NotNil(t, path.GetOperation(method), "%s %s is not in the openapi spec", method, route)
+		}
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Greater(t, routes, 0)
+}
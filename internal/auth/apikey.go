@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	SCOPE_WEATHER_READ  string = "weather:read"
+	SCOPE_CACHE_ADMIN   string = "cache:admin"
+	SCOPE_KEYS_ADMIN    string = "keys:admin"
+	SCOPE_ALERTS_MANAGE string = "alerts:manage"
+
+	DEFAULT_TIER string = "free"
+
+	API_KEY_INDEX      string = "apikeys"
+	API_KEY_RECORD     string = "apikey:record:"
+	API_KEY_HASH_INDEX string = "apikey:hash:"
+	API_KEY_PREFIX     string = "wk_"
+	// Ids of revoked keys are published here, so every
+	// replica can drop them from its cache of verified keys
+	REVOKED_CHANNEL string = "apikey:revoked"
+)
+
+var ErrApiKeyNotFound = errors.New("api key not found")
+
+// Every scope a key can be given
+var AllScopes = []string{SCOPE_WEATHER_READ, SCOPE_CACHE_ADMIN, SCOPE_KEYS_ADMIN, SCOPE_ALERTS_MANAGE}
+
+// An API key issued to a consumer of our api.
+// We only ever store a hash of the key itself,
+// the plain key is shown once when it is created.
+type ApiKey struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Tier      string    `json:"tier"`
+	CreatedAt time.Time `json:"created_at"`
+	Revoked   bool      `json:"revoked"`
+	Hash      string    `json:"-"`
+}
+
+// Check if the key has been given a scope
+func (k ApiKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type KeyStoreImplementor interface {
+	Create(ctx context.Context, name string, scopes []string, tier string) (string, ApiKey, error)
+	FindByKey(context.Context, string) (ApiKey, error)
+	List(context.Context) ([]ApiKey, error)
+	Revoke(context.Context, string) error
+}
+
+// Stores API keys in redis. Each key has a record by id,
+// an index from the key's hash to its id for lookups,
+// and a set of every id for listing.
+type RedisKeyStore struct {
+	Client *redis.Client
+}
+
+// Stored form of a key. Hash is kept here (but not
+// in the json we return) so a key can be revoked by id.
+type storedApiKey struct {
+	ApiKey
+	Hash string `json:"hash"`
+}
+
+func NewRedisKeyStore(rds *redis.Client) *RedisKeyStore {
+	return &RedisKeyStore{
+		Client: rds,
+	}
+}
+
+// Generate and save a new key. Returns the plain key,
+// which can't be recovered later, along with its record.
+func (ks *RedisKeyStore) Create(ctx context.Context, name string, scopes []string, tier string) (string, ApiKey, error) {
+	for _, scope := range scopes {
+		if !isValidScope(scope) {
+			return "", ApiKey{}, fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+	if tier == "" {
+		tier = DEFAULT_TIER
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return "", ApiKey{}, err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return "", ApiKey{}, err
+	}
+	plainKey := API_KEY_PREFIX + secret
+
+	key := ApiKey{
+		Id:        id,
+		Name:      name,
+		Scopes:    scopes,
+		Tier:      tier,
+		CreatedAt: time.Now().UTC(),
+		Hash:      HashKey(plainKey),
+	}
+	record, err := json.Marshal(storedApiKey{ApiKey: key, Hash: key.Hash})
+	if err != nil {
+		return "", ApiKey{}, err
+	}
+
+	pipe := ks.Client.TxPipeline()
+	pipe.Set(ctx, API_KEY_RECORD+id, record, 0)
+	pipe.Set(ctx, API_KEY_HASH_INDEX+key.Hash, id, 0)
+	pipe.SAdd(ctx, API_KEY_INDEX, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", ApiKey{}, fmt.Errorf("failed to save api key to redis: %w", err)
+	}
+
+	return plainKey, key, nil
+}
+
+// Look up a key by its plain value. Revoked keys are not returned.
+func (ks *RedisKeyStore) FindByKey(ctx context.Context, plainKey string) (ApiKey, error) {
+	id, err := ks.Client.Get(ctx, API_KEY_HASH_INDEX+HashKey(plainKey)).Result()
+	if err == redis.Nil {
+		return ApiKey{}, ErrApiKeyNotFound
+	} else if err != nil {
+		return ApiKey{}, fmt.Errorf("failed to find api key in redis: %w", err)
+	}
+
+	key, err := ks.findById(ctx, id)
+	if err != nil {
+		return ApiKey{}, err
+	}
+	if key.Revoked {
+		return ApiKey{}, ErrApiKeyNotFound
+	}
+
+	return key, nil
+}
+
+// List every key, including revoked ones
+func (ks *RedisKeyStore) List(ctx context.Context) ([]ApiKey, error) {
+	ids, err := ks.Client.SMembers(ctx, API_KEY_INDEX).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys from redis: %w", err)
+	}
+
+	keys := []ApiKey{}
+	for _, id := range ids {
+		key, err := ks.findById(ctx, id)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// Revoke a key by id. The record is kept so it still shows
+// up when listing keys, but the key can no longer be used.
+func (ks *RedisKeyStore) Revoke(ctx context.Context, id string) error {
+	key, err := ks.findById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	key.Revoked = true
+	record, err := json.Marshal(storedApiKey{ApiKey: key, Hash: key.Hash})
+	if err != nil {
+		return err
+	}
+
+	pipe := ks.Client.TxPipeline()
+	pipe.Set(ctx, API_KEY_RECORD+id, record, 0)
+	pipe.Del(ctx, API_KEY_HASH_INDEX+key.Hash)
+	pipe.Publish(ctx, REVOKED_CHANNEL, id)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to revoke api key in redis: %w", err)
+	}
+
+	return nil
+}
+
+// Call onRevoke with the id of every key revoked on any replica.
+// This blocks until the context is cancelled, so it should be run
+// on its own go-routine. go-redis resubscribes on its own if the
+// connection drops, but keys revoked meanwhile are missed.
+func (ks *RedisKeyStore) WatchRevocations(ctx context.Context, onRevoke func(string)) {
+	pubsub := ks.Client.Subscribe(ctx, REVOKED_CHANNEL)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			onRevoke(msg.Payload)
+		}
+	}
+}
+
+func (ks *RedisKeyStore) findById(ctx context.Context, id string) (ApiKey, error) {
+	record, err := ks.Client.Get(ctx, API_KEY_RECORD+id).Bytes()
+	if err == redis.Nil {
+		return ApiKey{}, ErrApiKeyNotFound
+	} else if err != nil {
+		return ApiKey{}, fmt.Errorf("failed to find api key in redis: %w", err)
+	}
+
+	stored := storedApiKey{}
+	if err := json.Unmarshal(record, &stored); err != nil {
+		return ApiKey{}, fmt.Errorf("failed to decode api key: %w", err)
+	}
+	stored.ApiKey.Hash = stored.Hash
+
+	return stored.ApiKey, nil
+}
+
+// Hash a plain key for storage and lookups. Keys are long
+// random strings, so a plain sha256 is enough here.
+func HashKey(plainKey string) string {
+	sum := sha256.Sum256([]byte(plainKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func isValidScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,16 @@
+package auth
+
+import "context"
+
+type contextKey struct{}
+
+// Attach the authenticated key to a request context
+func WithApiKey(ctx context.Context, key ApiKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// Get the authenticated key from a request context, if there is one
+func ApiKeyFromContext(ctx context.Context) (ApiKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(ApiKey)
+	return key, ok
+}
@@ -0,0 +1,112 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+)
+
+// Serves a TLS certificate from disk, reloading it when the files
+// change, so renewed certificates (e.g. from cert-manager or certbot)
+// are picked up without a restart.
+type Reloader struct {
+	CertFile string
+	KeyFile  string
+
+	mu          sync.RWMutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// Create a new Reloader, loading the certificate straight away
+// so a bad cert or key stops us from starting
+func NewReloader(certFile string, keyFile string) (*Reloader, error) {
+	reloader := &Reloader{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}
+	if _, err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// Used as tls.Config.GetCertificate
+func (rl *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.cert, nil
+}
+
+// A TLS config serving our certificate over HTTP/2 or HTTP/1.1
+func (rl *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: rl.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
+
+// Load the certificate again if either file has changed.
+// Returns whether it was reloaded. If the new files can't
+// be loaded we keep serving the old certificate.
+func (rl *Reloader) Reload() (bool, error) {
+	certInfo, err := os.Stat(rl.CertFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read tls certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(rl.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read tls key: %w", err)
+	}
+
+	rl.mu.RLock()
+	unchanged := rl.cert != nil &&
+		certInfo.ModTime().Equal(rl.certModTime) &&
+		keyInfo.ModTime().Equal(rl.keyModTime)
+	rl.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(rl.CertFile, rl.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+
+	rl.mu.Lock()
+	rl.cert = &cert
+	rl.certModTime = certInfo.ModTime()
+	rl.keyModTime = keyInfo.ModTime()
+	rl.mu.Unlock()
+
+	return true, nil
+}
+
+// Check for a new certificate every interval until the context
+// is cancelled. This blocks, so it should be run on its own go-routine.
+func (rl *Reloader) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := rl.Reload()
+			if err != nil {
+				logger.FromContext(ctx).Error("Failed to reload tls certificate, keeping the current one", "error", err)
+			} else if reloaded {
+				logger.FromContext(ctx).Info("Reloaded tls certificate", "cert", rl.CertFile)
+			}
+		}
+	}
+}
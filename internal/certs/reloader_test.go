@@ -0,0 +1,90 @@
+package certs_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/certs"
+	"github.com/stretchr/testify/assert"
+)
+
+// Write a self signed certificate for name to the cert and key files
+func writeCert(t *testing.T, certFile string, keyFile string, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+}
+
+func commonName(t *testing.T, reloader *certs.Reloader) string {
+	cert, err := reloader.GetCertificate(nil)
+	assert.Nil(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestReloaderPicksUpNewCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "old.example.com")
+
+	reloader, err := certs.NewReloader(certFile, keyFile)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "old.example.com", commonName(t, reloader))
+
+	// Unchanged files aren't reloaded
+	reloaded, err := reloader.Reload()
+	assert.Nil(t, err)
+	assert.False(t, reloaded)
+
+	writeCert(t, certFile, keyFile, "new.example.com")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+
+	reloaded, err = reloader.Reload()
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	assert.EqualValues(t, "new.example.com", commonName(t, reloader))
+}
+
+func TestReloaderKeepsCertificateWhenNewOneIsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, "old.example.com")
+
+	reloader, err := certs.NewReloader(certFile, keyFile)
+	assert.Nil(t, err)
+
+	os.WriteFile(certFile, []byte("not a certificate"), 0600)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+
+	_, err = reloader.Reload()
+	assert.NotNil(t, err)
+	assert.EqualValues(t, "old.example.com", commonName(t, reloader))
+}
@@ -0,0 +1,511 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_REDIS_ADDR         string        = "redis:6379"
+	DEFAULT_SERVER_ADDR        string        = ":8080"
+	DEFAULT_REFRESH_INTERVAL   time.Duration = 5 * time.Minute
+	DEFAULT_REFRESH_TOP_N      int           = 10
+	DEFAULT_POPULAR_CITIES_MAX int64         = 1000
+	DEFAULT_POPULAR_WINDOW     time.Duration = 24 * time.Hour
+	DEFAULT_UPSTREAM_RATE      int           = 60
+	DEFAULT_STALE_TTL          time.Duration = 24 * time.Hour
+	DEFAULT_RATE_LIMIT         int           = 60
+	DEFAULT_RATE_LIMIT_WINDOW  time.Duration = time.Minute
+	DEFAULT_WEATHER_PROVIDER   string        = "openweathermap"
+	DEFAULT_SERVICE_NAME       string        = "go_redis_api"
+	DEFAULT_LOG_LEVEL          string        = "info"
+	DEFAULT_LOG_FORMAT         string        = "json"
+	DEFAULT_HEALTH_TIMEOUT     time.Duration = 2 * time.Second
+	DEFAULT_HEALTH_CACHE_TTL   time.Duration = 5 * time.Second
+	DEFAULT_UPSTREAM_CACHE_TTL time.Duration = 30 * time.Second
+	DEFAULT_DRAIN_DELAY        time.Duration = 5 * time.Second
+	DEFAULT_REDIS_RETRY        time.Duration = 2 * time.Second
+	DEFAULT_READ_TIMEOUT       time.Duration = 10 * time.Second
+	DEFAULT_READ_HEADER        time.Duration = 5 * time.Second
+	DEFAULT_WRITE_TIMEOUT      time.Duration = 30 * time.Second
+	DEFAULT_IDLE_TIMEOUT       time.Duration = 2 * time.Minute
+	DEFAULT_MAX_HEADER_BYTES   int           = 1 << 20
+	DEFAULT_SHUTDOWN_TIMEOUT   time.Duration = 30 * time.Second
+	DEFAULT_TLS_RELOAD         time.Duration = time.Minute
+	DEFAULT_COMPRESSION_LEVEL  int           = 5
+	DEFAULT_MAX_BODY_BYTES     int           = 1 << 20
+	DEFAULT_REQUEST_TIMEOUT    time.Duration = 10 * time.Second
+	DEFAULT_CORS_MAX_AGE       time.Duration = 10 * time.Minute
+	DEFAULT_GRPC_ADDR          string        = ":9090"
+	DEFAULT_STREAM_HEARTBEAT   time.Duration = 15 * time.Second
+	DEFAULT_STREAM_MAX_CLIENTS int           = 1000
+	DEFAULT_ALERT_WORKERS      int           = 4
+	DEFAULT_ALERT_ATTEMPTS     int           = 5
+	DEFAULT_ALERT_BACKOFF      time.Duration = time.Second
+	DEFAULT_WEBHOOK_TIMEOUT    time.Duration = 5 * time.Second
+	DEFAULT_ALERT_QUEUE_SIZE   int           = 1000
+	DEFAULT_DEAD_LETTER_MAX    int           = 1000
+	DEFAULT_HISTORY_RETENTION  time.Duration = 7 * 24 * time.Hour
+	DEFAULT_HISTORY_MAX        int           = 5000
+	DEFAULT_KEY_SOURCE         string        = "env"
+	DEFAULT_KEY_ENV            string        = "APIKEY"
+	DEFAULT_KEY_STRATEGY       string        = "failover"
+	DEFAULT_KEY_RELOAD         time.Duration = 30 * time.Second
+	DEFAULT_AUTH_CACHE_TTL     time.Duration = time.Minute
+	DEFAULT_AUTH_STALE_TTL     time.Duration = time.Hour
+)
+
+// Runtime configuration for our App.
+// Every value is read from the environment
+// and falls back to a default if it is not set.
+type Config struct {
+	RedisAddr string
+	// How often redis is pinged to notice
+	// it going away and coming back
+	RedisRetryInterval time.Duration
+	ServerAddr         string
+	Server             ServerConfig
+	Refresher          RefresherConfig
+	Upstream           UpstreamConfig
+	RateLimit          RateLimitConfig
+	Auth               AuthConfig
+	Provider           ProviderConfig
+	Tracing            TracingConfig
+	Log                LogConfig
+	Health             HealthConfig
+	Middleware         MiddlewareConfig
+	// Serve the OpenAPI document and Swagger UI
+	DocsEnabled bool
+	// Serve weather over graphql at /graphql
+	GraphQLEnabled bool
+	GRPC           GRPCConfig
+	Stream         StreamConfig
+	Alerts         AlertsConfig
+	History        HistoryConfig
+}
+
+// Configuration for the background refresher that
+// keeps the most requested cities warm in the cache.
+type RefresherConfig struct {
+	Enabled bool
+	// How often the top cities are re-fetched. This should
+	// be shorter than the redis TTL so entries never lapse.
+	Interval time.Duration
+	// How many of the most requested cities to refresh.
+	TopN int
+	// How long the leader lock is held before it must be renewed.
+	LockTTL time.Duration
+	// How many cities we keep request counts for.
+	PopularCitiesMax int64
+	// How far back requests count towards a city's popularity.
+	PopularCitiesWindow time.Duration
+}
+
+// Configuration for calls to the upstream weather api.
+// The budget is shared by every replica through redis.
+type UpstreamConfig struct {
+	// Calls allowed per minute. 0 turns off the per minute
+	// limit, but not DailyQuota.
+	RatePerMinute int
+	// Calls that can be made at once. Defaults to RatePerMinute.
+	Burst int
+	// Calls allowed per UTC day. 0 means no daily limit.
+	DailyQuota int64
+	// How long a stale copy is kept to serve
+	// when the quota is used up.
+	StaleTTL time.Duration
+	// Where the api keys come from: "env" for a comma separated
+	// list in KeyEnv, or "file" for one key per line in KeyFile,
+	// e.g. mounted from a secret volume
+	KeySource string
+	KeyEnv    string
+	KeyFile   string
+	// How a key is picked for each call: "failover" uses the
+	// first that works, "round-robin" spreads calls over them all
+	KeyStrategy string
+	// How often KeyFile is checked for changed keys
+	KeyReloadInterval time.Duration
+}
+
+// Configuration for per client rate limiting of our own api
+type RateLimitConfig struct {
+	Enabled bool
+	// Requests allowed per client in each window
+	Limit  int
+	Window time.Duration
+	// CIDRs of proxies whose X-Forwarded-For header we trust
+	TrustedProxies []string
+}
+
+// Configuration for API key authentication
+type AuthConfig struct {
+	Enabled bool
+	// Bootstrap key with every scope, used to create the first keys
+	AdminKey string
+	// Rate limit per window for each key tier, e.g. "free=60,pro=600"
+	Tiers map[string]int
+	// How long a verified key is trusted without asking the key
+	// store again. Revoking a key drops it from every replica's
+	// cache, but a replica that misses the revoke (e.g. while it is
+	// reconnecting to redis) keeps trusting it for up to this long.
+	KeyCacheTTL time.Duration
+	// While the key store is failing, keys verified within this
+	// long keep working (fail open for known keys). Keys we haven't
+	// seen fail closed with a 503. 0 fails closed for every key.
+	// A replica that missed a revoke can trust the key this long.
+	KeyStaleTTL time.Duration
+}
+
+// Which weather providers to use. Fallback is
+// optional and only used when the primary fails.
+type ProviderConfig struct {
+	Primary  string
+	Fallback string
+}
+
+// Configuration for OpenTelemetry tracing
+type TracingConfig struct {
+	// Where spans are sent: "none", "stdout" or "otlp"
+	Exporter    string
+	ServiceName string
+	// Fraction of new traces to sample, from 0 to 1
+	SampleRatio float64
+}
+
+// Configuration for structured logging
+type LogConfig struct {
+	// "debug", "info", "warn" or "error"
+	Level string
+	// "json" or "text"
+	Format string
+}
+
+// Configuration for the http server
+type ServerConfig struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// How long shutdown has, after the drain delay, to finish
+	// in-flight requests and async work before we give up
+	ShutdownTimeout time.Duration
+	// Serve TLS (and HTTP/2) if both are set
+	TLSCertFile string
+	TLSKeyFile  string
+	// How often the cert and key are checked for changes
+	TLSReloadInterval time.Duration
+}
+
+// Configuration for the standard middleware stack
+type MiddlewareConfig struct {
+	RecoverEnabled bool
+	CORSEnabled    bool
+	// Origins allowed to call us from a browser, or "*" for any
+	CORSAllowedOrigins []string
+	CORSMaxAge         time.Duration
+	CompressionEnabled bool
+	// gzip level from 1 (fastest) to 9 (smallest)
+	CompressionLevel int
+	// Largest request body we accept, 0 for no limit
+	MaxBodyBytes int64
+	// How long a request may take, 0 for no limit.
+	// RouteTimeouts overrides it by route pattern.
+	RequestTimeout         time.Duration
+	RouteTimeouts          map[string]time.Duration
+	SecurityHeadersEnabled bool
+}
+
+// Configuration for the gRPC server, which
+// runs alongside the http server on its own port
+type GRPCConfig struct {
+	Enabled bool
+	Addr    string
+}
+
+// Configuration for streaming weather
+// updates to clients over server-sent events
+type StreamConfig struct {
+	Enabled bool
+	// How often a comment is sent on an idle
+	// stream so proxies don't close it
+	HeartbeatInterval time.Duration
+	// Most streams open at once on this replica
+	MaxClients int
+}
+
+// Configuration for weather alert rules
+// and the webhooks they deliver
+type AlertsConfig struct {
+	Enabled bool
+	// Webhooks delivered at once
+	Workers int
+	// Tries per webhook before it is dead-lettered
+	MaxAttempts int
+	// Wait before the first retry, doubled after each one
+	RetryBackoff   time.Duration
+	WebhookTimeout time.Duration
+	// Weather updates and webhooks waiting to be handled
+	QueueSize int
+	// Failed deliveries kept in the dead-letter list
+	DeadLetterMax int
+}
+
+// Configuration for the snapshots kept
+// of every city's weather we fetch
+type HistoryConfig struct {
+	Enabled bool
+	// How long snapshots are kept
+	Retention time.Duration
+	// Most snapshots kept per city
+	MaxSnapshots int
+}
+
+// Configuration for the health endpoints
+type HealthConfig struct {
+	// How long each dependency check may take
+	Timeout time.Duration
+	// How long the redis check result is reused for
+	CacheTTL time.Duration
+	// How long the upstream check result is reused for.
+	// It is longer since it makes a call to a third party.
+	UpstreamCacheTTL time.Duration
+	// How long readiness fails before the server stops
+	// accepting connections, so load balancers can drain us
+	DrainDelay time.Duration
+}
+
+// Load configuration from the environment
+func Load() *Config {
+	interval := GetEnvPositiveDuration("REFRESH_INTERVAL", DEFAULT_REFRESH_INTERVAL)
+
+	return &Config{
+		RedisAddr:          GetEnv("REDIS_ADDR", DEFAULT_REDIS_ADDR),
+		RedisRetryInterval: GetEnvPositiveDuration("REDIS_RETRY_INTERVAL", DEFAULT_REDIS_RETRY),
+		ServerAddr:         GetEnv("SERVER_ADDR", DEFAULT_SERVER_ADDR),
+		Server: ServerConfig{
+			ReadTimeout:       GetEnvDuration("SERVER_READ_TIMEOUT", DEFAULT_READ_TIMEOUT),
+			ReadHeaderTimeout: GetEnvDuration("SERVER_READ_HEADER_TIMEOUT", DEFAULT_READ_HEADER),
+			WriteTimeout:      GetEnvDuration("SERVER_WRITE_TIMEOUT", DEFAULT_WRITE_TIMEOUT),
+			IdleTimeout:       GetEnvDuration("SERVER_IDLE_TIMEOUT", DEFAULT_IDLE_TIMEOUT),
+			MaxHeaderBytes:    GetEnvInt("SERVER_MAX_HEADER_BYTES", DEFAULT_MAX_HEADER_BYTES),
+			ShutdownTimeout:   GetEnvDuration("SHUTDOWN_TIMEOUT", DEFAULT_SHUTDOWN_TIMEOUT),
+			TLSCertFile:       GetEnv("TLS_CERT_FILE", ""),
+			TLSKeyFile:        GetEnv("TLS_KEY_FILE", ""),
+			TLSReloadInterval: GetEnvPositiveDuration("TLS_RELOAD_INTERVAL", DEFAULT_TLS_RELOAD),
+		},
+		Refresher: RefresherConfig{
+			Enabled:             GetEnvBool("REFRESH_ENABLED", true),
+			Interval:            interval,
+			TopN:                GetEnvInt("REFRESH_TOP_N", DEFAULT_REFRESH_TOP_N),
+			LockTTL:             GetEnvPositiveDuration("REFRESH_LOCK_TTL", 2*interval),
+			PopularCitiesMax:    int64(GetEnvInt("POPULAR_CITIES_MAX", int(DEFAULT_POPULAR_CITIES_MAX))),
+			PopularCitiesWindow: GetEnvPositiveDuration("POPULAR_CITIES_WINDOW", DEFAULT_POPULAR_WINDOW),
+		},
+		Upstream: UpstreamConfig{
+			RatePerMinute:     GetEnvInt("UPSTREAM_RATE_PER_MINUTE", DEFAULT_UPSTREAM_RATE),
+			Burst:             GetEnvInt("UPSTREAM_BURST", 0),
+			DailyQuota:        int64(GetEnvInt("UPSTREAM_DAILY_QUOTA", 0)),
+			StaleTTL:          GetEnvDuration("STALE_TTL", DEFAULT_STALE_TTL),
+			KeySource:         GetEnv("UPSTREAM_KEY_SOURCE", DEFAULT_KEY_SOURCE),
+			KeyEnv:            GetEnv("UPSTREAM_KEY_ENV", DEFAULT_KEY_ENV),
+			KeyFile:           GetEnv("UPSTREAM_KEY_FILE", ""),
+			KeyStrategy:       GetEnv("UPSTREAM_KEY_STRATEGY", DEFAULT_KEY_STRATEGY),
+			KeyReloadInterval: GetEnvDuration("UPSTREAM_KEY_RELOAD_INTERVAL", DEFAULT_KEY_RELOAD),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:        GetEnvBool("RATE_LIMIT_ENABLED", true),
+			Limit:          GetEnvInt("RATE_LIMIT", DEFAULT_RATE_LIMIT),
+			Window:         GetEnvPositiveDuration("RATE_LIMIT_WINDOW", DEFAULT_RATE_LIMIT_WINDOW),
+			TrustedProxies: GetEnvList("TRUSTED_PROXIES", []string{}),
+		},
+		Auth: AuthConfig{
+			Enabled:     GetEnvBool("AUTH_ENABLED", false),
+			AdminKey:    GetEnv("ADMIN_API_KEY", ""),
+			Tiers:       GetEnvIntMap("RATE_LIMIT_TIERS", map[string]int{"free": DEFAULT_RATE_LIMIT}),
+			KeyCacheTTL: GetEnvDuration("AUTH_KEY_CACHE_TTL", DEFAULT_AUTH_CACHE_TTL),
+			KeyStaleTTL: GetEnvDuration("AUTH_KEY_STALE_TTL", DEFAULT_AUTH_STALE_TTL),
+		},
+		Provider: ProviderConfig{
+			Primary:  GetEnv("WEATHER_PROVIDER", DEFAULT_WEATHER_PROVIDER),
+			Fallback: GetEnv("WEATHER_FALLBACK_PROVIDER", ""),
+		},
+		Tracing: TracingConfig{
+			Exporter:    GetEnv("TRACING_EXPORTER", "none"),
+			ServiceName: GetEnv("OTEL_SERVICE_NAME", DEFAULT_SERVICE_NAME),
+			SampleRatio: GetEnvFloat("TRACING_SAMPLE_RATIO", 1),
+		},
+		Log: LogConfig{
+			Level:  GetEnv("LOG_LEVEL", DEFAULT_LOG_LEVEL),
+			Format: GetEnv("LOG_FORMAT", DEFAULT_LOG_FORMAT),
+		},
+		Middleware: MiddlewareConfig{
+			RecoverEnabled:         GetEnvBool("RECOVER_ENABLED", true),
+			CORSEnabled:            GetEnvBool("CORS_ENABLED", false),
+			CORSAllowedOrigins:     GetEnvList("CORS_ALLOWED_ORIGINS", []string{}),
+			CORSMaxAge:             GetEnvDuration("CORS_MAX_AGE", DEFAULT_CORS_MAX_AGE),
+			CompressionEnabled:     GetEnvBool("COMPRESSION_ENABLED", true),
+			CompressionLevel:       GetEnvInt("COMPRESSION_LEVEL", DEFAULT_COMPRESSION_LEVEL),
+			MaxBodyBytes:           int64(GetEnvInt("MAX_REQUEST_BODY_BYTES", DEFAULT_MAX_BODY_BYTES)),
+			RequestTimeout:         GetEnvDuration("REQUEST_TIMEOUT", DEFAULT_REQUEST_TIMEOUT),
+			RouteTimeouts:          GetEnvDurationMap("ROUTE_TIMEOUTS", map[string]time.Duration{}),
+			SecurityHeadersEnabled: GetEnvBool("SECURITY_HEADERS_ENABLED", true),
+		},
+		Health: HealthConfig{
+			Timeout:          GetEnvDuration("HEALTH_CHECK_TIMEOUT", DEFAULT_HEALTH_TIMEOUT),
+			CacheTTL:         GetEnvDuration("HEALTH_CACHE_TTL", DEFAULT_HEALTH_CACHE_TTL),
+			UpstreamCacheTTL: GetEnvDuration("HEALTH_UPSTREAM_CACHE_TTL", DEFAULT_UPSTREAM_CACHE_TTL),
+			DrainDelay:       GetEnvDuration("SHUTDOWN_DRAIN_DELAY", DEFAULT_DRAIN_DELAY),
+		},
+		DocsEnabled:    GetEnvBool("DOCS_ENABLED", true),
+		GraphQLEnabled: GetEnvBool("GRAPHQL_ENABLED", true),
+		GRPC: GRPCConfig{
+			Enabled: GetEnvBool("GRPC_ENABLED", true),
+			Addr:    GetEnv("GRPC_ADDR", DEFAULT_GRPC_ADDR),
+		},
+		Stream: StreamConfig{
+			Enabled:           GetEnvBool("STREAM_ENABLED", true),
+			HeartbeatInterval: GetEnvPositiveDuration("STREAM_HEARTBEAT_INTERVAL", DEFAULT_STREAM_HEARTBEAT),
+			MaxClients:        GetEnvInt("STREAM_MAX_CLIENTS", DEFAULT_STREAM_MAX_CLIENTS),
+		},
+		Alerts: AlertsConfig{
+			Enabled:        GetEnvBool("ALERTS_ENABLED", true),
+			Workers:        GetEnvInt("ALERT_WORKERS", DEFAULT_ALERT_WORKERS),
+			MaxAttempts:    GetEnvInt("ALERT_MAX_ATTEMPTS", DEFAULT_ALERT_ATTEMPTS),
+			RetryBackoff:   GetEnvDuration("ALERT_RETRY_BACKOFF", DEFAULT_ALERT_BACKOFF),
+			WebhookTimeout: GetEnvDuration("ALERT_WEBHOOK_TIMEOUT", DEFAULT_WEBHOOK_TIMEOUT),
+			QueueSize:      GetEnvInt("ALERT_QUEUE_SIZE", DEFAULT_ALERT_QUEUE_SIZE),
+			DeadLetterMax:  GetEnvInt("ALERT_DEAD_LETTER_MAX", DEFAULT_DEAD_LETTER_MAX),
+		},
+		History: HistoryConfig{
+			Enabled:      GetEnvBool("HISTORY_ENABLED", true),
+			Retention:    GetEnvDuration("HISTORY_RETENTION", DEFAULT_HISTORY_RETENTION),
+			MaxSnapshots: GetEnvInt("HISTORY_MAX_SNAPSHOTS", DEFAULT_HISTORY_MAX),
+		},
+	}
+}
+
+// Read a string from the environment, or return the fallback
+func GetEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Read an int from the environment, or return the fallback
+// if it is not set or can't be parsed
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Read a float from the environment, or return the fallback
+// if it is not set or can't be parsed
+func GetEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(GetEnv(key, ""), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Read a bool from the environment, or return the fallback
+// if it is not set or can't be parsed
+func GetEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Read a duration (e.g. "30s", "5m") from the environment,
+// or return the fallback if it is not set or can't be parsed
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Read a comma separated list from the environment,
+// or return the fallback if it is not set
+func GetEnvList(key string, fallback []string) []string {
+	value := GetEnv(key, "")
+	if value == "" {
+		return fallback
+	}
+
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Read a comma separated list of key=int pairs from the
+// environment, or return the fallback if it is not set.
+// Pairs that can't be parsed are skipped.
+func GetEnvIntMap(key string, fallback map[string]int) map[string]int {
+	list := GetEnvList(key, []string{})
+	if len(list) == 0 {
+		return fallback
+	}
+
+	values := map[string]int{}
+	for _, item := range list {
+		name, raw, found := strings.Cut(item, "=")
+		if !found {
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSpace(name)] = value
+	}
+	return values
+}
+
+// Like GetEnvDuration, for durations that must be above zero,
+// e.g. ticker intervals. Zero or negative values use the fallback.
+func GetEnvPositiveDuration(key string, fallback time.Duration) time.Duration {
+	value := GetEnvDuration(key, fallback)
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// Read a comma separated list of name=duration pairs from the
+// environment, e.g. "/api/weather=5s,/api/admin/keys=2s".
+// Returns the fallback if it is not set, and skips bad pairs.
+func GetEnvDurationMap(key string, fallback map[string]time.Duration) map[string]time.Duration {
+	list := GetEnvList(key, []string{})
+	if len(list) == 0 {
+		return fallback
+	}
+
+	values := map[string]time.Duration{}
+	for _, item := range list {
+		name, raw, found := strings.Cut(item, "=")
+		if !found {
+			continue
+		}
+		value, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			continue
+		}
+		values[strings.TrimSpace(name)] = value
+	}
+	return values
+}
@@ -0,0 +1,32 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRejectsNonPositiveRefreshInterval(t *testing.T) {
+	for _, value := range []string{"0", "0s", "-1m"} {
+		t.Setenv("REFRESH_INTERVAL", value)
+		cfg := config.Load()
+		assert.Equal(t, config.DEFAULT_REFRESH_INTERVAL, cfg.Refresher.Interval, value)
+		assert.Equal(t, 2*config.DEFAULT_REFRESH_INTERVAL, cfg.Refresher.LockTTL, value)
+	}
+
+	t.Setenv("REFRESH_INTERVAL", "90s")
+	assert.Equal(t, "1m30s", config.Load().Refresher.Interval.String())
+}
+
+func TestLoadRejectsNonPositiveTickerIntervals(t *testing.T) {
+	t.Setenv("REDIS_RETRY_INTERVAL", "0")
+	t.Setenv("REFRESH_LOCK_TTL", "-1s")
+	t.Setenv("TLS_RELOAD_INTERVAL", "0s")
+	t.Setenv("STREAM_HEARTBEAT_INTERVAL", "-5s")
+	cfg := config.Load()
+	assert.Equal(t, config.DEFAULT_REDIS_RETRY, cfg.RedisRetryInterval)
+	assert.Equal(t, config.DEFAULT_TLS_RELOAD, cfg.Server.TLSReloadInterval)
+	assert.Equal(t, config.DEFAULT_STREAM_HEARTBEAT, cfg.Stream.HeartbeatInterval)
+	assert.Equal(t, 2*config.DEFAULT_REFRESH_INTERVAL, cfg.Refresher.LockTTL)
+}
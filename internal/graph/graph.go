@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/internal/tracing"
+	"github.com/graph-gophers/graphql-go"
+	graphqlOtel "github.com/graph-gophers/graphql-go/trace/otel"
+)
+
+const (
+	// How deeply queries may nest, which is plenty for our schema
+	MAX_DEPTH int = 10
+	// How many cities one request may ask for, so a single
+	// query can't use up our upstream quota
+	MAX_CITIES int = 20
+)
+
+//go:embed schema.graphql
+var schema string
+
+type GraphQLImplementor interface {
+	Exec(context.Context, Request) *graphql.Response
+}
+
+// A graphql query, as sent in a request body
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type GraphQL struct {
+	Schema  *graphql.Schema
+	Service service.WeatherServiceImplementor
+}
+
+// Resolvers call the weather service, so graphql gets
+// the same cache-aside behavior as the rest api
+func NewGraphQL(svc service.WeatherServiceImplementor) (*GraphQL, error) {
+	parsed, err := graphql.ParseSchema(schema, &queryResolver{service: svc},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(MAX_DEPTH),
+		graphql.Tracer(&graphqlOtel.Tracer{Tracer: tracing.Tracer()}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse graphql schema: %w", err)
+	}
+
+	return &GraphQL{
+		Schema:  parsed,
+		Service: svc,
+	}, nil
+}
+
+// Run a query. Every query gets its own loader, so cities
+// asked for more than once are only looked up once, and
+// cached values never outlive the request. It also gets its
+// own budget of MAX_CITIES cities across all of its fields.
+func (g *GraphQL) Exec(ctx context.Context, req Request) *graphql.Response {
+	ctx = withLoader(ctx, newWeatherLoader(g.Service))
+	ctx = withBudget(ctx, newCityBudget())
+	return g.Schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+}
@@ -0,0 +1,184 @@
+package graph_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/graph"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (ms *MockService) RetrieveAndCacheWeatherAsync(ctx context.Context, city string) (model.CachedWeather, error) {
+	args := ms.Called(ctx, city)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
+}
+func (ms *MockService) RetrieveWeatherFromCache(ctx context.Context, city string) (model.CachedWeather, error) {
+	args := ms.Called(ctx, city)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
+}
+func (ms *MockService) DoesKeyExist(ctx context.Context, city string) bool {
+	args := ms.Called(ctx, city)
+	return args.Bool(0)
+}
+func (ms *MockService) InsertToCacheAsync(ctx context.Context, city string, weather model.CachedWeather) error {
+	args := ms.Called(ctx, city, weather)
+	return args.Error(0)
+}
+func (ms *MockService) RecordCityRequest(ctx context.Context, city string) error {
+	args := ms.Called(ctx, city)
+	return args.Error(0)
+}
+func (ms *MockService) PopularCities(ctx context.Context, n int) ([]string, error) {
+	args := ms.Called(ctx, n)
+	return args.Get(0).([]string), args.Error(1)
+}
+func (ms *MockService) RefreshWeather(ctx context.Context, city string) (model.CachedWeather, error) {
+	args := ms.Called(ctx, city)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
+}
+func (ms *MockService) InvalidateCity(ctx context.Context, city string) error {
+	args := ms.Called(ctx, city)
+	return args.Error(0)
+}
+
+func cachedWeather(city string, temp float32) model.CachedWeather {
+	return model.NewCachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: city,
+		},
+		List: []model.List{
+			{
+				Dt:      123,
+				Main:    model.Main{Temp: temp},
+				Weather: []model.Weather{{Icon: "10d"}},
+			},
+		},
+	}, "mock", time.Now(), 10*time.Minute)
+}
+
+func TestExecSelectsRequestedFields(t *testing.T) {
+	svc := &MockService{}
+	g, err := graph.NewGraphQL(svc)
+	assert.Nil(t, err)
+
+	svc.On("DoesKeyExist", mock.Anything, "chicago").Return(true).Once()
+	svc.On("RetrieveWeatherFromCache", mock.Anything, "chicago").Return(cachedWeather("chicago", 280.5), nil).Once()
+	svc.On("RecordCityRequest", mock.Anything, "chicago").Return(nil).Once()
+
+	resp := g.Exec(context.Background(), graph.Request{
+		Query: `{ weather(city: "Chicago") { city { name } list { main { temp } weather { icon } } } }`,
+	})
+
+	assert.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"weather":{"city":{"name":"chicago"},"list":[{"main":{"temp":280.5},"weather":[{"icon":"10d"}]}]}}`, string(resp.Data))
+}
+
+// Cities asked for more than once in a query are
+// only looked up once, and one failing city doesn't
+// fail the others
+func TestExecBatchesCities(t *testing.T) {
+	svc := &MockService{}
+	g, err := graph.NewGraphQL(svc)
+	assert.Nil(t, err)
+
+	svc.On("DoesKeyExist", mock.Anything, mock.Anything).Return(false)
+	svc.On("RetrieveAndCacheWeatherAsync", mock.Anything, "miami").Return(cachedWeather("miami", 300), nil).Once()
+	svc.On("RetrieveAndCacheWeatherAsync", mock.Anything, "denver").Return(cachedWeather("denver", 270), nil).Once()
+	svc.On("RetrieveAndCacheWeatherAsync", mock.Anything, "nowhere").Return(model.CachedWeather{}, errors.New("city not found")).Once()
+	svc.On("RecordCityRequest", mock.Anything, mock.Anything).Return(nil)
+
+	resp := g.Exec(context.Background(), graph.Request{
+		Query: `query Cities($cities: [String!]!) {
+			first: weather(city: "miami") { city { name } }
+			cities: weatherByCities(cities: $cities) { city { name } meta { source provider } }
+		}`,
+		Variables: map[string]interface{}{
+			"cities": []interface{}{"Miami", "nowhere", "denver"},
+		},
+	})
+
+	var data struct {
+		First struct {
+			City struct {
+				Name string
+			}
+		}
+		Cities []*struct {
+			City struct {
+				Name string
+			}
+			Meta struct {
+				Source   string
+				Provider string
+			}
+		}
+	}
+	assert.Nil(t, json.Unmarshal(resp.Data, &data))
+	assert.EqualValues(t, "miami", data.First.City.Name)
+	assert.Len(t, data.Cities, 3)
+	assert.EqualValues(t, "miami", data.Cities[0].City.Name)
+	assert.EqualValues(t, model.SOURCE_UPSTREAM, data.Cities[0].Meta.Source)
+	assert.EqualValues(t, "mock", data.Cities[0].Meta.Provider)
+	assert.Nil(t, data.Cities[1])
+	assert.EqualValues(t, "denver", data.Cities[2].City.Name)
+	assert.NotEmpty(t, resp.Errors)
+	assert.Contains(t, resp.Errors[0].Message, "city not found")
+	svc.AssertNumberOfCalls(t, "RetrieveAndCacheWeatherAsync", 3)
+}
+
+func TestExecLimitsCities(t *testing.T) {
+	svc := &MockService{}
+	g, err := graph.NewGraphQL(svc)
+	assert.Nil(t, err)
+	cities := make([]interface{}, graph.MAX_CITIES+1)
+	for i := range cities {
+		cities[i] = "chicago"
+	}
+
+	resp := g.Exec(context.Background(), graph.Request{
+		Query:     `query Cities($cities: [String!]!) { weatherByCities(cities: $cities) { city { name } } }`,
+		Variables: map[string]interface{}{"cities": cities},
+	})
+
+	assert.NotEmpty(t, resp.Errors)
+	svc.AssertNotCalled(t, "DoesKeyExist", mock.Anything, mock.Anything)
+}
+
+func TestExecLimitsCitiesAcrossAliases(t *testing.T) {
+	svc := &MockService{}
+	g, err := graph.NewGraphQL(svc)
+	assert.Nil(t, err)
+	first := make([]interface{}, graph.MAX_CITIES)
+	second := make([]interface{}, graph.MAX_CITIES)
+	for i := range first {
+		first[i] = fmt.Sprintf("city%d", i)
+		second[i] = fmt.Sprintf("town%d", i)
+	}
+
+	svc.On("DoesKeyExist", mock.Anything, mock.Anything).Return(false)
+	svc.On("RetrieveAndCacheWeatherAsync", mock.Anything, mock.Anything).Return(cachedWeather("somewhere", 280), nil)
+	svc.On("RecordCityRequest", mock.Anything, mock.Anything).Return(nil)
+
+	resp := g.Exec(context.Background(), graph.Request{
+		Query: `query Cities($first: [String!]!, $second: [String!]!) {
+			a: weatherByCities(cities: $first) { city { name } }
+			b: weatherByCities(cities: $second) { city { name } }
+		}`,
+		Variables: map[string]interface{}{"first": first, "second": second},
+	})
+
+	// Whichever field is resolved second is over the budget
+	assert.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "too many cities")
+	svc.AssertNumberOfCalls(t, "RetrieveAndCacheWeatherAsync", graph.MAX_CITIES)
+}
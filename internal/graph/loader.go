@@ -0,0 +1,118 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/internal/tracing"
+	"github.com/graph-gophers/dataloader/v7"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// How long the loader waits to collect cities into a batch
+	LOADER_WAIT time.Duration = 2 * time.Millisecond
+	// Cities looked up at once in a batch
+	LOADER_CONCURRENCY int = 8
+)
+
+type loaderKey struct{}
+
+type budgetKey struct{}
+
+type weatherLoader = dataloader.Loader[string, model.CachedWeather]
+
+// Batches every city asked for while resolving a query,
+// deduplicating them and looking them up in parallel
+func newWeatherLoader(svc service.WeatherServiceImplementor) *weatherLoader {
+	batch := func(ctx context.Context, cities []string) []*dataloader.Result[model.CachedWeather] {
+		ctx, span := tracing.Tracer().Start(ctx, "graph.LoadWeather",
+			trace.WithAttributes(attribute.Int("weather.cities", len(cities))),
+		)
+		defer span.End()
+
+		results := make([]*dataloader.Result[model.CachedWeather], len(cities))
+		limit := make(chan struct{}, LOADER_CONCURRENCY)
+		var wg sync.WaitGroup
+		for i, city := range cities {
+			wg.Add(1)
+			limit <- struct{}{}
+			go func(i int, city string) {
+				defer wg.Done()
+				defer func() { <-limit }()
+
+				weather, err := service.RetrieveWeather(ctx, svc, city)
+				results[i] = &dataloader.Result[model.CachedWeather]{Data: weather, Error: err}
+			}(i, city)
+		}
+		wg.Wait()
+
+		return results
+	}
+
+	return dataloader.NewBatchedLoader(batch,
+		dataloader.WithWait[string, model.CachedWeather](LOADER_WAIT),
+	)
+}
+
+func withLoader(ctx context.Context, loader *weatherLoader) context.Context {
+	return context.WithValue(ctx, loaderKey{}, loader)
+}
+
+func loaderFromContext(ctx context.Context) *weatherLoader {
+	loader, _ := ctx.Value(loaderKey{}).(*weatherLoader)
+	return loader
+}
+
+// The distinct cities one query has asked for, across every
+// field, so aliasing a field can't get around MAX_CITIES
+type cityBudget struct {
+	mu     sync.Mutex
+	cities map[string]struct{}
+}
+
+func newCityBudget() *cityBudget {
+	return &cityBudget{cities: map[string]struct{}{}}
+}
+
+// Count cities against the budget. Cities already asked for
+// are free, since the loader only looks them up once. If they
+// don't all fit none are counted.
+func (b *cityBudget) take(keys []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	added := map[string]struct{}{}
+	for _, key := range keys {
+		if _, ok := b.cities[key]; !ok {
+			added[key] = struct{}{}
+		}
+	}
+	if len(b.cities)+len(added) > MAX_CITIES {
+		return fmt.Errorf("too many cities, at most %d can be requested in one query", MAX_CITIES)
+	}
+	for key := range added {
+		b.cities[key] = struct{}{}
+	}
+	return nil
+}
+
+func withBudget(ctx context.Context, budget *cityBudget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+func budgetFromContext(ctx context.Context) *cityBudget {
+	budget, _ := ctx.Value(budgetKey{}).(*cityBudget)
+	return budget
+}
+
+// Cities are cached by lowercase name, like the rest api
+func cityKey(city string) string {
+	return strings.ToLower(city)
+}
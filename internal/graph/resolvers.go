@@ -0,0 +1,311 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/service"
+)
+
+// Resolvers wrap the models, converting their fields to the
+// types graphql expects: Int is an int32 and Float a float64.
+
+type queryResolver struct {
+	service service.WeatherServiceImplementor
+}
+
+type cityArgs struct {
+	City string
+}
+
+type citiesArgs struct {
+	Cities []string
+}
+
+func (q *queryResolver) Weather(ctx context.Context, args cityArgs) (*weatherResolver, error) {
+	key := cityKey(args.City)
+	if err := budgetFromContext(ctx).take([]string{key}); err != nil {
+		return nil, err
+	}
+	weather, err := loaderFromContext(ctx).Load(ctx, key)()
+	if err != nil {
+		return nil, err
+	}
+	return &weatherResolver{weather: weather}, nil
+}
+
+// A city that fails comes back as null with its own
+// error, so one bad city doesn't fail the rest
+func (q *queryResolver) WeatherByCities(ctx context.Context, args citiesArgs) ([]*weatherResolver, error) {
+	if len(args.Cities) > MAX_CITIES {
+		return nil, fmt.Errorf("too many cities, at most %d can be requested at once", MAX_CITIES)
+	}
+
+	keys := make([]string, len(args.Cities))
+	for i, city := range args.Cities {
+		keys[i] = cityKey(city)
+	}
+	if err := budgetFromContext(ctx).take(keys); err != nil {
+		return nil, err
+	}
+	weathers, errs := loaderFromContext(ctx).LoadMany(ctx, keys)()
+
+	resolvers := make([]*weatherResolver, len(weathers))
+	for i, weather := range weathers {
+		resolvers[i] = &weatherResolver{weather: weather}
+		if errs != nil && errs[i] != nil {
+			resolvers[i].err = fmt.Errorf("%s: %w", keys[i], errs[i])
+		}
+	}
+	return resolvers, nil
+}
+
+func (q *queryResolver) CachedWeather(ctx context.Context, args cityArgs) (*weatherResolver, error) {
+	weather, err := q.service.RetrieveWeatherFromCache(ctx, cityKey(args.City))
+	if err != nil {
+		return nil, err
+	}
+	return &weatherResolver{weather: weather}, nil
+}
+
+// Weather for a city, or the error getting it, which
+// makes the city null in a list of cities
+type weatherResolver struct {
+	weather model.CachedWeather
+	err     error
+}
+
+func (r *weatherResolver) City() (*cityResolver, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &cityResolver{r.weather.Weather.City}, nil
+}
+
+func (r *weatherResolver) List() ([]*listResolver, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	list := make([]*listResolver, len(r.weather.Weather.List))
+	for i, item := range r.weather.Weather.List {
+		list[i] = &listResolver{item}
+	}
+	return list, nil
+}
+
+func (r *weatherResolver) Meta() (*metaResolver, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &metaResolver{r.weather}, nil
+}
+
+type metaResolver struct {
+	weather model.CachedWeather
+}
+
+func (r *metaResolver) Source() string {
+	return r.weather.Source
+}
+
+func (r *metaResolver) Provider() string {
+	return r.weather.Provider
+}
+
+func (r *metaResolver) FetchedAt() string {
+	return r.weather.FetchedAt.UTC().Format(time.RFC3339)
+}
+
+func (r *metaResolver) ExpiresAt() string {
+	return r.weather.ExpiresAt.UTC().Format(time.RFC3339)
+}
+
+func (r *metaResolver) Stale() bool {
+	return r.weather.Stale
+}
+
+type cityResolver struct {
+	city model.City
+}
+
+func (r *cityResolver) Id() int32 {
+	return r.city.Id
+}
+
+func (r *cityResolver) Name() string {
+	return r.city.Name
+}
+
+func (r *cityResolver) Coord() *coordResolver {
+	return &coordResolver{r.city.Coord}
+}
+
+func (r *cityResolver) Country() string {
+	return r.city.Country
+}
+
+func (r *cityResolver) Population() int32 {
+	return int32(r.city.Population)
+}
+
+func (r *cityResolver) Timezone() int32 {
+	return r.city.Timezone
+}
+
+func (r *cityResolver) Sunrise() int32 {
+	return int32(r.city.Sunrise)
+}
+
+func (r *cityResolver) Sunset() int32 {
+	return int32(r.city.Sunset)
+}
+
+type coordResolver struct {
+	coord model.Coord
+}
+
+func (r *coordResolver) Lat() float64 {
+	return r.coord.Lat
+}
+
+func (r *coordResolver) Lon() float64 {
+	return r.coord.Lon
+}
+
+type listResolver struct {
+	item model.List
+}
+
+func (r *listResolver) Dt() int32 {
+	return int32(r.item.Dt)
+}
+
+func (r *listResolver) Main() *mainResolver {
+	return &mainResolver{r.item.Main}
+}
+
+func (r *listResolver) Weather() []*conditionResolver {
+	conditions := make([]*conditionResolver, len(r.item.Weather))
+	for i, condition := range r.item.Weather {
+		conditions[i] = &conditionResolver{condition}
+	}
+	return conditions
+}
+
+func (r *listResolver) Clouds() *cloudsResolver {
+	return &cloudsResolver{r.item.Clouds}
+}
+
+func (r *listResolver) Wind() *windResolver {
+	return &windResolver{r.item.Wind}
+}
+
+func (r *listResolver) Visibility() int32 {
+	return r.item.Visibility
+}
+
+func (r *listResolver) Pop() float64 {
+	return float64(r.item.Pop)
+}
+
+func (r *listResolver) Sys() *sysResolver {
+	return &sysResolver{r.item.Sys}
+}
+
+func (r *listResolver) DtTxt() string {
+	return r.item.DtTxt
+}
+
+type mainResolver struct {
+	main model.Main
+}
+
+func (r *mainResolver) Temp() float64 {
+	return float64(r.main.Temp)
+}
+
+func (r *mainResolver) FeelsLike() float64 {
+	return float64(r.main.FeelsLike)
+}
+
+func (r *mainResolver) TempMin() float64 {
+	return float64(r.main.TempMin)
+}
+
+func (r *mainResolver) TempMax() float64 {
+	return float64(r.main.TempMax)
+}
+
+func (r *mainResolver) Pressure() int32 {
+	return r.main.Pressure
+}
+
+func (r *mainResolver) SeaLevel() int32 {
+	return r.main.SeaLevel
+}
+
+func (r *mainResolver) GrndLevel() int32 {
+	return r.main.GrndLevel
+}
+
+func (r *mainResolver) Humidity() int32 {
+	return r.main.Humidity
+}
+
+func (r *mainResolver) TempKf() float64 {
+	return float64(r.main.TempKf)
+}
+
+type conditionResolver struct {
+	condition model.Weather
+}
+
+func (r *conditionResolver) Id() int32 {
+	return r.condition.Id
+}
+
+func (r *conditionResolver) Main() string {
+	return r.condition.Main
+}
+
+func (r *conditionResolver) Description() string {
+	return r.condition.Description
+}
+
+func (r *conditionResolver) Icon() string {
+	return r.condition.Icon
+}
+
+type cloudsResolver struct {
+	clouds model.Clouds
+}
+
+func (r *cloudsResolver) All() int32 {
+	return r.clouds.All
+}
+
+type windResolver struct {
+	wind model.Wind
+}
+
+func (r *windResolver) Speed() float64 {
+	return float64(r.wind.Speed)
+}
+
+func (r *windResolver) Deg() float64 {
+	return float64(r.wind.Deg)
+}
+
+func (r *windResolver) Gust() float64 {
+	return float64(r.wind.Gust)
+}
+
+type sysResolver struct {
+	sys model.Sys
+}
+
+func (r *sysResolver) Pod() string {
+	return r.sys.Pod
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	"github.com/bengimbel/go_redis_api/pkg/render"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminHandler struct {
+	Store   auth.KeyStoreImplementor
+	Service service.WeatherServiceImplementor
+	// Optional hook called with the id of a revoked key,
+	// e.g. to stop trusting a cached copy of it
+	OnRevoke func(string)
+}
+
+// Body for creating a new API key
+type CreateKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Tier   string   `json:"tier"`
+}
+
+// Response for a newly created API key. The plain
+// key is only ever returned here, so it must be saved.
+type CreateKeyResponse struct {
+	Key    string      `json:"key"`
+	ApiKey auth.ApiKey `json:"api_key"`
+}
+
+func NewAdminHandler(store auth.KeyStoreImplementor, svc service.WeatherServiceImplementor) *AdminHandler {
+	return &AdminHandler{
+		Store:   store,
+		Service: svc,
+	}
+}
+
+// Handler for creating a new API key
+func (ah *AdminHandler) HandleCreateKey(w http.ResponseWriter, r *http.Request) {
+	body := CreateKeyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		errorPkg.RenderBadRequestError(w, errors.New("invalid request body"))
+		return
+	}
+	if body.Name == "" {
+		errorPkg.RenderBadRequestError(w, errors.New("name is required"))
+		return
+	}
+	if len(body.Scopes) == 0 {
+		body.Scopes = []string{auth.SCOPE_WEATHER_READ}
+	}
+
+	plainKey, key, err := ah.Store.Create(r.Context(), body.Name, body.Scopes, body.Tier)
+	if err != nil {
+		errorPkg.RenderBadRequestError(w, err)
+		return
+	}
+
+	renderResponse(w, r, http.StatusCreated, CreateKeyResponse{
+		Key:    plainKey,
+		ApiKey: key,
+	})
+}
+
+// Handler for listing every API key
+func (ah *AdminHandler) HandleListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := ah.Store.List(r.Context())
+	if err != nil {
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+
+	renderResponse(w, r, http.StatusOK, keys)
+}
+
+// Handler for revoking an API key by id
+func (ah *AdminHandler) HandleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	err := ah.Store.Revoke(r.Context(), id)
+	if errors.Is(err, auth.ErrApiKeyNotFound) {
+		errorPkg.RenderNotFoundError(w, err)
+		return
+	} else if err != nil {
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+	if ah.OnRevoke != nil {
+		ah.OnRevoke(id)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for removing a city from the cache
+func (ah *AdminHandler) HandleInvalidateCache(w http.ResponseWriter, r *http.Request) {
+	city := strings.ToLower(r.URL.Query().Get("city"))
+	if city == "" {
+		errorPkg.RenderBadRequestError(w, errors.New("city is required"))
+		return
+	}
+
+	if err := ah.Service.InvalidateCity(r.Context(), city); err != nil {
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Marshal a value in the negotiated format and write it with the
+// given status. A value the format can't represent, like a list
+// of keys as csv, is a 406, and any other error a general server error.
+func renderResponse(w http.ResponseWriter, r *http.Request, code int, value interface{}) {
+	renderer := render.FromResponseWriter(w)
+	response, err := renderer.Marshal(value)
+	if errors.Is(err, render.ErrUnsupportedValue) {
+		errorPkg.RenderNotAcceptableError(w, err)
+		return
+	} else if err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "Error encoding response", "error", err)
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", renderer.ContentType())
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bengimbel/go_redis_api/internal/alert"
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	DEFAULT_DEAD_LETTERS int = 50
+	MAX_DEAD_LETTERS     int = 1000
+)
+
+type AlertHandler struct {
+	Store alert.StoreImplementor
+}
+
+// Body for creating or replacing an alert rule
+type AlertRuleRequest struct {
+	City       string            `json:"city"`
+	Conditions []alert.Condition `json:"conditions"`
+	Match      string            `json:"match"`
+	WebhookURL string            `json:"webhook_url"`
+}
+
+// Response for a newly created rule. The signing
+// secret is only ever returned here, so it must be saved.
+type CreateAlertRuleResponse struct {
+	Secret string     `json:"secret"`
+	Rule   alert.Rule `json:"rule"`
+}
+
+func NewAlertHandler(store alert.StoreImplementor) *AlertHandler {
+	return &AlertHandler{
+		Store: store,
+	}
+}
+
+// Handler for creating an alert rule
+func (ah *AlertHandler) HandleCreateRule(w http.ResponseWriter, r *http.Request) {
+	body := AlertRuleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		errorPkg.RenderBadRequestError(w, errors.New("invalid request body"))
+		return
+	}
+
+	rule := body.rule("")
+	rule.Owner = ruleOwner(r)
+	rule, err := ah.Store.Create(r.Context(), rule)
+	if err != nil {
+		renderAlertError(w, err)
+		return
+	}
+
+	renderResponse(w, r, http.StatusCreated, CreateAlertRuleResponse{
+		Secret: rule.Secret,
+		Rule:   rule,
+	})
+}
+
+// Handler for listing the caller's alert rules, optionally for one city
+func (ah *AlertHandler) HandleListRules(w http.ResponseWriter, r *http.Request) {
+	var rules []alert.Rule
+	var err error
+	if city := strings.ToLower(r.URL.Query().Get("city")); city != "" {
+		rules, err = ah.Store.ListByCity(r.Context(), city)
+	} else {
+		rules, err = ah.Store.List(r.Context())
+	}
+	if err != nil {
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+
+	owner := ruleOwner(r)
+	owned := []alert.Rule{}
+	for _, rule := range rules {
+		if rule.Owner == owner {
+			owned = append(owned, rule)
+		}
+	}
+
+	renderResponse(w, r, http.StatusOK, owned)
+}
+
+// Handler for getting an alert rule by id
+func (ah *AlertHandler) HandleGetRule(w http.ResponseWriter, r *http.Request) {
+	rule, err := ah.ownedRule(r, chi.URLParam(r, "id"))
+	if err != nil {
+		renderAlertError(w, err)
+		return
+	}
+
+	renderResponse(w, r, http.StatusOK, rule)
+}
+
+// Handler for replacing an alert rule by id
+func (ah *AlertHandler) HandleUpdateRule(w http.ResponseWriter, r *http.Request) {
+	body := AlertRuleRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		errorPkg.RenderBadRequestError(w, errors.New("invalid request body"))
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, err := ah.ownedRule(r, id); err != nil {
+		renderAlertError(w, err)
+		return
+	}
+
+	rule, err := ah.Store.Update(r.Context(), body.rule(id))
+	if err != nil {
+		renderAlertError(w, err)
+		return
+	}
+
+	renderResponse(w, r, http.StatusOK, rule)
+}
+
+// Handler for deleting an alert rule by id
+func (ah *AlertHandler) HandleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := ah.ownedRule(r, id); err != nil {
+		renderAlertError(w, err)
+		return
+	}
+
+	if err := ah.Store.Delete(r.Context(), id); err != nil {
+		renderAlertError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Handler for listing the newest webhook deliveries that
+// failed for the caller's rules, up to ?limit= of them
+func (ah *AlertHandler) HandleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	limit := DEFAULT_DEAD_LETTERS
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MAX_DEAD_LETTERS {
+			errorPkg.RenderBadRequestError(w, errors.New("limit must be between 1 and 1000"))
+			return
+		}
+		limit = n
+	}
+
+	// Other keys' deliveries are in the same list,
+	// so look through all of them before taking the limit
+	deliveries, err := ah.Store.DeadLetters(r.Context(), MAX_DEAD_LETTERS)
+	if err != nil {
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+
+	owner := ruleOwner(r)
+	owned := []alert.Delivery{}
+	for _, delivery := range deliveries {
+		if delivery.Owner == owner && len(owned) < limit {
+			owned = append(owned, delivery)
+		}
+	}
+
+	renderResponse(w, r, http.StatusOK, owned)
+}
+
+// Get a rule if it belongs to the caller. Other keys'
+// rules are reported as not found, so ids don't leak.
+func (ah *AlertHandler) ownedRule(r *http.Request, id string) (alert.Rule, error) {
+	rule, err := ah.Store.Get(r.Context(), id)
+	if err != nil {
+		return alert.Rule{}, err
+	}
+	if rule.Owner != ruleOwner(r) {
+		return alert.Rule{}, alert.ErrRuleNotFound
+	}
+	return rule, nil
+}
+
+// The id of the api key making the request
+func ruleOwner(r *http.Request) string {
+	key, _ := auth.ApiKeyFromContext(r.Context())
+	return key.Id
+}
+
+func (body AlertRuleRequest) rule(id string) alert.Rule {
+	return alert.Rule{
+		Id:         id,
+		City:       body.City,
+		Conditions: body.Conditions,
+		Match:      body.Match,
+		WebhookURL: body.WebhookURL,
+	}
+}
+
+// Render an error from the alert store
+func renderAlertError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, alert.ErrRuleNotFound):
+		errorPkg.RenderNotFoundError(w, err)
+	case errors.Is(err, alert.ErrInvalidRule):
+		errorPkg.RenderBadRequestError(w, err)
+	default:
+		errorPkg.RenderInternalServerError(w, err)
+	}
+}
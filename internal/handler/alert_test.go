@@ -0,0 +1,143 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/alert"
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/internal/handler"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAlertStore struct {
+	alert.StoreImplementor
+	mock.Mock
+}
+
+func (ms *MockAlertStore) Create(ctx context.Context, rule alert.Rule) (alert.Rule, error) {
+	args := ms.Called(ctx, rule)
+	return args.Get(0).(alert.Rule), args.Error(1)
+}
+
+func (ms *MockAlertStore) Get(ctx context.Context, id string) (alert.Rule, error) {
+	args := ms.Called(ctx, id)
+	return args.Get(0).(alert.Rule), args.Error(1)
+}
+
+func (ms *MockAlertStore) List(ctx context.Context) ([]alert.Rule, error) {
+	args := ms.Called(ctx)
+	return args.Get(0).([]alert.Rule), args.Error(1)
+}
+
+func (ms *MockAlertStore) Delete(ctx context.Context, id string) error {
+	args := ms.Called(ctx, id)
+	return args.Error(0)
+}
+
+func newAlertRouter(store *MockAlertStore) http.Handler {
+	alertHandler := handler.NewAlertHandler(store)
+	router := chi.NewRouter()
+	router.Post("/api/alerts", alertHandler.HandleCreateRule)
+	router.Get("/api/alerts", alertHandler.HandleListRules)
+	router.Get("/api/alerts/{id}", alertHandler.HandleGetRule)
+	router.Delete("/api/alerts/{id}", alertHandler.HandleDeleteRule)
+	return router
+}
+
+// A request made with the api key id
+func asKey(req *http.Request, id string) *http.Request {
+	return req.WithContext(auth.WithApiKey(req.Context(), auth.ApiKey{Id: id}))
+}
+
+func TestCreateAlertRuleReturnsSecret(t *testing.T) {
+	store := &MockAlertStore{}
+	created := alert.Rule{
+		Id:         "abc",
+		City:       "chicago",
+		Conditions: []alert.Condition{{Field: alert.FIELD_TEMP, Operator: alert.OPERATOR_LT, Value: 0}},
+		Match:      alert.MATCH_ANY,
+		WebhookURL: "https://example.com/hook",
+		Secret:     "whsec_test",
+	}
+	store.On("Create", mock.Anything, mock.MatchedBy(func(rule alert.Rule) bool {
+		return rule.City == "Chicago" && len(rule.Conditions) == 1 && rule.WebhookURL == created.WebhookURL && rule.Owner == "key1"
+	})).Return(created, nil).Once()
+
+	body := `{"city":"Chicago","conditions":[{"field":"temp","operator":"<","value":0}],"webhook_url":"https://example.com/hook"}`
+	req := asKey(httptest.NewRequest(http.MethodPost, "/api/alerts", strings.NewReader(body)), "key1")
+	rr := httptest.NewRecorder()
+	newAlertRouter(store).ServeHTTP(rr, req)
+
+	response := map[string]interface{}{}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.EqualValues(t, http.StatusCreated, rr.Code)
+	assert.EqualValues(t, "whsec_test", response["secret"])
+	assert.NotContains(t, response["rule"], "secret")
+}
+
+func TestAlertRuleErrors(t *testing.T) {
+	store := &MockAlertStore{}
+	store.On("Create", mock.Anything, mock.Anything).Return(alert.Rule{}, fmt.Errorf("%w: city is required", alert.ErrInvalidRule)).Once()
+	store.On("Get", mock.Anything, "missing").Return(alert.Rule{}, alert.ErrRuleNotFound).Once()
+	store.On("Get", mock.Anything, "broken").Return(alert.Rule{}, errors.New("redis down")).Once()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		code   int
+	}{
+		{"invalid body", http.MethodPost, "/api/alerts", "{", http.StatusBadRequest},
+		{"invalid rule", http.MethodPost, "/api/alerts", "{}", http.StatusBadRequest},
+		{"not found", http.MethodGet, "/api/alerts/missing", "", http.StatusNotFound},
+		{"store error", http.MethodGet, "/api/alerts/broken", "", http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+			newAlertRouter(store).ServeHTTP(rr, req)
+
+			assert.EqualValues(t, test.code, rr.Code)
+		})
+	}
+}
+
+func TestAlertRulesAreScopedToTheirOwner(t *testing.T) {
+	store := &MockAlertStore{}
+	mine := alert.Rule{Id: "mine", Owner: "key1"}
+	theirs := alert.Rule{Id: "theirs", Owner: "key2"}
+	store.On("List", mock.Anything).Return([]alert.Rule{mine, theirs}, nil)
+	store.On("Get", mock.Anything, "mine").Return(mine, nil)
+	store.On("Get", mock.Anything, "theirs").Return(theirs, nil)
+	store.On("Delete", mock.Anything, "mine").Return(nil).Once()
+
+	rr := httptest.NewRecorder()
+	newAlertRouter(store).ServeHTTP(rr, asKey(httptest.NewRequest(http.MethodGet, "/api/alerts", nil), "key1"))
+	rules := []alert.Rule{}
+	json.Unmarshal(rr.Body.Bytes(), &rules)
+	assert.EqualValues(t, []alert.Rule{mine}, rules)
+
+	rr = httptest.NewRecorder()
+	newAlertRouter(store).ServeHTTP(rr, asKey(httptest.NewRequest(http.MethodGet, "/api/alerts/theirs", nil), "key1"))
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	newAlertRouter(store).ServeHTTP(rr, asKey(httptest.NewRequest(http.MethodDelete, "/api/alerts/theirs", nil), "key1"))
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	newAlertRouter(store).ServeHTTP(rr, asKey(httptest.NewRequest(http.MethodDelete, "/api/alerts/mine", nil), "key1"))
+	assert.EqualValues(t, http.StatusNoContent, rr.Code)
+	store.AssertNotCalled(t, "Delete", mock.Anything, "theirs")
+}
@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/view"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	"github.com/bengimbel/go_redis_api/pkg/render"
+	"github.com/bengimbel/go_redis_api/pkg/weatherProto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	CACHE_HIT   string = "HIT"
+	CACHE_MISS  string = "MISS"
+	CACHE_STALE string = "STALE"
+
+	ENVELOPE_PARAM string = "envelope"
+	VIEW_PARAM     string = "view"
+	FIELDS_PARAM   string = "fields"
+	TZ_PARAM       string = "tz"
+	LIVE_PARAM     string = "live"
+
+	VIEW_FULL    string = "full"
+	VIEW_SUMMARY string = "summary"
+)
+
+// Weather wrapped with where it came from and how fresh it is.
+// Sent instead of the bare weather when a client asks for it
+// with ?envelope=true.
+type WeatherEnvelope struct {
+	Data view.Weather `json:"data"`
+	Meta WeatherMeta  `json:"meta"`
+}
+
+// The summary view, or the fields picked from the full
+// view, wrapped with the same metadata as WeatherEnvelope
+type viewEnvelope struct {
+	Data interface{} `json:"data"`
+	Meta WeatherMeta `json:"meta"`
+}
+
+type WeatherMeta struct {
+	// "local", "redis" or "upstream"
+	Source    string    `json:"source"`
+	Provider  string    `json:"provider"`
+	FetchedAt time.Time `json:"fetched_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Stale     bool      `json:"stale"`
+}
+
+// Write the weather in the negotiated format with caching headers: an ETag
+// over the body, Last-Modified from when it was fetched, and a max-age
+// of however long it stays in our cache. X-Cache and X-Cache-Tier
+// say where it came from.
+// A live view changes with the time, so it is sent with no-cache
+// and no Last-Modified, and can only be revalidated by its ETag.
+// If the client already has this version we send a 304 with
+// no body instead.
+func renderCachedWeather(w http.ResponseWriter, r *http.Request, result model.CachedWeather, weatherView weatherView) {
+	// Times relative to now, like until_sunset, only change
+	// once a minute so a live view's ETag does too
+	now := time.Now().Truncate(time.Minute)
+	body, err := weatherView.body(result, now)
+	if err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "Error building weather view", "error", err)
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+
+	// Marshal struct in the negotiated format for the return.
+	// The ETag is over these bytes, so each format has its own.
+	// If error while encoding, render a general server error
+	renderer := render.FromResponseWriter(w)
+	response, err := renderer.Marshal(body)
+	if errors.Is(err, render.ErrUnsupportedValue) {
+		errorPkg.RenderNotAcceptableError(w, err)
+		return
+	} else if err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "Error encoding response", "error", err)
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+
+	etag, err := weatherETag(renderer, result, weatherView, now, response)
+	if err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "Error encoding response", "error", err)
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+	lastModified := result.FetchedAt
+	w.Header().Set("ETag", etag)
+	if weatherView.live {
+		lastModified = time.Time{}
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		maxAge := int(result.RemainingTTL(time.Now()).Seconds())
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(maxAge))
+	}
+	w.Header().Set("X-Cache", cacheStatus(result))
+	w.Header().Set("X-Cache-Tier", result.Source)
+
+	if IsNotModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", renderer.ContentType())
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// The forecast list as csv, without the meta
+func (we WeatherEnvelope) MarshalCSV() ([][]string, error) {
+	return we.Data.MarshalCSV()
+}
+
+// The envelope as a protobuf GetWeatherResponse,
+// the same message the gRPC api returns
+func (we WeatherEnvelope) MarshalProto() ([]byte, error) {
+	return proto.Marshal(&weatherProto.GetWeatherResponse{
+		Weather: we.Data.Model().Proto(),
+		Meta: &weatherProto.Meta{
+			Source:    we.Meta.Source,
+			Provider:  we.Meta.Provider,
+			FetchedAt: timestamppb.New(we.Meta.FetchedAt),
+			ExpiresAt: timestamppb.New(we.Meta.ExpiresAt),
+			Stale:     we.Meta.Stale,
+		},
+	})
+}
+
+// The data as csv when it has a csv form, like the summary
+func (ve viewEnvelope) MarshalCSV() ([][]string, error) {
+	if marshaler, ok := ve.Data.(render.CSVMarshaler); ok {
+		return marshaler.MarshalCSV()
+	}
+	return nil, fmt.Errorf("%w: csv", render.ErrUnsupportedValue)
+}
+
+// Wrap the weather, with its times in zone as of now
+func newWeatherEnvelope(result model.CachedWeather, zone *time.Location, now time.Time) WeatherEnvelope {
+	return WeatherEnvelope{
+		Data: view.NewWeather(result.Weather, zone, now),
+		Meta: newWeatherMeta(result),
+	}
+}
+
+func newWeatherMeta(result model.CachedWeather) WeatherMeta {
+	return WeatherMeta{
+		Source:    result.Source,
+		Provider:  result.Provider,
+		FetchedAt: result.FetchedAt,
+		ExpiresAt: result.ExpiresAt,
+		Stale:     result.Stale,
+	}
+}
+
+// How the client asked to see the weather
+type weatherView struct {
+	summary  bool
+	fields   []string
+	envelope bool
+	// Add is_day and until_sunset as of now
+	live bool
+	// Nil for the city's own offset from UTC
+	zone *time.Location
+}
+
+// Read ?view=, ?fields=, ?tz=, ?live= and ?envelope= up front, so
+// a bad request is turned away before we fetch any weather
+func parseWeatherView(r *http.Request) (weatherView, error) {
+	query := r.URL.Query()
+	weatherView := weatherView{
+		envelope: wantsEnvelope(r),
+	}
+
+	if value := query.Get(LIVE_PARAM); value != "" {
+		live, err := strconv.ParseBool(value)
+		if err != nil {
+			return weatherView, fmt.Errorf("%s must be true or false", LIVE_PARAM)
+		}
+		weatherView.live = live
+	}
+
+	switch query.Get(VIEW_PARAM) {
+	case "", VIEW_FULL:
+	case VIEW_SUMMARY:
+		weatherView.summary = true
+	default:
+		return weatherView, fmt.Errorf("view must be %s or %s", VIEW_FULL, VIEW_SUMMARY)
+	}
+
+	if fields := query.Get(FIELDS_PARAM); fields != "" {
+		if weatherView.summary {
+			return weatherView, errors.New("fields can only be selected from the full view")
+		}
+		selected, err := view.ParseFields(fields, view.Weather{})
+		if err != nil {
+			return weatherView, err
+		}
+		weatherView.fields = selected
+	}
+
+	if tz := query.Get(TZ_PARAM); tz != "" {
+		zone, err := loadTimeZone(tz)
+		if err != nil {
+			return weatherView, err
+		}
+		weatherView.zone = zone
+	}
+
+	return weatherView, nil
+}
+
+// An IANA time zone like America/Denver. Local is
+// refused, since it would be the server's zone.
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	zone, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return zone, nil
+}
+
+// The response body for the view. Only a live view has
+// times as of now, otherwise it depends on the weather alone.
+func (wv weatherView) body(result model.CachedWeather, now time.Time) (interface{}, error) {
+	if !wv.live {
+		now = time.Time{}
+	}
+
+	var data interface{}
+	switch {
+	case wv.summary:
+		data = view.NewSummary(result.Weather.Normalize(result.Provider), wv.zone)
+	case len(wv.fields) > 0:
+		selected, err := view.SelectFields(view.NewWeather(result.Weather, wv.zone, now), wv.fields)
+		if err != nil {
+			return nil, err
+		}
+		data = selected
+	case wv.envelope:
+		return newWeatherEnvelope(result, wv.zone, now), nil
+	default:
+		return view.NewWeather(result.Weather, wv.zone, now), nil
+	}
+
+	if wv.envelope {
+		return viewEnvelope{Data: data, Meta: newWeatherMeta(result)}, nil
+	}
+	return data, nil
+}
+
+// HIT if we had the weather cached, MISS if we had to
+// fetch it, or STALE if we served an old copy
+func cacheStatus(result model.CachedWeather) string {
+	switch {
+	case result.Stale:
+		return CACHE_STALE
+	case result.Source == model.SOURCE_UPSTREAM:
+		return CACHE_MISS
+	default:
+		return CACHE_HIT
+	}
+}
+
+func wantsEnvelope(r *http.Request) bool {
+	envelope, _ := strconv.ParseBool(r.URL.Query().Get(ENVELOPE_PARAM))
+	return envelope
+}
+
+// The ETag for the weather. The envelope's meta.source only says
+// which tier served this copy, so it is left out and the same
+// weather has the same ETag wherever it came from. That makes
+// the envelope's ETag weak, since the bytes can still differ.
+func weatherETag(renderer render.Renderer, result model.CachedWeather, weatherView weatherView, now time.Time, response []byte) (string, error) {
+	if !weatherView.envelope {
+		return ETag(response), nil
+	}
+
+	result.Source = ""
+	body, err := weatherView.body(result, now)
+	if err != nil {
+		return "", err
+	}
+	unsourced, err := renderer.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	return "W/" + ETag(unsourced), nil
+}
+
+// Strong ETag for a response body
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Check the request's conditional headers against our version.
+// If-None-Match wins when both are sent, as RFC 9110 says.
+func IsNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		// Weak comparison is used for If-None-Match, so
+		// neither side's W/ matters
+		etag = strings.TrimPrefix(etag, "W/")
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Without a Last-Modified there is nothing to compare against
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		// Last-Modified only has second precision
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/provider"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+)
+
+const (
+	LOCATION_PARAM string = "location"
+	INDEX_PARAM    string = "index"
+	STATE_PARAM    string = "state"
+	COUNTRY_PARAM  string = "country"
+	LIMIT_PARAM    string = "limit"
+
+	DEFAULT_REVERSE_LIMIT int = 1
+)
+
+type GeoHandler struct {
+	Geo service.GeoServiceImplementor
+}
+
+func NewGeoHandler(geo service.GeoServiceImplementor) *GeoHandler {
+	return &GeoHandler{
+		Geo: geo,
+	}
+}
+
+// Handler for every place matching ?q=, optionally narrowed
+// by ?state= and ?country=. Each has an id that can be
+// passed to the weather api as ?location= to get its weather.
+func (gh *GeoHandler) HandleSearchLocations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		errorPkg.RenderBadRequestError(w, errors.New("q is required"))
+		return
+	}
+	limit, err := parseGeoLimit(query.Get(LIMIT_PARAM), provider.MAX_GEO_RESULTS)
+	if err != nil {
+		errorPkg.RenderBadRequestError(w, err)
+		return
+	}
+
+	locations, err := gh.Geo.SearchLocations(r.Context(), service.NewGeoQuery(q, query.Get(STATE_PARAM), query.Get(COUNTRY_PARAM), limit))
+	if err != nil {
+		renderUpstreamError(w, err)
+		return
+	}
+
+	renderResponse(w, r, http.StatusOK, locations)
+}
+
+// Handler for the places at ?lat= and ?lon=, nearest first
+func (gh *GeoHandler) HandleReverseGeocode(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	lat, err := parseCoordinate(query.Get("lat"), "lat", 90)
+	if err != nil {
+		errorPkg.RenderBadRequestError(w, err)
+		return
+	}
+	lon, err := parseCoordinate(query.Get("lon"), "lon", 180)
+	if err != nil {
+		errorPkg.RenderBadRequestError(w, err)
+		return
+	}
+	limit, err := parseGeoLimit(query.Get(LIMIT_PARAM), DEFAULT_REVERSE_LIMIT)
+	if err != nil {
+		errorPkg.RenderBadRequestError(w, err)
+		return
+	}
+
+	locations, err := gh.Geo.ReverseGeocode(r.Context(), lat, lon, limit)
+	if err != nil {
+		renderUpstreamError(w, err)
+		return
+	}
+
+	renderResponse(w, r, http.StatusOK, locations)
+}
+
+// Pick the city the weather handlers were asked for, rendering
+// an error and returning false if it can't be worked out.
+// A ?location= id is used as is. Otherwise the ?city= name is
+// used, unless ?index=, ?state= or ?country= ask for one of the
+// places with that name, which are looked up to find its id.
+func (wh *WeatherHandler) resolveCity(w http.ResponseWriter, r *http.Request) (string, bool) {
+	query := r.URL.Query()
+	if location := query.Get(LOCATION_PARAM); location != "" {
+		lat, lon, ok := model.ParseLocationId(location)
+		if !ok {
+			errorPkg.RenderBadRequestError(w, fmt.Errorf("location must be an id from /api/geo, like 45.5152,-122.6784"))
+			return "", false
+		}
+		return model.LocationId(lat, lon), true
+	}
+
+	city := strings.ToLower(query.Get("city"))
+	if city == "" {
+		errorPkg.RenderBadRequestError(w, errors.New("city or location is required"))
+		return "", false
+	}
+	if !query.Has(INDEX_PARAM) && !query.Has(STATE_PARAM) && !query.Has(COUNTRY_PARAM) {
+		return city, true
+	}
+
+	index := 0
+	if value := query.Get(INDEX_PARAM); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n >= provider.MAX_GEO_RESULTS {
+			errorPkg.RenderBadRequestError(w, fmt.Errorf("index must be between 0 and %d", provider.MAX_GEO_RESULTS-1))
+			return "", false
+		}
+		index = n
+	}
+	if wh.Geo == nil {
+		errorPkg.RenderBadRequestError(w, errors.New("the weather provider can't tell places apart, use location instead"))
+		return "", false
+	}
+
+	locations, err := wh.Geo.SearchLocations(r.Context(), service.NewGeoQuery(city, query.Get(STATE_PARAM), query.Get(COUNTRY_PARAM), provider.MAX_GEO_RESULTS))
+	if err != nil {
+		renderUpstreamError(w, err)
+		return "", false
+	}
+	if index >= len(locations) {
+		errorPkg.RenderNotFoundError(w, fmt.Errorf("%d places match %s, there is no index %d", len(locations), city, index))
+		return "", false
+	}
+
+	return locations[index].Id, true
+}
+
+func parseGeoLimit(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > provider.MAX_GEO_RESULTS {
+		return 0, fmt.Errorf("limit must be between 1 and %d", provider.MAX_GEO_RESULTS)
+	}
+	return n, nil
+}
+
+// A latitude or longitude, within plus or minus max degrees
+func parseCoordinate(value string, name string, max float64) (float64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("%s is required", name)
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || !(n >= -max && n <= max) {
+		return 0, fmt.Errorf("%s must be between %g and %g", name, -max, max)
+	}
+	return n, nil
+}
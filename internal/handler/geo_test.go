@@ -0,0 +1,170 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/handler"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockGeoService struct {
+	mock.Mock
+}
+
+func (mgs *MockGeoService) SearchLocations(ctx context.Context, query service.GeoQuery) ([]model.GeoLocation, error) {
+	args := mgs.Called(ctx, query)
+	locations, _ := args.Get(0).([]model.GeoLocation)
+	return locations, args.Error(1)
+}
+
+func (mgs *MockGeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64, limit int) ([]model.GeoLocation, error) {
+	args := mgs.Called(ctx, lat, lon, limit)
+	locations, _ := args.Get(0).([]model.GeoLocation)
+	return locations, args.Error(1)
+}
+
+var parisLocations = []model.GeoLocation{
+	model.NewGeoLocation(model.WeatherCoordinates{Name: "Paris", Country: "FR", Lat: 48.8589, Lon: 2.32}),
+	model.NewGeoLocation(model.WeatherCoordinates{Name: "Paris", State: "Texas", Country: "US", Lat: 33.6609, Lon: -95.5555}),
+}
+
+func TestSearchLocations(t *testing.T) {
+	geo := new(MockGeoService)
+	geoHandler := handler.NewGeoHandler(geo)
+	geo.On("SearchLocations", mock.Anything, service.GeoQuery{Query: "paris", Country: "us", Limit: 5}).Return(parisLocations[1:], nil)
+
+	rr := httptest.NewRecorder()
+	geoHandler.HandleSearchLocations(rr, httptest.NewRequest(http.MethodGet, "/api/geo/search?q=paris&country=us", nil))
+
+	actual := []model.GeoLocation{}
+	json.Unmarshal(rr.Body.Bytes(), &actual)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, parisLocations[1:], actual)
+}
+
+func TestSearchLocationsStateDefaultsToUS(t *testing.T) {
+	geo := new(MockGeoService)
+	geoHandler := handler.NewGeoHandler(geo)
+	weatherHandler := handler.NewWeatherHandler(&MockService{})
+	weatherHandler.Geo = geo
+	// Without a country, "portland,or" would be read as Oregon the country
+	geo.On("SearchLocations", mock.Anything, service.GeoQuery{Query: "portland", State: "or", Country: "us", Limit: 5}).Return([]model.GeoLocation{}, nil).Twice()
+
+	rr := httptest.NewRecorder()
+	geoHandler.HandleSearchLocations(rr, httptest.NewRequest(http.MethodGet, "/api/geo/search?q=portland&state=or", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	weatherHandler.HandleRetrieveCachedWeather(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=portland&state=or", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	geo.AssertExpectations(t)
+}
+
+func TestSearchLocationsValidatesQuery(t *testing.T) {
+	geoHandler := handler.NewGeoHandler(new(MockGeoService))
+
+	for _, target := range []string{
+		"/api/geo/search",
+		"/api/geo/search?q=paris&limit=0",
+		"/api/geo/search?q=paris&limit=6",
+		"/api/geo/reverse?lat=48.8",
+		"/api/geo/reverse?lat=91&lon=2.3",
+		"/api/geo/reverse?lat=NaN&lon=2.3",
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if req.URL.Path == "/api/geo/search" {
+			geoHandler.HandleSearchLocations(rr, req)
+		} else {
+			geoHandler.HandleReverseGeocode(rr, req)
+		}
+		assert.Equal(t, http.StatusBadRequest, rr.Code, target)
+	}
+}
+
+func TestReverseGeocode(t *testing.T) {
+	geo := new(MockGeoService)
+	geoHandler := handler.NewGeoHandler(geo)
+	geo.On("ReverseGeocode", mock.Anything, 48.8589, 2.32, 1).Return(parisLocations[:1], nil)
+
+	rr := httptest.NewRecorder()
+	geoHandler.HandleReverseGeocode(rr, httptest.NewRequest(http.MethodGet, "/api/geo/reverse?lat=48.8589&lon=2.32", nil))
+
+	actual := []model.GeoLocation{}
+	json.Unmarshal(rr.Body.Bytes(), &actual)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "48.8589,2.3200", actual[0].Id)
+}
+
+func TestFetchWeatherByLocation(t *testing.T) {
+	service := &MockService{}
+	weatherHandler := handler.NewWeatherHandler(service)
+	weather := model.WeatherResponse{City: model.City{Name: "Paris"}}
+
+	service.On("DoesKeyExist", mock.Anything, "33.6609,-95.5555").Return(true).Once()
+	service.On("RetrieveWeatherFromCache", mock.Anything, "33.6609,-95.5555").Return(cachedWeather(weather), nil).Once()
+	service.On("RecordCityRequest", mock.Anything, "33.6609,-95.5555").Return(nil).Once()
+
+	rr := httptest.NewRecorder()
+	weatherHandler.HandleRetrieveWeather(rr, httptest.NewRequest(http.MethodGet, "/api/weather?location=33.66090,-95.5555", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	service.AssertExpectations(t)
+}
+
+func TestFetchWeatherByIndex(t *testing.T) {
+	service := &MockService{}
+	geo := new(MockGeoService)
+	weatherHandler := handler.NewWeatherHandler(service)
+	weatherHandler.Geo = geo
+	weather := model.WeatherResponse{City: model.City{Name: "Paris"}}
+
+	geo.On("SearchLocations", mock.Anything, mock.Anything).Return(parisLocations, nil)
+	service.On("RetrieveWeatherFromCache", mock.Anything, "33.6609,-95.5555").Return(cachedWeather(weather), nil).Once()
+
+	rr := httptest.NewRecorder()
+	weatherHandler.HandleRetrieveCachedWeather(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=Paris&index=1", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	weatherHandler.HandleRetrieveCachedWeather(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=Paris&index=2", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	service.AssertExpectations(t)
+}
+
+func TestFetchWeatherRejectsBadLocation(t *testing.T) {
+	weatherHandler := handler.NewWeatherHandler(&MockService{})
+
+	for _, target := range []string{
+		"/api/weather?location=paris",
+		"/api/weather?location=91,0",
+		"/api/weather?city=paris&index=-1",
+		// Without a geocoder we can't pick one of the matches
+		"/api/weather?city=paris&index=1",
+	} {
+		rr := httptest.NewRecorder()
+		weatherHandler.HandleRetrieveWeather(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, target)
+	}
+}
+
+func TestFetchWeatherByIndexGeocodingFails(t *testing.T) {
+	geo := new(MockGeoService)
+	weatherHandler := handler.NewWeatherHandler(&MockService{})
+	weatherHandler.Geo = geo
+	geo.On("SearchLocations", mock.Anything, mock.Anything).Return(nil, errors.New("upstream failed"))
+
+	rr := httptest.NewRecorder()
+	weatherHandler.HandleRetrieveWeather(rr, httptest.NewRequest(http.MethodGet, "/api/weather?city=paris&country=fr", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "upstream failed")
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bengimbel/go_redis_api/internal/graph"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+)
+
+type GraphQLHandler struct {
+	GraphQL graph.GraphQLImplementor
+}
+
+func NewGraphQLHandler(g graph.GraphQLImplementor) *GraphQLHandler {
+	return &GraphQLHandler{
+		GraphQL: g,
+	}
+}
+
+// Handler for graphql queries, sent as a json body in a POST or
+// as query params in a GET. Errors resolving the query are
+// returned in the response's errors with a 200, as graphql clients expect.
+func (gh *GraphQLHandler) HandleQuery(w http.ResponseWriter, r *http.Request) {
+	req := graph.Request{}
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				errorPkg.RenderBadRequestError(w, errors.New("invalid graphql variables"))
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorPkg.RenderBadRequestError(w, errors.New("invalid graphql request body"))
+		return
+	}
+	if req.Query == "" {
+		errorPkg.RenderBadRequestError(w, errors.New("graphql query is required"))
+		return
+	}
+
+	renderResponse(w, r, http.StatusOK, gh.GraphQL.Exec(r.Context(), req))
+}
@@ -0,0 +1,56 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/graph"
+	"github.com/bengimbel/go_redis_api/internal/handler"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockGraphQL struct {
+	mock.Mock
+}
+
+func (mg *MockGraphQL) Exec(ctx context.Context, req graph.Request) *graphql.Response {
+	args := mg.Called(ctx, req)
+	return args.Get(0).(*graphql.Response)
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	mockGraphQL := &MockGraphQL{}
+	graphQLHandler := handler.NewGraphQLHandler(mockGraphQL)
+	query := `{ weather(city: "chicago") { city { name } } }`
+	response := &graphql.Response{Data: []byte(`{"weather":{"city":{"name":"chicago"}}}`)}
+
+	mockGraphQL.On("Exec", mock.Anything, graph.Request{Query: query}).Return(response).Twice()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ weather(city: \"chicago\") { city { name } } }"}`))
+	graphQLHandler.HandleQuery(rr, req)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"data":{"weather":{"city":{"name":"chicago"}}}}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/graphql?query="+strings.ReplaceAll(query, " ", "+"), nil)
+	graphQLHandler.HandleQuery(rr, req)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+}
+
+func TestGraphQLInvalidRequest(t *testing.T) {
+	graphQLHandler := handler.NewGraphQLHandler(&MockGraphQL{})
+
+	rr := httptest.NewRecorder()
+	graphQLHandler.HandleQuery(rr, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader("not json")))
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	graphQLHandler.HandleQuery(rr, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":""}`)))
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+}
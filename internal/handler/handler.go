@@ -1,87 +1,113 @@
 package handler
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
 	"net/http"
-	"strings"
+	"time"
 
-	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/repository"
 	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/internal/tracing"
 	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
-	"github.com/redis/go-redis/v9"
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	REDIS_RETRY_AFTER time.Duration = 5 * time.Second
 )
 
 type WeatherHandler struct {
 	Service service.WeatherServiceImplementor
+	// Optional, used to pick one of the places a
+	// city name matches with ?index=
+	Geo service.GeoServiceImplementor
 }
 
-func NewWeatherHandler(rds *redis.Client) *WeatherHandler {
+func NewWeatherHandler(svc service.WeatherServiceImplementor) *WeatherHandler {
 	return &WeatherHandler{
-		Service: service.NewWeatherService(rds),
+		Service: svc,
 	}
 }
 
 // Handler for fetching weather from open weather map API.
 func (wh *WeatherHandler) HandleRetrieveWeather(w http.ResponseWriter, r *http.Request) {
-	city := strings.ToLower(r.URL.Query().Get("city"))
-	ctx := r.Context()
-	var result model.WeatherResponse
-
-	// Check the cache before fetching
-	keyExists := wh.Service.DoesKeyExist(ctx, city)
-	if keyExists {
-		value, err := wh.Service.RetrieveWeatherFromCache(ctx, city)
-		if err != nil {
-			errorPkg.RenderInternalServerError(w, err)
-			return
-		}
-		result = value
-	} else {
-		value, err := wh.Service.RetrieveAndCacheWeatherAsync(ctx, city)
-		if err != nil {
-			errorPkg.RenderBadRequestError(w, err)
-			return
-		}
-		result = value
+	weatherView, err := parseWeatherView(r)
+	if err != nil {
+		errorPkg.RenderBadRequestError(w, err)
+		return
 	}
-	// Marshal struct to json for the return.
-	// If error while decoding to json,
-	// render a general server error
-	response, err := json.Marshal(&result)
+	city, ok := wh.resolveCity(w, r)
+	if !ok {
+		return
+	}
+	ctx, span := tracing.Tracer().Start(r.Context(), "WeatherHandler.HandleRetrieveWeather",
+		trace.WithAttributes(attribute.String("weather.city", city)),
+	)
+	defer span.End()
+
+	result, err := service.RetrieveWeather(ctx, wh.Service, city)
 	if err != nil {
-		log.Println("Error decoding response to json", err)
-		errorPkg.RenderInternalServerError(w, err)
+		renderUpstreamError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(response)
-	w.WriteHeader(http.StatusOK)
+	renderCachedWeather(w, r, result, weatherView)
 }
 
 func (wh *WeatherHandler) HandleRetrieveCachedWeather(w http.ResponseWriter, r *http.Request) {
-	city := strings.ToLower(r.URL.Query().Get("city"))
-	ctx := r.Context()
+	weatherView, err := parseWeatherView(r)
+	if err != nil {
+		errorPkg.RenderBadRequestError(w, err)
+		return
+	}
+	city, ok := wh.resolveCity(w, r)
+	if !ok {
+		return
+	}
+	ctx, span := tracing.Tracer().Start(r.Context(), "WeatherHandler.HandleRetrieveCachedWeather",
+		trace.WithAttributes(attribute.String("weather.city", city)),
+	)
+	defer span.End()
 
-	// Get results from redis cache
+	// Get results from redis cache. If redis is down
+	// and the city isn't cached locally, say so rather
+	// than claiming the city isn't cached.
 	result, err := wh.Service.RetrieveWeatherFromCache(ctx, city)
-	if err != nil {
+	if errors.Is(err, repository.ErrRedisUnavailable) {
+		errorPkg.RenderServiceUnavailableError(w, err, REDIS_RETRY_AFTER)
+		return
+	} else if err != nil {
 		errorPkg.RenderBadRequestError(w, err)
 		return
 	}
 
-	// Marshal struct to json for the return.
-	// If error while decoding to json,
-	// render a general server error
-	response, err := json.Marshal(result)
-	if err != nil {
-		log.Println("Error decoding response to json", err)
-		errorPkg.RenderInternalServerError(w, err)
+	renderCachedWeather(w, r, result, weatherView)
+}
+
+// Render an error from fetching upstream weather.
+// If we are out of upstream quota tell the client when
+// to retry: a 429 for the per minute rate, or a 503
+// when the daily quota is gone. A request that ran out
+// of time is a 504, like the timeout middleware's.
+func renderUpstreamError(w http.ResponseWriter, err error) {
+	// The route's timeout ran out while we were fetching
+	if errors.Is(err, context.DeadlineExceeded) {
+		errorPkg.RenderGatewayTimeoutError(w, errors.New("request timed out"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+	var quotaErr *httpClient.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		errorPkg.RenderBadRequestError(w, err)
+		return
+	}
+
+	if quotaErr.Reason == httpClient.QUOTA_REASON_DAILY {
+		errorPkg.RenderServiceUnavailableError(w, err, quotaErr.RetryAfter)
+		return
+	}
+	errorPkg.RenderTooManyRequestsError(w, err, quotaErr.RetryAfter)
 }
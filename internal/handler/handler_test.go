@@ -4,19 +4,26 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bengimbel/go_redis_api/internal/handler"
+	"github.com/bengimbel/go_redis_api/internal/middleware"
 	"github.com/bengimbel/go_redis_api/internal/model"
 	"github.com/bengimbel/go_redis_api/internal/repository"
 	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
 	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+	"github.com/bengimbel/go_redis_api/pkg/render"
+	"github.com/bengimbel/go_redis_api/pkg/weatherProto"
 	"github.com/go-redis/cache/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/protobuf/proto"
 )
 
 type MockHttpClient struct {
@@ -40,33 +47,48 @@ type MockWeatherHandler struct {
 	Service *MockService
 }
 
-func (ms *MockService) FetchCoordinates(config *httpClient.HttpConfig) ([]model.WeatherCoordinates, error) {
-	args := ms.Called(config)
-	return args.Get(0).([]model.WeatherCoordinates), args.Error(1)
-}
-func (ms *MockService) FetchWeatherByCity(config *httpClient.HttpConfig) (model.WeatherResponse, error) {
-	args := ms.Called(config)
-	return args.Get(0).(model.WeatherResponse), args.Error(1)
-}
-func (ms *MockService) RetrieveAndCacheWeatherAsync(ctx context.Context, city string) (model.WeatherResponse, error) {
+func (ms *MockService) RetrieveAndCacheWeatherAsync(ctx context.Context, city string) (model.CachedWeather, error) {
 	args := ms.Called(ctx, city)
-	return args.Get(0).(model.WeatherResponse), args.Error(1)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
 }
-func (ms *MockService) RetrieveWeatherFromCache(ctx context.Context, city string) (model.WeatherResponse, error) {
+func (ms *MockService) RetrieveWeatherFromCache(ctx context.Context, city string) (model.CachedWeather, error) {
 	args := ms.Called(ctx, city)
-	return args.Get(0).(model.WeatherResponse), args.Error(1)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
 }
 func (ms *MockService) DoesKeyExist(ctx context.Context, city string) bool {
 	args := ms.Called(ctx, city)
 	return args.Bool(0)
 }
-func (ms *MockService) InsertToCacheAsync(ctx context.Context, city string, weatherResponse model.WeatherResponse) error {
+func (ms *MockService) InsertToCacheAsync(ctx context.Context, city string, weatherResponse model.CachedWeather) error {
 	args := ms.Called(ctx, city, weatherResponse)
 	return args.Error(0)
 }
 
+func (ms *MockService) RecordCityRequest(ctx context.Context, city string) error {
+	args := ms.Called(ctx, city)
+	return args.Error(0)
+}
+func (ms *MockService) PopularCities(ctx context.Context, n int) ([]string, error) {
+	args := ms.Called(ctx, n)
+	return args.Get(0).([]string), args.Error(1)
+}
+func (ms *MockService) RefreshWeather(ctx context.Context, city string) (model.CachedWeather, error) {
+	args := ms.Called(ctx, city)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
+}
+
+func (ms *MockService) InvalidateCity(ctx context.Context, city string) error {
+	args := ms.Called(ctx, city)
+	return args.Error(0)
+}
+
 var mockService = &MockService{}
 
+// Wrap weather as if it was just fetched
+func cachedWeather(weather model.WeatherResponse) model.CachedWeather {
+	return model.NewCachedWeather(weather, "mock", time.Now(), repository.CACHE_TTL)
+}
+
 var mockWeatherHandler = handler.WeatherHandler{
 	Service: mockService,
 }
@@ -74,7 +96,8 @@ var mockWeatherHandler = handler.WeatherHandler{
 func TestFetchWeatherFromApiSuccess(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/api/weather?city=chicago", nil)
 	rr := httptest.NewRecorder()
-	ctx := context.Background()
+	// Handlers start a span, so the context passed on is a child of the request context
+	ctx := mock.Anything
 	expected := model.WeatherResponse{
 		City: model.City{
 			Name: "chicago",
@@ -87,7 +110,8 @@ func TestFetchWeatherFromApiSuccess(t *testing.T) {
 	}
 
 	mockService.On("DoesKeyExist", ctx, "chicago").Return(false).Once()
-	mockService.On("RetrieveAndCacheWeatherAsync", ctx, "chicago").Return(expected, nil).Once()
+	mockService.On("RetrieveAndCacheWeatherAsync", ctx, "chicago").Return(cachedWeather(expected), nil).Once()
+	mockService.On("RecordCityRequest", ctx, "chicago").Return(nil).Once()
 
 	handler := http.HandlerFunc(mockWeatherHandler.HandleRetrieveWeather)
 	handler.ServeHTTP(rr, req)
@@ -103,14 +127,14 @@ func TestFetchWeatherFromApiSuccess(t *testing.T) {
 func TestFetchWeatherFromApiFailure(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/api/weather?city=unkowncity", nil)
 	rr := httptest.NewRecorder()
-	ctx := context.Background()
+	ctx := mock.Anything
 	errorString := "Error fetching coordinates. Check if API key is valid."
 	expectedError := errors.New(errorString)
 	expected := errorPkg.Error{
 		Code:    400,
 		Message: errorString,
 	}
-	emptyWeather := model.WeatherResponse{}
+	emptyWeather := model.CachedWeather{}
 
 	mockService.On("DoesKeyExist", ctx, "unkowncity").Return(false).Once()
 	mockService.On("RetrieveAndCacheWeatherAsync", ctx, "unkowncity").Return(emptyWeather, expectedError).Once()
@@ -125,3 +149,333 @@ func TestFetchWeatherFromApiFailure(t *testing.T) {
 	assert.EqualValues(t, expected, actual)
 	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
 }
+
+func TestFetchWeatherFromApiTimeout(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/weather?city=slowcity", nil)
+	rr := httptest.NewRecorder()
+	ctx := mock.Anything
+	timeoutErr := fmt.Errorf("Error fetching coordinates: %w", context.DeadlineExceeded)
+
+	mockService.On("DoesKeyExist", ctx, "slowcity").Return(false).Once()
+	mockService.On("RetrieveAndCacheWeatherAsync", ctx, "slowcity").Return(model.CachedWeather{}, timeoutErr).Once()
+
+	http.HandlerFunc(mockWeatherHandler.HandleRetrieveWeather).ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusGatewayTimeout, rr.Code)
+}
+
+func TestFetchWeatherRecordsCityRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/weather?city=Miami", nil)
+	rr := httptest.NewRecorder()
+	ctx := mock.Anything
+	cached := model.WeatherResponse{
+		City: model.City{
+			Name: "miami",
+		},
+	}
+
+	mockService.On("DoesKeyExist", ctx, "miami").Return(true).Once()
+	mockService.On("RetrieveWeatherFromCache", ctx, "miami").Return(cachedWeather(cached), nil).Once()
+	mockService.On("RecordCityRequest", ctx, "miami").Return(errors.New("redis down")).Once()
+
+	handler := http.HandlerFunc(mockWeatherHandler.HandleRetrieveWeather)
+	handler.ServeHTTP(rr, req)
+
+	mockService.AssertCalled(t, "RecordCityRequest", ctx, "miami")
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+}
+
+func TestFetchWeatherFromApiQuotaExceeded(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/weather?city=boston", nil)
+	rr := httptest.NewRecorder()
+	ctx := mock.Anything
+	quotaErr := &httpClient.QuotaExceededError{
+		Reason:     httpClient.QUOTA_REASON_RATE,
+		RetryAfter: 1500 * time.Millisecond,
+	}
+	emptyWeather := model.CachedWeather{}
+
+	mockService.On("DoesKeyExist", ctx, "boston").Return(false).Once()
+	mockService.On("RetrieveAndCacheWeatherAsync", ctx, "boston").Return(emptyWeather, quotaErr).Once()
+
+	handler := http.HandlerFunc(mockWeatherHandler.HandleRetrieveWeather)
+	handler.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusTooManyRequests, rr.Code)
+	assert.EqualValues(t, "2", rr.Header().Get("Retry-After"))
+}
+
+func TestInvalidateCache(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/cache?city=Chicago", nil)
+	rr := httptest.NewRecorder()
+	ctx := context.Background()
+	adminHandler := handler.AdminHandler{
+		Service: mockService,
+	}
+
+	mockService.On("InvalidateCity", ctx, "chicago").Return(nil).Once()
+
+	handler := http.HandlerFunc(adminHandler.HandleInvalidateCache)
+	handler.ServeHTTP(rr, req)
+
+	mockService.AssertCalled(t, "InvalidateCity", ctx, "chicago")
+	assert.EqualValues(t, http.StatusNoContent, rr.Code)
+}
+
+func TestFetchWeatherBypassesCacheWhenRedisFails(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/weather?city=austin", nil)
+	rr := httptest.NewRecorder()
+	ctx := mock.Anything
+	expected := model.WeatherResponse{
+		City: model.City{
+			Name: "austin",
+		},
+	}
+
+	mockService.On("DoesKeyExist", ctx, "austin").Return(true).Once()
+	mockService.On("RetrieveWeatherFromCache", ctx, "austin").Return(model.CachedWeather{}, repository.ErrRedisUnavailable).Once()
+	mockService.On("RetrieveAndCacheWeatherAsync", ctx, "austin").Return(cachedWeather(expected), nil).Once()
+	mockService.On("RecordCityRequest", ctx, "austin").Return(nil).Once()
+
+	handler := http.HandlerFunc(mockWeatherHandler.HandleRetrieveWeather)
+	handler.ServeHTTP(rr, req)
+
+	actual := model.WeatherResponse{}
+	json.NewDecoder(rr.Body).Decode(&actual)
+
+	assert.EqualValues(t, expected, actual)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+}
+
+func TestFetchCachedWeatherRedisUnavailable(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=austin", nil)
+	rr := httptest.NewRecorder()
+	ctx := mock.Anything
+
+	mockService.On("RetrieveWeatherFromCache", ctx, "austin").Return(model.CachedWeather{}, repository.ErrRedisUnavailable).Once()
+
+	handler := http.HandlerFunc(mockWeatherHandler.HandleRetrieveCachedWeather)
+	handler.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusServiceUnavailable, rr.Code)
+	assert.EqualValues(t, "5", rr.Header().Get("Retry-After"))
+}
+
+func TestFetchCachedWeatherCachingHeaders(t *testing.T) {
+	ctx := mock.Anything
+	fetchedAt := time.Now().Add(-4 * time.Minute)
+	cached := model.NewCachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "tulsa",
+		},
+	}, "mock", fetchedAt, 10*time.Minute)
+	handler := http.HandlerFunc(mockWeatherHandler.HandleRetrieveCachedWeather)
+
+	mockService.On("RetrieveWeatherFromCache", ctx, "tulsa").Return(cached, nil).Times(3)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=tulsa", nil)
+	handler.ServeHTTP(rr, req)
+
+	etag := rr.Header().Get("ETag")
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, etag)
+	assert.EqualValues(t, fetchedAt.UTC().Format(http.TimeFormat), rr.Header().Get("Last-Modified"))
+	assert.Contains(t, []string{"max-age=359", "max-age=360"}, rr.Header().Get("Cache-Control"))
+
+	// Same version by ETag
+	rr = httptest.NewRecorder()
+	req.Header.Set("If-None-Match", etag)
+	handler.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.Bytes())
+
+	// Same version by date
+	rr = httptest.NewRecorder()
+	req.Header.Del("If-None-Match")
+	req.Header.Set("If-Modified-Since", fetchedAt.UTC().Format(http.TimeFormat))
+	handler.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusNotModified, rr.Code)
+}
+
+func TestFetchCachedWeatherMetadata(t *testing.T) {
+	ctx := mock.Anything
+	cached := cachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "reno",
+		},
+	})
+	cached.Source = model.SOURCE_LOCAL
+	stale := cachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "provo",
+		},
+	})
+	stale.Source = model.SOURCE_REDIS
+	stale.Stale = true
+	h := http.HandlerFunc(mockWeatherHandler.HandleRetrieveCachedWeather)
+
+	mockService.On("RetrieveWeatherFromCache", ctx, "reno").Return(cached, nil).Twice()
+	mockService.On("RetrieveWeatherFromCache", ctx, "provo").Return(stale, nil).Once()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=reno", nil))
+	assert.EqualValues(t, "HIT", rr.Header().Get("X-Cache"))
+	assert.EqualValues(t, "local", rr.Header().Get("X-Cache-Tier"))
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=provo", nil))
+	assert.EqualValues(t, "STALE", rr.Header().Get("X-Cache"))
+	assert.EqualValues(t, "redis", rr.Header().Get("X-Cache-Tier"))
+
+	// Same weather wrapped with its metadata
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=reno&envelope=true", nil))
+	var envelope handler.WeatherEnvelope
+	err := json.Unmarshal(rr.Body.Bytes(), &envelope)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "reno", envelope.Data.City.Name)
+	assert.EqualValues(t, "local", envelope.Meta.Source)
+	assert.EqualValues(t, "mock", envelope.Meta.Provider)
+	assert.False(t, envelope.Meta.Stale)
+	assert.True(t, envelope.Meta.ExpiresAt.After(envelope.Meta.FetchedAt))
+}
+
+func TestFetchCachedWeatherLiveTimes(t *testing.T) {
+	ctx := mock.Anything
+	now := time.Now()
+	cached := model.NewCachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name:    "tempe",
+			Sunrise: now.Add(-time.Hour).Unix(),
+			Sunset:  now.Add(time.Hour).Unix(),
+		},
+	}, "mock", now.Add(-time.Minute), 10*time.Minute)
+	handler := http.HandlerFunc(mockWeatherHandler.HandleRetrieveCachedWeather)
+
+	mockService.On("RetrieveWeatherFromCache", ctx, "tempe").Return(cached, nil).Twice()
+
+	// By default the body only depends on the cached weather
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=tempe", nil))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "is_day")
+	assert.NotContains(t, rr.Body.String(), "until_sunset")
+	assert.Contains(t, rr.Header().Get("Cache-Control"), "max-age=")
+
+	// Live times aren't cached or revalidated by date
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=tempe&live=true", nil)
+	req.Header.Set("If-Modified-Since", now.UTC().Format(http.TimeFormat))
+	handler.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"is_day":true`)
+	assert.Contains(t, rr.Body.String(), "until_sunset")
+	assert.EqualValues(t, "no-cache", rr.Header().Get("Cache-Control"))
+	assert.Empty(t, rr.Header().Get("Last-Modified"))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=tempe&live=maybe", nil))
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestIsNotModified(t *testing.T) {
+	lastModified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	req := httptest.NewRequest(http.MethodGet, "/api/weather?city=tulsa", nil)
+
+	// A different ETag wins over a matching date
+	req.Header.Set("If-None-Match", `"other"`)
+	req.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+	assert.False(t, handler.IsNotModified(req, `"abc"`, lastModified))
+
+	req.Header.Set("If-None-Match", `"other", W/"abc"`)
+	assert.True(t, handler.IsNotModified(req, `"abc"`, lastModified))
+
+	// Modified since the client's copy
+	req.Header.Del("If-None-Match")
+	assert.False(t, handler.IsNotModified(req, `"abc"`, lastModified.Add(time.Second)))
+}
+
+func TestFetchCachedWeatherFormats(t *testing.T) {
+	ctx := mock.Anything
+	cached := cachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name:    "fargo",
+			Country: "US",
+		},
+		List: []model.List{
+			{
+				Dt:      1704067200,
+				Main:    model.Main{Temp: 260.5, Humidity: 80},
+				Weather: []model.Weather{{Main: "Snow", Description: "light snow", Icon: "13d"}},
+				Pop:     0.4,
+				DtTxt:   "2024-01-01 00:00:00",
+			},
+		},
+	})
+	cached.Source = model.SOURCE_REDIS
+	h := middleware.Negotiate(render.NewDefaultRegistry())(http.HandlerFunc(mockWeatherHandler.HandleRetrieveCachedWeather))
+
+	mockService.On("RetrieveWeatherFromCache", ctx, "fargo").Return(cached, nil).Times(3)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=fargo&format=csv", nil))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "text/csv", rr.Header().Get("Content-Type"))
+	assert.EqualValues(t, "city,country,dt,dt_txt,temp,feels_like,temp_min,temp_max,pressure,humidity,condition,description,icon,clouds,wind_speed,wind_deg,wind_gust,visibility,pop\n"+
+		"fargo,US,1704067200,2024-01-01 00:00:00,260.5,0,0,0,0,80,Snow,light snow,13d,0,0,0,0,0,0.4\n", rr.Body.String())
+	csvETag := rr.Header().Get("ETag")
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=fargo", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	h.ServeHTTP(rr, req)
+	weather := &weatherProto.WeatherResponse{}
+	assert.Nil(t, proto.Unmarshal(rr.Body.Bytes(), weather))
+	assert.EqualValues(t, "application/x-protobuf", rr.Header().Get("Content-Type"))
+	assert.EqualValues(t, "fargo", weather.GetCity().GetName())
+	assert.EqualValues(t, "light snow", weather.GetList()[0].GetWeather()[0].GetDescription())
+	// Each format is its own version
+	assert.NotEqual(t, csvETag, rr.Header().Get("ETag"))
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=fargo&envelope=true", nil)
+	req.Header.Set("Accept", "application/x-protobuf")
+	h.ServeHTTP(rr, req)
+	envelope := &weatherProto.GetWeatherResponse{}
+	assert.Nil(t, proto.Unmarshal(rr.Body.Bytes(), envelope))
+	assert.EqualValues(t, "fargo", envelope.GetWeather().GetCity().GetName())
+	assert.EqualValues(t, "redis", envelope.GetMeta().GetSource())
+}
+
+func TestFetchCachedWeatherEnvelopeETagIgnoresSource(t *testing.T) {
+	ctx := mock.Anything
+	local := cachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "boise",
+		},
+	})
+	local.Source = model.SOURCE_LOCAL
+	fromRedis := local
+	fromRedis.Source = model.SOURCE_REDIS
+	h := http.HandlerFunc(mockWeatherHandler.HandleRetrieveCachedWeather)
+
+	mockService.On("RetrieveWeatherFromCache", ctx, "boise").Return(local, nil).Once()
+	mockService.On("RetrieveWeatherFromCache", ctx, "boise").Return(fromRedis, nil).Twice()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=boise&envelope=true", nil))
+	etag := rr.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, "W/"))
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=boise&envelope=true", nil))
+	assert.EqualValues(t, etag, rr.Header().Get("ETag"))
+
+	// The weak ETag sent back revalidates
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather/cached?city=boise&envelope=true", nil)
+	req.Header.Set("If-None-Match", etag)
+	h.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusNotModified, rr.Code)
+}
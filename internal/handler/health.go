@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/bengimbel/go_redis_api/internal/health"
+)
+
+type HealthHandler struct {
+	Health health.HealthImplementor
+}
+
+func NewHealthHandler(h health.HealthImplementor) *HealthHandler {
+	return &HealthHandler{
+		Health: h,
+	}
+}
+
+// Liveness only tells us the process is up and serving,
+// so it never checks dependencies
+func (hh *HealthHandler) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	renderResponse(w, r, http.StatusOK, health.Response{Status: health.STATUS_OK})
+}
+
+// Readiness reports every dependency check, with a 503
+// if we shouldn't get traffic. Degraded still gets a 200
+// since we can serve without non-critical dependencies.
+func (hh *HealthHandler) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	response := hh.Health.Ready(r.Context())
+
+	code := http.StatusOK
+	if response.Status == health.STATUS_FAIL {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	renderResponse(w, r, code, response)
+}
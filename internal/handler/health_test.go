@@ -0,0 +1,65 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/handler"
+	"github.com/bengimbel/go_redis_api/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockHealth struct {
+	mock.Mock
+}
+
+func (mh *MockHealth) Ready(ctx context.Context) health.Response {
+	args := mh.Called(ctx)
+	return args.Get(0).(health.Response)
+}
+
+func TestLiveness(t *testing.T) {
+	healthHandler := handler.NewHealthHandler(&MockHealth{})
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	healthHandler.HandleLiveness(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+}
+
+func TestReadinessFailing(t *testing.T) {
+	mockHealth := &MockHealth{}
+	expected := health.Response{
+		Status: health.STATUS_FAIL,
+		Checks: map[string]health.Result{
+			"redis": {Status: health.STATUS_FAIL, Error: "connection refused"},
+		},
+	}
+	mockHealth.On("Ready", mock.Anything).Return(expected).Once()
+
+	healthHandler := handler.NewHealthHandler(mockHealth)
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	healthHandler.HandleReadiness(rr, req)
+
+	var actual health.Response
+	json.NewDecoder(rr.Body).Decode(&actual)
+	assert.EqualValues(t, http.StatusServiceUnavailable, rr.Code)
+	assert.EqualValues(t, expected, actual)
+}
+
+func TestReadinessOk(t *testing.T) {
+	mockHealth := &MockHealth{}
+	mockHealth.On("Ready", mock.Anything).Return(health.Response{Status: health.STATUS_OK}).Once()
+
+	healthHandler := handler.NewHealthHandler(mockHealth)
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	healthHandler.HandleReadiness(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/bengimbel/go_redis_api/internal/tracing"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DEFAULT_HISTORY_WINDOW time.Duration = 24 * time.Hour
+	DEFAULT_HISTORY_LIMIT  int           = 100
+	MAX_HISTORY_LIMIT      int           = 1000
+)
+
+type HistoryHandler struct {
+	History repository.HistoryImplementor
+}
+
+func NewHistoryHandler(history repository.HistoryImplementor) *HistoryHandler {
+	return &HistoryHandler{
+		History: history,
+	}
+}
+
+// Handler for a city's weather snapshots between ?from= and ?to=,
+// which default to the last day. Both take an RFC 3339 time or a
+// date, where a date means the start of the day for from and the
+// end of it for to. At most ?limit= snapshots are returned, newest
+// first, and the response says if the range was cut short. The
+// days are summarized over the whole range either way.
+func (hh *HistoryHandler) HandleRetrieveHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	city := strings.ToLower(query.Get("city"))
+	if city == "" {
+		errorPkg.RenderBadRequestError(w, errors.New("city is required"))
+		return
+	}
+
+	now := time.Now().UTC()
+	to, err := parseHistoryTime(query.Get("to"), now, true)
+	if err != nil {
+		errorPkg.RenderBadRequestError(w, err)
+		return
+	}
+	from, err := parseHistoryTime(query.Get("from"), to.Add(-DEFAULT_HISTORY_WINDOW), false)
+	if err != nil {
+		errorPkg.RenderBadRequestError(w, err)
+		return
+	}
+	if from.After(to) {
+		errorPkg.RenderBadRequestError(w, errors.New("from must not be after to"))
+		return
+	}
+
+	limit := DEFAULT_HISTORY_LIMIT
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MAX_HISTORY_LIMIT {
+			errorPkg.RenderBadRequestError(w, fmt.Errorf("limit must be between 1 and %d", MAX_HISTORY_LIMIT))
+			return
+		}
+		limit = n
+	}
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "HistoryHandler.HandleRetrieveHistory",
+		trace.WithAttributes(attribute.String("weather.city", city)),
+	)
+	defer span.End()
+
+	snapshots, err := hh.History.Range(ctx, city, from, to)
+	if repository.IsConnectionError(err) {
+		errorPkg.RenderServiceUnavailableError(w, err, REDIS_RETRY_AFTER)
+		return
+	} else if err != nil {
+		errorPkg.RenderInternalServerError(w, err)
+		return
+	}
+
+	renderResponse(w, r, http.StatusOK, model.NewWeatherHistory(city, from, to, snapshots, limit))
+}
+
+// Parse an RFC 3339 time or a date in UTC. A date is the
+// start of the day, or the end of it if endOfDay is set.
+func parseHistoryTime(value string, fallback time.Time, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	date, err := time.Parse(model.DATE_FORMAT, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or YYYY-MM-DD", value)
+	}
+	if endOfDay {
+		return date.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return date, nil
+}
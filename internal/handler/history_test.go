@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/handler"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/openapi"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockHistory struct {
+	mock.Mock
+}
+
+func (mh *MockHistory) Append(ctx context.Context, city string, weather model.CachedWeather) error {
+	args := mh.Called(ctx, city, weather)
+	return args.Error(0)
+}
+
+func (mh *MockHistory) Range(ctx context.Context, city string, from time.Time, to time.Time) ([]model.WeatherSnapshot, error) {
+	args := mh.Called(ctx, city, from, to)
+	return args.Get(0).([]model.WeatherSnapshot), args.Error(1)
+}
+
+// A snapshot of Boise, which is UTC-7, at a temperature in Kelvin
+func boiseSnapshot(fetchedAt string, kelvin float32) model.WeatherSnapshot {
+	at, _ := time.Parse(time.RFC3339, fetchedAt)
+	snapshot, _ := model.NewWeatherSnapshot(model.NewCachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name:     "boise",
+			Timezone: -7 * 60 * 60,
+		},
+		List: []model.List{
+			{
+				Dt: at.Unix(),
+				Main: model.Main{
+					Temp: kelvin,
+				},
+			},
+		},
+	}, "mock", at, time.Minute))
+	return snapshot
+}
+
+func TestRetrieveHistorySummarizesDays(t *testing.T) {
+	doc, err := openapi.Load(context.Background())
+	assert.Nil(t, err)
+	validator, err := openapi.NewValidator(doc)
+	assert.Nil(t, err)
+	history := &MockHistory{}
+	router := chi.NewRouter()
+	router.Use(validator.Handler)
+	router.Get("/api/weather/history", handler.NewHistoryHandler(history).HandleRetrieveHistory)
+
+	from, _ := time.Parse(time.RFC3339, "2024-01-09T00:00:00Z")
+	to, _ := time.Parse(time.RFC3339, "2024-01-10T23:59:59.999999999Z")
+	// 03:00 UTC on the 10th is still the 9th in Boise
+	snapshots := []model.WeatherSnapshot{
+		boiseSnapshot("2024-01-10T18:00:00Z", 280.15),
+		boiseSnapshot("2024-01-10T15:00:00Z", 278.15),
+		boiseSnapshot("2024-01-10T09:00:00Z", 273.15),
+		boiseSnapshot("2024-01-10T03:00:00Z", 283.15),
+	}
+	history.On("Range", mock.Anything, "boise", from, to).Return(snapshots, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/weather/history?city=Boise&from=2024-01-09&to=2024-01-10&limit=2", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	actual := model.WeatherHistory{}
+	json.Unmarshal(rr.Body.Bytes(), &actual)
+	assert.EqualValues(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.True(t, actual.Truncated)
+	assert.EqualValues(t, 4, actual.Total)
+	// Only the newest are returned, but every day is summarized
+	assert.EqualValues(t, snapshots[:2], actual.Snapshots)
+	assert.EqualValues(t, []model.DailyTemperature{
+		{Date: "2024-01-10", TempMin: 0, TempMax: 7, TempAvg: 4, Samples: 3},
+		{Date: "2024-01-09", TempMin: 10, TempMax: 10, TempAvg: 10, Samples: 1},
+	}, actual.Daily)
+}
+
+func TestRetrieveHistoryRejectsBadRange(t *testing.T) {
+	historyHandler := handler.NewHistoryHandler(&MockHistory{})
+	for _, target := range []string{
+		"/api/weather/history",
+		"/api/weather/history?city=boise&from=yesterday",
+		"/api/weather/history?city=boise&from=2024-01-10&to=2024-01-09",
+		"/api/weather/history?city=boise&limit=0",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		historyHandler.HandleRetrieveHistory(rr, req)
+
+		assert.EqualValues(t, http.StatusBadRequest, rr.Code, target)
+	}
+}
@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/openapi"
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Serve the weather handlers behind the openapi validator,
+// so any response that drifts from the spec becomes a 500
+func newValidatedRouter(t *testing.T) http.Handler {
+	doc, err := openapi.Load(context.Background())
+	assert.Nil(t, err)
+	validator, err := openapi.NewValidator(doc)
+	assert.Nil(t, err)
+
+	router := chi.NewRouter()
+	router.Use(validator.Handler)
+	router.Get("/api/weather", mockWeatherHandler.HandleRetrieveWeather)
+	router.Get("/api/weather/cached", mockWeatherHandler.HandleRetrieveCachedWeather)
+	return router
+}
+
+func TestWeatherRoutesMatchOpenApiSpec(t *testing.T) {
+	ctx := mock.Anything
+	router := newValidatedRouter(t)
+	weather := cachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "omaha",
+		},
+		List: []model.List{
+			{
+				Dt:      123,
+				Weather: []model.Weather{{Main: "Rain"}},
+			},
+		},
+	})
+
+	mockService.On("DoesKeyExist", ctx, "omaha").Return(false).Times(6)
+	mockService.On("RetrieveAndCacheWeatherAsync", ctx, "omaha").Return(weather, nil).Times(6)
+	mockService.On("RecordCityRequest", ctx, "omaha").Return(nil).Times(6)
+	mockService.On("RetrieveWeatherFromCache", ctx, "omaha").Return(weather, nil).Twice()
+	mockService.On("DoesKeyExist", ctx, "nowhere").Return(false).Once()
+	mockService.On("RetrieveAndCacheWeatherAsync", ctx, "nowhere").Return(model.CachedWeather{}, errors.New("city not found")).Once()
+	mockService.On("RetrieveWeatherFromCache", ctx, "nowhere").Return(model.CachedWeather{}, repository.ErrRedisUnavailable).Once()
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		code   int
+	}{
+		{"weather", "/api/weather?city=omaha", nil, http.StatusOK},
+		{"weather envelope", "/api/weather?city=omaha&envelope=true", nil, http.StatusOK},
+		{"weather summary", "/api/weather?city=omaha&view=summary", nil, http.StatusOK},
+		{"weather summary envelope", "/api/weather?city=omaha&view=summary&envelope=true", nil, http.StatusOK},
+		{"weather fields", "/api/weather?city=omaha&fields=city.name,list.dt", nil, http.StatusOK},
+		{"weather in a time zone", "/api/weather?city=omaha&tz=America/Chicago", nil, http.StatusOK},
+		{"weather unknown time zone", "/api/weather?city=omaha&tz=Mars/Olympus", nil, http.StatusBadRequest},
+		{"weather unknown field", "/api/weather?city=omaha&fields=city.mayor", nil, http.StatusBadRequest},
+		{"weather error", "/api/weather?city=nowhere", nil, http.StatusBadRequest},
+		{"cached weather", "/api/weather/cached?city=omaha", nil, http.StatusOK},
+		{"cached weather not modified", "/api/weather/cached?city=omaha", http.Header{"If-Modified-Since": {weather.FetchedAt.UTC().Format(http.TimeFormat)}}, http.StatusNotModified},
+		{"cached weather unavailable", "/api/weather/cached?city=nowhere", nil, http.StatusServiceUnavailable},
+		{"missing city", "/api/weather", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			router.ServeHTTP(rr, req)
+
+			assert.EqualValues(t, tt.code, rr.Code, rr.Body.String())
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/internal/stream"
+	"github.com/bengimbel/go_redis_api/internal/tracing"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	WEATHER_EVENT      string        = "weather"
+	STREAM_RETRY_AFTER time.Duration = 5 * time.Second
+)
+
+type StreamHandler struct {
+	Service service.WeatherServiceImplementor
+	Hub     stream.HubImplementor
+	// How often a comment is sent on an idle stream
+	HeartbeatInterval time.Duration
+}
+
+func NewStreamHandler(svc service.WeatherServiceImplementor, hub stream.HubImplementor, heartbeatInterval time.Duration) *StreamHandler {
+	return &StreamHandler{
+		Service:           svc,
+		Hub:               hub,
+		HeartbeatInterval: heartbeatInterval,
+	}
+}
+
+// Handler for streaming a city's weather as server-sent events.
+// The current weather is sent first, then a new event each time
+// any replica refreshes the city in the cache. Each event's id is
+// when the weather was fetched, so a client reconnecting with
+// Last-Event-ID isn't sent the weather it already has.
+func (sh *StreamHandler) HandleStreamWeather(w http.ResponseWriter, r *http.Request) {
+	city := strings.ToLower(r.URL.Query().Get("city"))
+	if city == "" {
+		errorPkg.RenderBadRequestError(w, errors.New("city is required"))
+		return
+	}
+
+	// Subscribe before reading the current weather,
+	// so a refresh in between isn't missed
+	sub, err := sh.Hub.Subscribe(city)
+	if err != nil {
+		errorPkg.RenderServiceUnavailableError(w, err, STREAM_RETRY_AFTER)
+		return
+	}
+	defer sh.Hub.Unsubscribe(sub)
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "StreamHandler.HandleStreamWeather",
+		trace.WithAttributes(attribute.String("weather.city", city)),
+	)
+	result, err := service.RetrieveWeather(ctx, sh.Service, city)
+	span.End()
+	if err != nil {
+		renderUpstreamError(w, err)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.FromContext(r.Context()).WarnContext(r.Context(), "Failed to clear write deadline for stream", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// Stop nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	lastSent := time.Time{}
+	if lastEventID, err := time.Parse(time.RFC3339Nano, r.Header.Get("Last-Event-ID")); err == nil {
+		lastSent = lastEventID
+	}
+
+	send := func(weather model.CachedWeather) error {
+		if !weather.FetchedAt.After(lastSent) {
+			return nil
+		}
+		if err := writeWeatherEvent(w, weather); err != nil {
+			return err
+		}
+		lastSent = weather.FetchedAt
+		return rc.Flush()
+	}
+	if err := send(result); err != nil {
+		logger.FromContext(r.Context()).WarnContext(r.Context(), "Failed to write weather event", "error", err)
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		logger.FromContext(r.Context()).WarnContext(r.Context(), "Failed to flush weather stream", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(sh.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case weather, ok := <-sub.Updates:
+			// The hub is shutting down
+			if !ok {
+				return
+			}
+			if err := send(weather); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Write the weather as one event. The data is the
+// same envelope as ?envelope=true on the weather api.
+func writeWeatherEvent(w http.ResponseWriter, weather model.CachedWeather) error {
+	data, err := json.Marshal(newWeatherEnvelope(weather, nil, time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to encode weather event: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n",
+		weather.FetchedAt.UTC().Format(time.RFC3339Nano), WEATHER_EVENT, data)
+
+	return err
+}
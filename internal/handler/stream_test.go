@@ -0,0 +1,83 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/handler"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockHub struct {
+	mock.Mock
+}
+
+func (mh *MockHub) Subscribe(city string) (*stream.Subscription, error) {
+	args := mh.Called(city)
+	sub, _ := args.Get(0).(*stream.Subscription)
+	return sub, args.Error(1)
+}
+
+func (mh *MockHub) Unsubscribe(sub *stream.Subscription) {
+	mh.Called(sub)
+}
+
+func TestStreamWeatherSendsCurrentThenUpdates(t *testing.T) {
+	ctx := mock.Anything
+	hub := &MockHub{}
+	streamHandler := handler.NewStreamHandler(mockService, hub, time.Minute)
+	current := cachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "tulsa",
+		},
+	})
+	refreshed := current
+	refreshed.FetchedAt = current.FetchedAt.Add(time.Minute)
+
+	// The first update repeats what was already sent, and the
+	// channel is closed after the second as if shutting down
+	sub := &stream.Subscription{
+		City:    "tulsa",
+		Updates: make(chan model.CachedWeather, 2),
+	}
+	sub.Updates <- current
+	sub.Updates <- refreshed
+	close(sub.Updates)
+	hub.On("Subscribe", "tulsa").Return(sub, nil).Once()
+	hub.On("Unsubscribe", sub).Once()
+	mockService.On("DoesKeyExist", ctx, "tulsa").Return(true).Once()
+	mockService.On("RetrieveWeatherFromCache", ctx, "tulsa").Return(current, nil).Once()
+	mockService.On("RecordCityRequest", ctx, "tulsa").Return(nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/weather/stream?city=Tulsa", nil)
+	rr := httptest.NewRecorder()
+	streamHandler.HandleStreamWeather(rr, req)
+
+	body := rr.Body.String()
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	assert.EqualValues(t, 2, strings.Count(body, "event: weather\n"))
+	assert.Contains(t, body, "id: "+current.FetchedAt.UTC().Format(time.RFC3339Nano)+"\n")
+	assert.Contains(t, body, "id: "+refreshed.FetchedAt.UTC().Format(time.RFC3339Nano)+"\n")
+	assert.Contains(t, body, `data: {"data":{"city":{`)
+	hub.AssertExpectations(t)
+}
+
+func TestStreamWeatherRejectsTooManyClients(t *testing.T) {
+	hub := &MockHub{}
+	streamHandler := handler.NewStreamHandler(mockService, hub, time.Minute)
+	hub.On("Subscribe", "tulsa").Return(nil, stream.ErrTooManySubscribers).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/weather/stream?city=tulsa", nil)
+	rr := httptest.NewRecorder()
+	streamHandler.HandleStreamWeather(rr, req)
+
+	assert.EqualValues(t, http.StatusServiceUnavailable, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+}
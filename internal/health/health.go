@@ -0,0 +1,194 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	STATUS_OK       string = "ok"
+	STATUS_FAIL     string = "fail"
+	STATUS_DEGRADED string = "degraded"
+
+	WARMUP_CHECK   string = "warmup"
+	SHUTDOWN_CHECK string = "shutdown"
+)
+
+// A dependency check. It should return quickly,
+// and an error if the dependency can't be used.
+type CheckFunc func(context.Context) error
+
+// Result of a single check
+type Result struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Overall health, with the result of every check
+type Response struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+type HealthImplementor interface {
+	Ready(context.Context) Response
+}
+
+// Tracks whether we are ready for traffic. We are ready once
+// warmup is done, every critical check passes, and we are not
+// shutting down. If only non-critical checks fail we are still
+// ready, but report ourselves as degraded. Check results are cached for the check's TTL so
+// frequent probes don't hammer our dependencies.
+type Health struct {
+	Timeout      time.Duration
+	checks       []*check
+	warm         atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+type check struct {
+	name      string
+	ttl       time.Duration
+	fn        CheckFunc
+	critical  bool
+	mu        sync.Mutex
+	last      Result
+	expiresAt time.Time
+}
+
+// Create a new Health. Each check gets at most timeout to run.
+func NewHealth(timeout time.Duration) *Health {
+	return &Health{
+		Timeout: timeout,
+	}
+}
+
+// Add a dependency check we can't serve without,
+// caching its result for ttl
+func (h *Health) AddCheck(name string, ttl time.Duration, fn CheckFunc) {
+	h.checks = append(h.checks, &check{
+		name:     name,
+		ttl:      ttl,
+		fn:       fn,
+		critical: true,
+	})
+}
+
+// Add a dependency check we can run without,
+// in degraded mode, caching its result for ttl
+func (h *Health) AddNonCriticalCheck(name string, ttl time.Duration, fn CheckFunc) {
+	h.checks = append(h.checks, &check{
+		name: name,
+		ttl:  ttl,
+		fn:   fn,
+	})
+}
+
+// Mark warmup as done
+func (h *Health) MarkWarm() {
+	h.warm.Store(true)
+}
+
+// Start failing readiness so load balancers stop sending us traffic
+func (h *Health) MarkShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Run (or reuse cached results of) every check
+func (h *Health) Ready(ctx context.Context) Response {
+	now := time.Now().UTC()
+	if h.shuttingDown.Load() {
+		return Response{
+			Status: STATUS_FAIL,
+			Checks: map[string]Result{
+				SHUTDOWN_CHECK: {Status: STATUS_FAIL, Error: "shutting down", CheckedAt: now},
+			},
+		}
+	}
+
+	response := Response{
+		Status: STATUS_OK,
+		Checks: make(map[string]Result, len(h.checks)+1),
+	}
+
+	warmup := Result{Status: STATUS_OK, CheckedAt: now}
+	if !h.warm.Load() {
+		warmup = Result{Status: STATUS_FAIL, Error: "warming up", CheckedAt: now}
+	}
+	response.Checks[WARMUP_CHECK] = warmup
+
+	// Checks run in parallel so one slow dependency
+	// doesn't add to the time of the others
+	results := make([]Result, len(h.checks))
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = h.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	if warmup.Status != STATUS_OK {
+		response.Status = STATUS_FAIL
+	}
+	for i, c := range h.checks {
+		response.Checks[c.name] = results[i]
+		if results[i].Status == STATUS_OK {
+			continue
+		}
+		if c.critical {
+			response.Status = STATUS_FAIL
+		} else if response.Status == STATUS_OK {
+			response.Status = STATUS_DEGRADED
+		}
+	}
+
+	return response
+}
+
+// Run a check unless its last result is still fresh.
+// Concurrent probes wait on the lock and share one run.
+func (h *Health) run(ctx context.Context, c *check) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(c.expiresAt) {
+		return c.last
+	}
+
+	checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.Timeout)
+	defer cancel()
+
+	result := Result{Status: STATUS_OK, CheckedAt: now.UTC()}
+	if err := c.fn(checkCtx); err != nil {
+		result.Status = STATUS_FAIL
+		result.Error = err.Error()
+	}
+
+	c.last = result
+	c.expiresAt = now.Add(c.ttl)
+	return result
+}
+
+// Combine checks so the result passes if any of them pass,
+// e.g. when a fallback can serve if the primary is down
+func Any(checks ...CheckFunc) CheckFunc {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, fn := range checks {
+			err := fn(ctx)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}
+}
@@ -0,0 +1,84 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadyCachesCheckResults(t *testing.T) {
+	calls := 0
+	h := health.NewHealth(time.Second)
+	h.AddCheck("redis", time.Minute, func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	h.MarkWarm()
+
+	first := h.Ready(context.Background())
+	second := h.Ready(context.Background())
+
+	assert.EqualValues(t, health.STATUS_OK, first.Status)
+	assert.EqualValues(t, health.STATUS_OK, second.Status)
+	assert.EqualValues(t, 1, calls)
+}
+
+func TestReadyFailsUntilWarm(t *testing.T) {
+	h := health.NewHealth(time.Second)
+
+	response := h.Ready(context.Background())
+	assert.EqualValues(t, health.STATUS_FAIL, response.Status)
+	assert.EqualValues(t, health.STATUS_FAIL, response.Checks[health.WARMUP_CHECK].Status)
+
+	h.MarkWarm()
+	assert.EqualValues(t, health.STATUS_OK, h.Ready(context.Background()).Status)
+}
+
+func TestReadyFailsWhenCheckFails(t *testing.T) {
+	h := health.NewHealth(time.Second)
+	h.AddCheck("redis", 0, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	h.MarkWarm()
+
+	response := h.Ready(context.Background())
+	assert.EqualValues(t, health.STATUS_FAIL, response.Status)
+	assert.EqualValues(t, "connection refused", response.Checks["redis"].Error)
+}
+
+func TestReadyFailsWhenShuttingDown(t *testing.T) {
+	h := health.NewHealth(time.Second)
+	h.MarkWarm()
+	h.MarkShuttingDown()
+
+	response := h.Ready(context.Background())
+	assert.EqualValues(t, health.STATUS_FAIL, response.Status)
+	assert.Contains(t, response.Checks, health.SHUTDOWN_CHECK)
+}
+
+func TestAnyPassesIfOneCheckPasses(t *testing.T) {
+	failing := func(ctx context.Context) error { return errors.New("down") }
+	passing := func(ctx context.Context) error { return nil }
+
+	assert.Nil(t, health.Any(failing, passing)(context.Background()))
+	assert.NotNil(t, health.Any(failing, failing)(context.Background()))
+}
+
+func TestReadyIsDegradedWhenNonCriticalCheckFails(t *testing.T) {
+	h := health.NewHealth(time.Second)
+	h.AddCheck("upstream", 0, func(ctx context.Context) error {
+		return nil
+	})
+	h.AddNonCriticalCheck("redis", 0, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	h.MarkWarm()
+
+	response := h.Ready(context.Background())
+	assert.EqualValues(t, health.STATUS_DEGRADED, response.Status)
+	assert.EqualValues(t, health.STATUS_FAIL, response.Checks["redis"].Status)
+}
@@ -0,0 +1,51 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+)
+
+// A step run on shutdown, e.g. closing a connection
+type Hook struct {
+	Name string
+	Fn   func(context.Context) error
+}
+
+// Shutdown hooks, run in the order they were added.
+// Order matters: we stop taking requests before draining
+// the work they started, and close redis last since
+// everything before it may still be using it.
+type Shutdown struct {
+	hooks []Hook
+}
+
+func NewShutdown() *Shutdown {
+	return &Shutdown{}
+}
+
+// Add a hook to run after every hook added before it
+func (s *Shutdown) Add(name string, fn func(context.Context) error) {
+	s.hooks = append(s.hooks, Hook{Name: name, Fn: fn})
+}
+
+// Run every hook in order. All hooks share the context's
+// deadline, so a slow hook eats into the time of the ones
+// after it, but a failing hook doesn't stop the rest running.
+func (s *Shutdown) Run(ctx context.Context) error {
+	var errs []error
+	for _, hook := range s.hooks {
+		start := time.Now()
+		if err := hook.Fn(ctx); err != nil {
+			logger.FromContext(ctx).Error("Shutdown step failed", "step", hook.Name, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", hook.Name, err))
+			continue
+		}
+		logger.FromContext(ctx).Info("Shutdown step done", "step", hook.Name, "duration", time.Since(start))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,44 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/lifecycle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownRunsHooksInOrder(t *testing.T) {
+	shutdown := lifecycle.NewShutdown()
+	order := []string{}
+	for _, name := range []string{"server", "inserts", "redis"} {
+		name := name
+		shutdown.Add(name, func(ctx context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+
+	err := shutdown.Run(context.Background())
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{"server", "inserts", "redis"}, order)
+}
+
+func TestShutdownKeepsGoingAfterError(t *testing.T) {
+	shutdown := lifecycle.NewShutdown()
+	closed := false
+	shutdown.Add("server", func(ctx context.Context) error {
+		return errors.New("timed out")
+	})
+	shutdown.Add("redis", func(ctx context.Context) error {
+		closed = true
+		return nil
+	})
+
+	err := shutdown.Run(context.Background())
+
+	assert.ErrorContains(t, err, "server: timed out")
+	assert.True(t, closed)
+}
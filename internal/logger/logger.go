@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FORMAT_JSON string = "json"
+	FORMAT_TEXT string = "text"
+
+	REQUEST_ID_KEY string = "request_id"
+	TRACE_ID_KEY   string = "trace_id"
+	REDACTED       string = "REDACTED"
+)
+
+// Matches the api key query param in any upstream url
+var apiKeyPattern = regexp.MustCompile(`(?i)(appid=)[^&\s"]+`)
+
+type contextKey struct{}
+
+type loggerKey struct{}
+
+// Create a structured logger writing to w in the given format
+// ("json" or "text") at the given level ("debug", "info", "warn", "error").
+// Every line gets the request id and trace id from the context, if
+// there is one, and api keys in upstream urls are redacted.
+func New(w io.Writer, format string, level string) *slog.Logger {
+	options := &slog.HandlerOptions{
+		Level:       ParseLevel(level),
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, FORMAT_TEXT) {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// Parse a level name, falling back to info
+func ParseLevel(level string) slog.Level {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return parsed
+}
+
+// Attach a request id to a context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestID)
+}
+
+// Get the request id from a context, if there is one
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKey{}).(string)
+	return requestID
+}
+
+// Attach a logger to a context, so code handling
+// a request or running a job logs through it
+func WithLogger(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// Get the logger from a context. Falls back to slog's
+// default logger if the context doesn't have one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return log
+	}
+	return slog.Default()
+}
+
+// Replace the api key in a url (or any string) so it is safe to log
+func Redact(value string) string {
+	return apiKeyPattern.ReplaceAllString(value, "${1}"+REDACTED)
+}
+
+// A logger that discards everything, for tests
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// Handler that adds request and trace ids from the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String(REQUEST_ID_KEY, requestID))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		record.AddAttrs(slog.String(TRACE_ID_KEY, span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// Redact api keys from string values and errors before they are written
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		attr.Value = slog.StringValue(Redact(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			attr.Value = slog.StringValue(Redact(err.Error()))
+		}
+	}
+	return attr
+}
@@ -0,0 +1,38 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerAddsRequestIDAndRedactsApiKey(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	log := logger.New(buffer, logger.FORMAT_JSON, "info")
+	ctx := logger.WithRequestID(context.Background(), "abc-123")
+
+	log.ErrorContext(ctx, "upstream failed",
+		"url", "https://api.openweathermap.org/geo/1.0/direct?appid=secret&q=chicago",
+		"error", errors.New(`Get "https://api.openweathermap.org/data/2.5/forecast?appid=secret": timeout`),
+	)
+
+	line := map[string]string{}
+	json.Unmarshal(buffer.Bytes(), &line)
+
+	assert.EqualValues(t, "abc-123", line["request_id"])
+	assert.EqualValues(t, "https://api.openweathermap.org/geo/1.0/direct?appid=REDACTED&q=chicago", line["url"])
+	assert.NotContains(t, line["error"], "secret")
+}
+
+func TestFromContextFallsBackToDefault(t *testing.T) {
+	log := logger.Discard()
+
+	assert.Same(t, log, logger.FromContext(logger.WithLogger(context.Background(), log)))
+	assert.Same(t, slog.Default(), logger.FromContext(context.Background()))
+}
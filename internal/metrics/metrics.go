@@ -0,0 +1,214 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-redis/cache/v9"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	NAMESPACE string = "weather_api"
+
+	TIER_LOCAL string = "local"
+	TIER_REDIS string = "redis"
+
+	RESULT_HIT   string = "hit"
+	RESULT_MISS  string = "miss"
+	RESULT_ERROR string = "error"
+
+	DELIVERY_DELIVERED     string = "delivered"
+	DELIVERY_RETRIED       string = "retried"
+	DELIVERY_DEAD_LETTERED string = "dead_lettered"
+
+	UNMATCHED_ROUTE string = "unmatched"
+)
+
+// Registry every metric is registered to.
+// We use our own registry rather than the global
+// default so only our metrics are exposed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HttpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	HttpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "cache_lookups_total",
+		Help:      "Cache lookups by tier (local, redis) and result (hit, miss, error).",
+	}, []string{"tier", "result"})
+
+	UpstreamRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "upstream_requests_total",
+		Help:      "Calls to upstream weather apis by host, path and result.",
+	}, []string{"host", "path", "result"})
+
+	UpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: NAMESPACE,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of calls to upstream weather apis by host and path.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"host", "path"})
+
+	AsyncInsertsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "cache_async_inserts_in_flight",
+		Help:      "Async cache inserts that have been started but not finished.",
+	})
+
+	RedisUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "redis_up",
+		Help:      "1 if redis is reachable, 0 if we are running degraded without it.",
+	})
+
+	StreamClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: NAMESPACE,
+		Name:      "stream_clients",
+		Help:      "Clients currently streaming weather updates.",
+	})
+
+	AlertDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: NAMESPACE,
+		Name:      "alert_deliveries_total",
+		Help:      "Alert webhook delivery attempts by result (delivered, retried, dead_lettered).",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HttpRequests,
+		HttpDuration,
+		CacheLookups,
+		UpstreamRequests,
+		UpstreamDuration,
+		AsyncInsertsInFlight,
+		RedisUp,
+		StreamClients,
+		AlertDeliveries,
+	)
+}
+
+// Handler for the /metrics endpoint
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Middleware that records the count and latency of every request.
+// Routes are labeled by their chi pattern (e.g. /api/weather)
+// rather than the raw path, so query params and unknown
+// paths can't blow up the number of series.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := UNMATCHED_ROUTE
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{
+			"route":  route,
+			"method": r.Method,
+			"status": strconv.Itoa(status),
+		}
+
+		HttpRequests.With(labels).Inc()
+		HttpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Record the result of a cache lookup in one tier
+func ObserveCacheLookup(tier string, result string) {
+	CacheLookups.WithLabelValues(tier, result).Inc()
+}
+
+// Record a call to an upstream api
+func ObserveUpstreamRequest(host string, path string, duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = RESULT_ERROR
+	}
+	UpstreamRequests.WithLabelValues(host, path, result).Inc()
+	UpstreamDuration.WithLabelValues(host, path).Observe(duration.Seconds())
+}
+
+// Expose the go-redis/cache hit and miss stats. These
+// only count lookups that reach redis.
+func RegisterCacheStats(c *cache.Cache) {
+	replace(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "redis_cache_hits_total",
+			Help:      "Hits reported by the redis cache client.",
+		}, func() float64 {
+			return cacheStat(c, func(s *cache.Stats) uint64 { return s.Hits })
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: NAMESPACE,
+			Name:      "redis_cache_misses_total",
+			Help:      "Misses reported by the redis cache client.",
+		}, func() float64 {
+			return cacheStat(c, func(s *cache.Stats) uint64 { return s.Misses })
+		}),
+	)
+}
+
+// Expose the redis client's connection pool stats
+func RegisterRedisPoolStats(rds *redis.Client) {
+	replace(&poolStatsCollector{client: rds})
+}
+
+// Register collectors that read from a client, replacing any
+// registered for an earlier client. NewApp can run more than
+// once in a process (tests, a second server) and the
+// latest app's clients are the ones we want to expose.
+func replace(collectors ...prometheus.Collector) {
+	for _, c := range collectors {
+		err := Registry.Register(c)
+		var exists prometheus.AlreadyRegisteredError
+		if errors.As(err, &exists) {
+			Registry.Unregister(exists.ExistingCollector)
+			err = Registry.Register(c)
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func cacheStat(c *cache.Cache, get func(*cache.Stats) uint64) float64 {
+	stats := c.Stats()
+	if stats == nil {
+		return 0
+	}
+	return float64(get(stats))
+}
@@ -0,0 +1,39 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-redis/cache/v9"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareLabelsByRoutePattern(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(metrics.Middleware)
+	router.Get("/api/weather/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/weather/1?city=chicago", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/weather/2", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope", nil))
+
+	assert.EqualValues(t, 2, testutil.ToFloat64(metrics.HttpRequests.WithLabelValues("/api/weather/{id}", http.MethodGet, "418")))
+	assert.EqualValues(t, 1, testutil.ToFloat64(metrics.HttpRequests.WithLabelValues(metrics.UNMATCHED_ROUTE, http.MethodGet, "404")))
+}
+
+func TestRegisterClientStatsTwice(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		rds := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+		assert.NotPanics(t, func() {
+			metrics.RegisterCacheStats(cache.New(&cache.Options{Redis: rds}))
+			metrics.RegisterRedisPoolStats(rds)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	poolHitsDesc     = poolDesc("hits_total", "Times a free connection was found in the pool.")
+	poolMissesDesc   = poolDesc("misses_total", "Times a free connection was not found in the pool.")
+	poolTimeoutsDesc = poolDesc("timeouts_total", "Times a wait for a connection timed out.")
+	poolTotalDesc    = poolDesc("connections", "Connections in the pool.")
+	poolIdleDesc     = poolDesc("idle_connections", "Idle connections in the pool.")
+	poolStaleDesc    = poolDesc("stale_connections_total", "Stale connections removed from the pool.")
+)
+
+// Collector that reads redis.Client.PoolStats() on every scrape
+type poolStatsCollector struct {
+	client *redis.Client
+}
+
+func (c *poolStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolHitsDesc
+	ch <- poolMissesDesc
+	ch <- poolTimeoutsDesc
+	ch <- poolTotalDesc
+	ch <- poolIdleDesc
+	ch <- poolStaleDesc
+}
+
+func (c *poolStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(poolHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(poolMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(poolTimeoutsDesc, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(poolTotalDesc, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(poolStaleDesc, prometheus.CounterValue, float64(stats.StaleConns))
+}
+
+func poolDesc(name string, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(NAMESPACE, "redis_pool", name), help, nil, nil)
+}
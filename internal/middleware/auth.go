@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+)
+
+const (
+	ADMIN_KEY_ID          string        = "admin"
+	KEY_STORE_RETRY_AFTER time.Duration = 5 * time.Second
+)
+
+// Authenticates requests by API key. Keys are looked up in the
+// key store, except for the bootstrap admin key from config which
+// has every scope and is used to create the first real keys.
+// Verified keys are cached locally for CacheTTL, and while the key
+// store is failing, keys verified within StaleTTL keep working.
+// Revoked keys are dropped from the cache with Invalidate.
+type Authenticator struct {
+	Store    auth.KeyStoreImplementor
+	AdminKey string
+	CacheTTL time.Duration
+	StaleTTL time.Duration
+	cache    keyCache
+}
+
+func NewAuthenticator(store auth.KeyStoreImplementor, cfg config.AuthConfig) *Authenticator {
+	return &Authenticator{
+		Store:    store,
+		AdminKey: cfg.AdminKey,
+		CacheTTL: cfg.KeyCacheTTL,
+		StaleTTL: cfg.KeyStaleTTL,
+	}
+}
+
+// Stop trusting a cached key, e.g. once it is revoked
+func (a *Authenticator) Invalidate(id string) {
+	a.cache.removeId(id)
+}
+
+// Middleware that rejects requests without a valid API key,
+// and attaches the key to the request context for later checks.
+func (a *Authenticator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plainKey := RequestApiKey(r)
+		if plainKey == "" {
+			errorPkg.RenderUnauthorizedError(w, errors.New("missing api key"))
+			return
+		}
+
+		key, err := a.FindKey(r.Context(), plainKey)
+		if errors.Is(err, auth.ErrApiKeyNotFound) {
+			errorPkg.RenderUnauthorizedError(w, errors.New("invalid api key"))
+			return
+		} else if err != nil {
+			// Most likely redis is down and this key isn't one we
+			// verified recently. Fail closed, but ask the client
+			// to retry since it should clear up.
+			logger.FromContext(r.Context()).ErrorContext(r.Context(), "Failed to check api key", "error", err)
+			errorPkg.RenderServiceUnavailableError(w, errors.New("failed to check api key"), KEY_STORE_RETRY_AFTER)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithApiKey(r.Context(), key)))
+	})
+}
+
+// Middleware that only lets through keys with the given scope.
+// This must run after Handler.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := auth.ApiKeyFromContext(r.Context())
+			if !ok {
+				errorPkg.RenderUnauthorizedError(w, errors.New("missing api key"))
+				return
+			}
+			if !key.HasScope(scope) {
+				errorPkg.RenderForbiddenError(w, fmt.Errorf("api key is missing scope: %s", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Look up a plain api key, which may be the bootstrap admin key.
+// If the key store fails, a key we verified within StaleTTL is
+// used rather than taking down every authenticated request.
+func (a *Authenticator) FindKey(ctx context.Context, plainKey string) (auth.ApiKey, error) {
+	if a.AdminKey != "" && subtle.ConstantTimeCompare([]byte(plainKey), []byte(a.AdminKey)) == 1 {
+		return auth.ApiKey{
+			Id:     ADMIN_KEY_ID,
+			Name:   "bootstrap admin",
+			Scopes: auth.AllScopes,
+			Tier:   auth.DEFAULT_TIER,
+		}, nil
+	}
+
+	hash := auth.HashKey(plainKey)
+	if key, ok := a.cache.get(hash, a.CacheTTL); ok {
+		return key, nil
+	}
+
+	key, err := a.Store.FindByKey(ctx, plainKey)
+	if err == nil {
+		a.cache.put(hash, key)
+	} else if errors.Is(err, auth.ErrApiKeyNotFound) {
+		a.cache.remove(hash)
+	} else if cached, ok := a.cache.get(hash, a.StaleTTL); ok {
+		logger.FromContext(ctx).WarnContext(ctx, "Key store unavailable, using cached api key", "key", cached.Id, "error", err)
+		return cached, nil
+	}
+	return key, err
+}
@@ -0,0 +1,119 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockKeyStore struct {
+	mock.Mock
+}
+
+func (m *MockKeyStore) Create(ctx context.Context, name string, scopes []string, tier string) (string, auth.ApiKey, error) {
+	args := m.Called(ctx, name, scopes, tier)
+	return args.String(0), args.Get(1).(auth.ApiKey), args.Error(2)
+}
+
+func (m *MockKeyStore) FindByKey(ctx context.Context, plainKey string) (auth.ApiKey, error) {
+	args := m.Called(ctx, plainKey)
+	return args.Get(0).(auth.ApiKey), args.Error(1)
+}
+
+func (m *MockKeyStore) List(ctx context.Context) ([]auth.ApiKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]auth.ApiKey), args.Error(1)
+}
+
+func (m *MockKeyStore) Revoke(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestRequireScope(t *testing.T) {
+	handler := middleware.RequireScope(auth.SCOPE_CACHE_ADMIN)(okHandler)
+	key := auth.ApiKey{
+		Id:     "abc",
+		Scopes: []string{auth.SCOPE_WEATHER_READ},
+	}
+
+	// No key at all
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/cache", nil)
+	handler.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusUnauthorized, rr.Code)
+
+	// Key without the scope
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req.WithContext(auth.WithApiKey(req.Context(), key)))
+	assert.EqualValues(t, http.StatusForbidden, rr.Code)
+
+	// Key with the scope
+	key.Scopes = append(key.Scopes, auth.SCOPE_CACHE_ADMIN)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req.WithContext(auth.WithApiKey(req.Context(), key)))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+}
+
+func TestFindKeyUsesCachedKeyWhenStoreFails(t *testing.T) {
+	key := auth.ApiKey{Id: "abc", Scopes: []string{auth.SCOPE_WEATHER_READ}}
+	store := &MockKeyStore{}
+	store.On("FindByKey", mock.Anything, "known").Return(key, nil).Once()
+	store.On("FindByKey", mock.Anything, mock.Anything).Return(auth.ApiKey{}, errors.New("redis is down"))
+	authenticator := middleware.NewAuthenticator(store, config.AuthConfig{KeyStaleTTL: time.Hour})
+
+	found, err := authenticator.FindKey(context.Background(), "known")
+	assert.Nil(t, err)
+	assert.Equal(t, key, found)
+
+	// Known keys keep working, unknown keys fail closed
+	found, err = authenticator.FindKey(context.Background(), "known")
+	assert.Nil(t, err)
+	assert.Equal(t, key, found)
+
+	_, err = authenticator.FindKey(context.Background(), "unknown")
+	assert.NotNil(t, err)
+}
+
+func TestFindKeyCachesVerifiedKeys(t *testing.T) {
+	key := auth.ApiKey{Id: "abc"}
+	store := &MockKeyStore{}
+	store.On("FindByKey", mock.Anything, "known").Return(key, nil).Once()
+	authenticator := middleware.NewAuthenticator(store, config.AuthConfig{KeyCacheTTL: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		found, err := authenticator.FindKey(context.Background(), "known")
+		assert.Nil(t, err)
+		assert.Equal(t, key, found)
+	}
+	store.AssertNumberOfCalls(t, "FindByKey", 1)
+}
+
+func TestInvalidateForgetsRevokedKeys(t *testing.T) {
+	key := auth.ApiKey{Id: "abc"}
+	store := &MockKeyStore{}
+	store.On("FindByKey", mock.Anything, "known").Return(key, nil).Once()
+	store.On("FindByKey", mock.Anything, "known").Return(auth.ApiKey{}, errors.New("redis is down"))
+	authenticator := middleware.NewAuthenticator(store, config.AuthConfig{KeyCacheTTL: time.Minute, KeyStaleTTL: time.Hour})
+
+	_, err := authenticator.FindKey(context.Background(), "known")
+	assert.Nil(t, err)
+
+	// Once revoked, neither the fresh nor the stale copy is used
+	authenticator.Invalidate("abc")
+	_, err = authenticator.FindKey(context.Background(), "known")
+	assert.NotNil(t, err)
+}
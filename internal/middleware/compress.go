@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Content types worth compressing. Everything we serve is json,
+// text or one of the binary formats we negotiate, which still
+// shrink well, but e.g. images are already compressed.
+var compressibleTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/msgpack",
+	"application/x-protobuf",
+	"text/csv",
+	"text/plain",
+	"text/html",
+	"text/css",
+	"application/javascript",
+}
+
+// Middleware that compresses responses with brotli or gzip,
+// whichever the client prefers and accepts. Level is the
+// gzip level (1-9), and is also used for brotli (0-11).
+func Compress(level int) func(http.Handler) http.Handler {
+	compressor := chiMiddleware.NewCompressor(level, compressibleTypes...)
+	// Encoders set here take precedence over gzip
+	compressor.SetEncoder("br", func(w io.Writer, level int) io.Writer {
+		return brotli.NewWriterLevel(w, level)
+	})
+
+	return compressor.Handler
+}
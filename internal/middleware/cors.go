@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	corsAllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions}
+	corsAllowedHeaders = []string{"Accept", "Authorization", "Content-Type", "If-None-Match", "If-Modified-Since", API_KEY_HEADER, REQUEST_ID_HEADER}
+	corsExposedHeaders = []string{REQUEST_ID_HEADER, "ETag", "X-Cache", "X-Cache-Tier", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"}
+)
+
+// Lets browsers on the allowed origins call our api. An origin
+// of "*" allows any origin. Credentials (cookies) are never allowed,
+// since clients authenticate with an api key header.
+type CORS struct {
+	AllowedOrigins []string
+	MaxAge         time.Duration
+}
+
+func NewCORS(allowedOrigins []string, maxAge time.Duration) *CORS {
+	return &CORS{
+		AllowedOrigins: allowedOrigins,
+		MaxAge:         maxAge,
+	}
+}
+
+// Middleware that sets the CORS headers for allowed origins,
+// and answers preflight requests without calling the handler.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		// The response depends on the origin, so caches must key on it
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !c.IsAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+
+		isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !isPreflight {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+		if c.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Check if an origin may call us
+func (c *CORS) IsAllowed(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
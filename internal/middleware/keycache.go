@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/auth"
+)
+
+const (
+	MAX_CACHED_KEYS int = 10000
+)
+
+// Api keys we have recently verified, by the hash of the
+// plain key so plain keys aren't kept in memory
+type keyCache struct {
+	mu   sync.Mutex
+	keys map[string]cachedKey
+}
+
+type cachedKey struct {
+	key        auth.ApiKey
+	verifiedAt time.Time
+}
+
+// Get a key verified less than maxAge ago
+func (kc *keyCache) get(hash string, maxAge time.Duration) (auth.ApiKey, bool) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	cached, ok := kc.keys[hash]
+	if !ok || time.Since(cached.verifiedAt) >= maxAge {
+		return auth.ApiKey{}, false
+	}
+	return cached.key, true
+}
+
+// Remember a key was just verified. Once the cache is
+// full an arbitrary key is dropped to make room.
+func (kc *keyCache) put(hash string, key auth.ApiKey) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+
+	if kc.keys == nil {
+		kc.keys = map[string]cachedKey{}
+	}
+	if _, ok := kc.keys[hash]; !ok && len(kc.keys) >= MAX_CACHED_KEYS {
+		for other := range kc.keys {
+			delete(kc.keys, other)
+			break
+		}
+	}
+	kc.keys[hash] = cachedKey{key: key, verifiedAt: time.Now()}
+}
+
+func (kc *keyCache) remove(hash string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	delete(kc.keys, hash)
+}
+
+// Forget a key by id, since revoking only knows the id
+func (kc *keyCache) removeId(id string) {
+	kc.mu.Lock()
+	defer kc.mu.Unlock()
+	for hash, cached := range kc.keys {
+		if cached.key.Id == id {
+			delete(kc.keys, hash)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Middleware that caps the size of request bodies.
+// Reading past the limit fails, so handlers decoding
+// the body render a 400 rather than buffering it all.
+func MaxBodyBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				errorPkg.RenderRequestTooLargeError(w, errors.New("request body is too large"))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Middleware that gives the handler timeout to finish. The request
+// context is cancelled at the deadline, so redis and upstream calls
+// stop, and if the handler hasn't written anything we render a 504.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && ww.Status() == 0 {
+				errorPkg.RenderGatewayTimeoutError(w, errors.New("request timed out"))
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	"github.com/bengimbel/go_redis_api/pkg/render"
+)
+
+// Middleware that picks the response format from ?format= or the
+// Accept header and attaches its renderer to the response writer,
+// where handlers and errorPkg pick it up. An unknown ?format= is
+// a 406, in json since we couldn't agree on anything else.
+func Negotiate(registry *render.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept")
+
+			renderer, err := registry.Negotiate(r)
+			if err != nil {
+				errorPkg.RenderNotAcceptableError(w, err)
+				return
+			}
+
+			next.ServeHTTP(render.WithRenderer(w, renderer), r)
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RATE_LIMIT_KEY_PREFIX string = "ratelimit:"
+	// Failed authentication is counted under its own key per IP
+	AUTH_FAILURE_PREFIX  string = "authfail:"
+	API_KEY_HEADER       string = "X-API-Key"
+	FORWARDED_FOR_HEADER string = "X-Forwarded-For"
+	// Windows are counted in whole milliseconds
+	MIN_RATE_LIMIT_WINDOW time.Duration = time.Millisecond
+)
+
+// Per client rate limiter using a sliding window counter in redis,
+// so the limit holds no matter which replica a request lands on.
+// Clients are identified by their API key once it is verified,
+// otherwise by IP.
+// Authenticated keys get the limit for their tier, if it has one.
+type RateLimiter struct {
+	Client         *redis.Client
+	Limit          int
+	Window         time.Duration
+	TrustedProxies []*net.IPNet
+	Tiers          map[string]int
+	Logger         *slog.Logger
+}
+
+// Approximate a sliding window by weighting the previous fixed
+// window's count by how much of it still overlaps the sliding window.
+// Only count the request if it is allowed, so clients that keep
+// retrying while limited don't lock themselves out forever.
+// Nothing is counted when ARGV[4] is 0, which only checks the limit.
+// Returns {allowed, count}.
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local previous = tonumber(redis.call("GET", KEYS[2]) or "0")
+local weighted = previous * (window - elapsed) / window
+
+if weighted + current >= limit then
+	return {0, math.ceil(weighted + current)}
+end
+if ARGV[4] == "0" then
+	return {1, math.ceil(weighted + current)}
+end
+
+current = redis.call("INCR", KEYS[1])
+if current == 1 then
+	redis.call("PEXPIRE", KEYS[1], window * 2)
+end
+
+return {1, math.ceil(weighted + current)}
+`)
+
+// Create a new rate limiter. Trusted proxies are CIDRs (or single IPs)
+// whose X-Forwarded-For header we believe.
+func NewRateLimiter(rds *redis.Client, limit int, window time.Duration, trustedProxies []string, tiers map[string]int, log *slog.Logger) *RateLimiter {
+	networks, err := ParseCIDRs(trustedProxies)
+	if err != nil {
+		log.Warn("Skipping invalid trusted proxies", "error", err)
+	}
+	return &RateLimiter{
+		Client:         rds,
+		Limit:          limit,
+		Window:         window,
+		TrustedProxies: networks,
+		Tiers:          tiers,
+		Logger:         log,
+	}
+}
+
+// Middleware that counts every request against the client's limit,
+// sets the RateLimit-* headers, and renders a 429 once it is used up.
+// If redis can't be reached the request is let through.
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := rl.LimitFor(r.Context())
+		allowed, remaining, reset, err := rl.Take(r.Context(), rl.ClientKey(r), limit)
+		if err != nil {
+			rl.Logger.WarnContext(r.Context(), "Rate limiter unavailable, allowing request", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+
+		if !allowed {
+			errorPkg.RenderTooManyRequestsError(w, fmt.Errorf("rate limit of %d requests per %s exceeded", limit, rl.Window), reset)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware that limits failed authentication per IP, so keys
+// can't be guessed faster than the anonymous rate limit. It runs
+// before the authenticator, and once an IP has used up its
+// failures its requests are turned away without a key lookup.
+// If redis can't be reached the request is let through.
+func (rl *RateLimiter) AuthFailureHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := AUTH_FAILURE_PREFIX + "ip:" + ClientIP(r, rl.TrustedProxies)
+		allowed, _, reset, err := rl.Check(r.Context(), client, rl.Limit)
+		if err != nil {
+			rl.Logger.WarnContext(r.Context(), "Rate limiter unavailable, allowing request", "error", err)
+		} else if !allowed {
+			errorPkg.RenderTooManyRequestsError(w, fmt.Errorf("too many failed authentication attempts, at most %d per %s", rl.Limit, rl.Window), reset)
+			return
+		}
+
+		ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		if ww.Status() != http.StatusUnauthorized {
+			return
+		}
+		if _, _, _, err := rl.Take(r.Context(), client, rl.Limit); err != nil {
+			rl.Logger.WarnContext(r.Context(), "Failed to count authentication failure", "error", err)
+		}
+	})
+}
+
+// Get the limit for a request or call. Authenticated keys
+// use their tier's limit, everyone else gets the default.
+func (rl *RateLimiter) LimitFor(ctx context.Context) int {
+	if key, ok := auth.ApiKeyFromContext(ctx); ok {
+		if limit, ok := rl.Tiers[key.Tier]; ok && limit > 0 {
+			return limit
+		}
+	}
+	return rl.Limit
+}
+
+// Work out who is making the request. Only keys the authenticator
+// has verified count, otherwise a client could send a made up key
+// on every request to get a fresh limit each time.
+func (rl *RateLimiter) ClientKey(r *http.Request) string {
+	return ClientKeyFor(r.Context(), ClientIP(r, rl.TrustedProxies))
+}
+
+// Key a client by its verified api key, or else by its IP
+func ClientKeyFor(ctx context.Context, ip string) string {
+	if key, ok := auth.ApiKeyFromContext(ctx); ok {
+		return "id:" + key.Id
+	}
+	return "ip:" + ip
+}
+
+// Count a request in the client's current window. Returns whether it is
+// allowed, how many requests are left, and how long until the window resets.
+func (rl *RateLimiter) Take(ctx context.Context, client string, limit int) (bool, int, time.Duration, error) {
+	return rl.run(ctx, client, limit, true)
+}
+
+// Like Take, but only checks the client's window without counting
+func (rl *RateLimiter) Check(ctx context.Context, client string, limit int) (bool, int, time.Duration, error) {
+	return rl.run(ctx, client, limit, false)
+}
+
+func (rl *RateLimiter) run(ctx context.Context, client string, limit int, count bool) (bool, int, time.Duration, error) {
+	window := max(rl.Window, MIN_RATE_LIMIT_WINDOW).Milliseconds()
+	now := time.Now().UnixMilli()
+	index := now / window
+	elapsed := now % window
+	reset := time.Duration(window-elapsed) * time.Millisecond
+
+	result, err := slidingWindowScript.Run(ctx, rl.Client,
+		[]string{
+			fmt.Sprintf("%s%s:%d", RATE_LIMIT_KEY_PREFIX, client, index),
+			fmt.Sprintf("%s%s:%d", RATE_LIMIT_KEY_PREFIX, client, index-1),
+		},
+		limit, window, elapsed, count,
+	).Int64Slice()
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("failed to check rate limit: %w", err)
+	}
+
+	remaining := limit - int(result[1])
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return result[0] == 1, remaining, reset, nil
+}
+
+// Get the API key from the X-API-Key header,
+// or from an Authorization: Bearer header
+func RequestApiKey(r *http.Request) string {
+	if apiKey := r.Header.Get(API_KEY_HEADER); apiKey != "" {
+		return apiKey
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// Get the client's IP address. X-Forwarded-For is only used when the
+// request came from a trusted proxy, and then we walk it from the right,
+// skipping our own proxies, so a client can't spoof its IP by sending
+// the header itself.
+func ClientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+
+	if !isTrusted(remoteIP, trustedProxies) {
+		return remoteIP
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values(FORWARDED_FOR_HEADER), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(forwarded[i])
+		if ip == "" {
+			continue
+		}
+		if !isTrusted(ip, trustedProxies) {
+			return ip
+		}
+		remoteIP = ip
+	}
+
+	return remoteIP
+}
+
+// Parse a list of CIDRs. Plain IPs are treated as a single
+// address. Invalid entries are skipped and returned as an error
+// alongside the ones that did parse.
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	var errs []error
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks, errors.Join(errs...)
+}
+
+func isTrusted(value string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/middleware"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+var trustedProxies, _ = middleware.ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+
+func TestClientIPIgnoresForwardedForFromUntrustedPeer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.RemoteAddr = "203.0.113.7:5000"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+	assert.EqualValues(t, "203.0.113.7", middleware.ClientIP(req, trustedProxies))
+}
+
+func TestClientIPUsesForwardedForFromTrustedProxy(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.RemoteAddr = "10.1.2.3:5000"
+	req.Header.Set("X-Forwarded-For", "9.9.9.9, 198.51.100.20, 192.168.1.1")
+
+	assert.EqualValues(t, "198.51.100.20", middleware.ClientIP(req, trustedProxies))
+}
+
+func TestRequestApiKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.Header.Set("Authorization", "Bearer abc123")
+	assert.EqualValues(t, "abc123", middleware.RequestApiKey(req))
+
+	req.Header.Set("X-API-Key", "xyz789")
+	assert.EqualValues(t, "xyz789", middleware.RequestApiKey(req))
+}
+
+func TestClientKeyIgnoresUnverifiedApiKeys(t *testing.T) {
+	limiter := middleware.NewRateLimiter(nil, 60, time.Minute, nil, nil, logger.Discard())
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.RemoteAddr = "203.0.113.7:5000"
+	req.Header.Set("X-API-Key", "made-up")
+	assert.EqualValues(t, "ip:203.0.113.7", limiter.ClientKey(req))
+
+	ctx := auth.WithApiKey(req.Context(), auth.ApiKey{Id: "key-1"})
+	assert.EqualValues(t, "id:key-1", limiter.ClientKey(req.WithContext(ctx)))
+}
+
+func TestAuthFailureHandlerAllowsRequestsWithoutRedis(t *testing.T) {
+	rds := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer rds.Close()
+	limiter := middleware.NewRateLimiter(rds, 1, time.Minute, nil, nil, logger.Discard())
+	handler := limiter.AuthFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather?city=chicago", nil))
+		assert.EqualValues(t, http.StatusUnauthorized, rr.Code)
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+)
+
+// Middleware that recovers from a panic in any handler below it,
+// logs it with the stack, and renders a json 500 so the client
+// still gets a proper error with its request id.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// The server uses this panic to abort a response on purpose
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			logger.FromContext(r.Context()).ErrorContext(r.Context(), "Recovered from panic",
+				"panic", recovered,
+				"stack", string(debug.Stack()),
+			)
+			errorPkg.RenderInternalServerError(w, errors.New("internal server error"))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
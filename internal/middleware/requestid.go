@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+)
+
+const (
+	REQUEST_ID_HEADER string = "X-Request-ID"
+)
+
+// Incoming request ids are only reused if they look sane,
+// so clients can't inject junk into our logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Middleware that gives every request an id. The caller's X-Request-ID
+// is reused if it sends a valid one, otherwise a new id is generated.
+// The id is attached to the request context for logging and echoed
+// back on the response, where error responses also pick it up.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(REQUEST_ID_HEADER)
+		if !IsValidRequestID(requestID) {
+			requestID = NewRequestID()
+		}
+
+		w.Header().Set(REQUEST_ID_HEADER, requestID)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+func IsValidRequestID(requestID string) bool {
+	return validRequestID.MatchString(requestID)
+}
+
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
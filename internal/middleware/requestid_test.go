@@ -0,0 +1,56 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/middleware"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDReusesValidHeader(t *testing.T) {
+	var requestID string
+	handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = logger.RequestIDFromContext(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.Header.Set(middleware.REQUEST_ID_HEADER, "abc-123")
+	handler.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, "abc-123", requestID)
+	assert.EqualValues(t, "abc-123", rr.Header().Get(middleware.REQUEST_ID_HEADER))
+}
+
+func TestRequestIDReplacesInvalidHeader(t *testing.T) {
+	handler := middleware.RequestID(okHandler)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.Header.Set(middleware.REQUEST_ID_HEADER, "bad id\nwith newline")
+	handler.ServeHTTP(rr, req)
+
+	assert.Len(t, rr.Header().Get(middleware.REQUEST_ID_HEADER), 32)
+}
+
+func TestRequestIDInErrorBody(t *testing.T) {
+	handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorPkg.RenderBadRequestError(w, errors.New("missing city"))
+	}))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.Header.Set(middleware.REQUEST_ID_HEADER, "abc-123")
+	handler.ServeHTTP(rr, req)
+
+	var body errorPkg.Error
+	json.NewDecoder(rr.Body).Decode(&body)
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+	assert.EqualValues(t, "abc-123", body.RequestID)
+}
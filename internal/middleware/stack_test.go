@@ -0,0 +1,137 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/middleware"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	"github.com/bengimbel/go_redis_api/pkg/render"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovererRendersJsonError(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var list []int
+		_ = list[0]
+	})
+	handler := middleware.RequestID(middleware.Recoverer(panicking))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.Header.Set(middleware.REQUEST_ID_HEADER, "abc-123")
+	handler.ServeHTTP(rr, req)
+
+	var body errorPkg.Error
+	json.NewDecoder(rr.Body).Decode(&body)
+	assert.EqualValues(t, http.StatusInternalServerError, rr.Code)
+	assert.EqualValues(t, "abc-123", body.RequestID)
+}
+
+func TestCORSPreflight(t *testing.T) {
+	handler := middleware.NewCORS([]string{"https://example.com"}, time.Minute).Handler(okHandler)
+
+	// Allowed origin
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/api/weather", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	handler.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusNoContent, rr.Code)
+	assert.EqualValues(t, "https://example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.EqualValues(t, "60", rr.Header().Get("Access-Control-Max-Age"))
+
+	// Other origins get no CORS headers
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	handler.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCompressPrefersBrotli(t *testing.T) {
+	jsonHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"city":"` + strings.Repeat("chicago", 100) + `"}`))
+	})
+	handler := middleware.Compress(5)(jsonHandler)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	handler.ServeHTTP(rr, req)
+	assert.EqualValues(t, "br", rr.Header().Get("Content-Encoding"))
+
+	rr = httptest.NewRecorder()
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(rr, req)
+	assert.EqualValues(t, "gzip", rr.Header().Get("Content-Encoding"))
+}
+
+func TestMaxBodyBytesRejectsLargeBody(t *testing.T) {
+	handler := middleware.MaxBodyBytes(10)(okHandler)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/keys", strings.NewReader(strings.Repeat("a", 100)))
+	handler.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestTimeoutRendersGatewayTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	handler := middleware.Timeout(10 * time.Millisecond)(slow)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	handler.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusGatewayTimeout, rr.Code)
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	handler := middleware.SecurityHeaders(okHandler)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather", nil)
+	handler.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+	assert.EqualValues(t, "DENY", rr.Header().Get("X-Frame-Options"))
+	// Only sent over TLS
+	assert.Empty(t, rr.Header().Get("Strict-Transport-Security"))
+}
+
+func TestNegotiateRendersErrorsInFormat(t *testing.T) {
+	failing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errorPkg.RenderNotFoundError(w, errors.New("no weather for atlantis"))
+	})
+	handler := middleware.RequestID(middleware.Negotiate(render.NewDefaultRegistry())(failing))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/weather?city=atlantis", nil)
+	req.Header.Set("Accept", "text/csv")
+	req.Header.Set(middleware.REQUEST_ID_HEADER, "abc-123")
+	handler.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+	assert.EqualValues(t, "text/csv", rr.Header().Get("Content-Type"))
+	assert.EqualValues(t, "Accept", rr.Header().Get("Vary"))
+	assert.EqualValues(t, "code,message,request_id\n404,no weather for atlantis,abc-123\n", rr.Body.String())
+
+	// A format we don't have is refused in json
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/weather?city=atlantis&format=xml", nil))
+	var body errorPkg.Error
+	json.NewDecoder(rr.Body).Decode(&body)
+	assert.EqualValues(t, http.StatusNotAcceptable, rr.Code)
+	assert.EqualValues(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Contains(t, body.Message, "unsupported format")
+}
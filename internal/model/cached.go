@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// Where a response's weather came from
+const (
+	SOURCE_LOCAL    string = "local"
+	SOURCE_REDIS    string = "redis"
+	SOURCE_UPSTREAM string = "upstream"
+)
+
+// A city's weather as we cache it, with when it was fetched
+// from upstream and when the cached copy stops being fresh.
+// These drive the Last-Modified and Cache-Control headers.
+type CachedWeather struct {
+	Weather   WeatherResponse `json:"weather"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	// Name of the provider it was fetched from
+	Provider string `json:"provider"`
+	// Where this copy was read from, and whether it is a stale
+	// copy served because upstream was unavailable. These are
+	// set when it is read, so they aren't stored in the cache.
+	Source string `json:"source" msgpack:"-"`
+	Stale  bool   `json:"stale" msgpack:"-"`
+}
+
+// Wrap weather fetched from provider at fetchedAt, fresh for ttl
+func NewCachedWeather(weather WeatherResponse, provider string, fetchedAt time.Time, ttl time.Duration) CachedWeather {
+	fetchedAt = fetchedAt.UTC()
+	return CachedWeather{
+		Weather:   weather,
+		FetchedAt: fetchedAt,
+		ExpiresAt: fetchedAt.Add(ttl),
+		Provider:  provider,
+		Source:    SOURCE_UPSTREAM,
+	}
+}
+
+// How much longer the weather is fresh for, as of now.
+// Never negative, e.g. for a stale copy.
+func (cw CachedWeather) RemainingTTL(now time.Time) time.Duration {
+	remaining := cw.ExpiresAt.Sub(now)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
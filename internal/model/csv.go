@@ -0,0 +1,53 @@
+package model
+
+import (
+	"strconv"
+)
+
+var forecastCSVHeader = []string{
+	"city", "country", "dt", "dt_txt",
+	"temp", "feels_like", "temp_min", "temp_max", "pressure", "humidity",
+	"condition", "description", "icon", "clouds",
+	"wind_speed", "wind_deg", "wind_gust", "visibility", "pop",
+}
+
+// The forecast list as csv, one row per forecast with the
+// same names and units as the json. Only the first weather
+// condition of each forecast is kept.
+func (weather WeatherResponse) MarshalCSV() ([][]string, error) {
+	records := [][]string{forecastCSVHeader}
+	for _, item := range weather.List {
+		condition := Weather{}
+		if len(item.Weather) > 0 {
+			condition = item.Weather[0]
+		}
+
+		records = append(records, []string{
+			weather.City.Name,
+			weather.City.Country,
+			strconv.FormatInt(item.Dt, 10),
+			item.DtTxt,
+			formatFloat(item.Main.Temp),
+			formatFloat(item.Main.FeelsLike),
+			formatFloat(item.Main.TempMin),
+			formatFloat(item.Main.TempMax),
+			strconv.Itoa(int(item.Main.Pressure)),
+			strconv.Itoa(int(item.Main.Humidity)),
+			condition.Main,
+			condition.Description,
+			condition.Icon,
+			strconv.Itoa(int(item.Clouds.All)),
+			formatFloat(item.Wind.Speed),
+			formatFloat(item.Wind.Deg),
+			formatFloat(item.Wind.Gust),
+			strconv.Itoa(int(item.Visibility)),
+			formatFloat(item.Pop),
+		})
+	}
+
+	return records, nil
+}
+
+func formatFloat(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
@@ -0,0 +1,162 @@
+package model
+
+import "time"
+
+const (
+	KELVIN_OFFSET float32 = 273.15
+	DT_TXT_FORMAT string  = "2006-01-02 15:04:05"
+)
+
+// Normalized weather model that every provider maps into,
+// so the rest of the app doesn't depend on one provider's
+// response shape. Temperatures are in Celsius, speeds in
+// meters per second and times in Unix seconds (UTC).
+type Forecast struct {
+	Provider string          `json:"provider"`
+	Location Location        `json:"location"`
+	Entries  []ForecastEntry `json:"entries"`
+}
+
+type Location struct {
+	Name    string  `json:"name"`
+	State   string  `json:"state"`
+	Country string  `json:"country"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	// Offset from UTC in seconds
+	Timezone   int32 `json:"timezone"`
+	Population int64 `json:"population"`
+	Sunrise    int64 `json:"sunrise"`
+	Sunset     int64 `json:"sunset"`
+}
+
+type ForecastEntry struct {
+	Time        int64   `json:"time"`
+	Temp        float32 `json:"temp"`
+	FeelsLike   float32 `json:"feels_like"`
+	TempMin     float32 `json:"temp_min"`
+	TempMax     float32 `json:"temp_max"`
+	Pressure    int32   `json:"pressure"`
+	Humidity    int32   `json:"humidity"`
+	ConditionId int32   `json:"condition_id"`
+	Condition   string  `json:"condition"`
+	Description string  `json:"description"`
+	Icon        string  `json:"icon"`
+	Clouds      int32   `json:"clouds"`
+	WindSpeed   float32 `json:"wind_speed"`
+	WindDeg     float32 `json:"wind_deg"`
+	WindGust    float32 `json:"wind_gust"`
+	Visibility  int32   `json:"visibility"`
+	// Chance of precipitation from 0 to 1
+	PrecipitationChance float32 `json:"precipitation_chance"`
+}
+
+// Map an open weather map shaped response into the normalized model.
+// Open weather map returns temperatures in Kelvin by default.
+func (wr WeatherResponse) Normalize(provider string) Forecast {
+	forecast := Forecast{
+		Provider: provider,
+		Location: Location{
+			Name:       wr.City.Name,
+			Country:    wr.City.Country,
+			Lat:        wr.City.Coord.Lat,
+			Lon:        wr.City.Coord.Lon,
+			Timezone:   wr.City.Timezone,
+			Population: wr.City.Population,
+			Sunrise:    wr.City.Sunrise,
+			Sunset:     wr.City.Sunset,
+		},
+		Entries: []ForecastEntry{},
+	}
+
+	for _, item := range wr.List {
+		entry := ForecastEntry{
+			Time:                item.Dt,
+			Temp:                KelvinToCelsius(item.Main.Temp),
+			FeelsLike:           KelvinToCelsius(item.Main.FeelsLike),
+			TempMin:             KelvinToCelsius(item.Main.TempMin),
+			TempMax:             KelvinToCelsius(item.Main.TempMax),
+			Pressure:            item.Main.Pressure,
+			Humidity:            item.Main.Humidity,
+			Clouds:              item.Clouds.All,
+			WindSpeed:           item.Wind.Speed,
+			WindDeg:             item.Wind.Deg,
+			WindGust:            item.Wind.Gust,
+			Visibility:          item.Visibility,
+			PrecipitationChance: item.Pop,
+		}
+		if len(item.Weather) > 0 {
+			entry.ConditionId = item.Weather[0].Id
+			entry.Condition = item.Weather[0].Main
+			entry.Description = item.Weather[0].Description
+			entry.Icon = item.Weather[0].Icon
+		}
+		forecast.Entries = append(forecast.Entries, entry)
+	}
+
+	return forecast
+}
+
+// Map a normalized forecast back into the open weather map shaped
+// response our api returns, so every provider looks the same to clients.
+// Fields the normalized model doesn't carry are left empty.
+func NewWeatherResponse(forecast Forecast) WeatherResponse {
+	response := WeatherResponse{
+		City: City{
+			Name: forecast.Location.Name,
+			Coord: Coord{
+				Lat: forecast.Location.Lat,
+				Lon: forecast.Location.Lon,
+			},
+			Country:    forecast.Location.Country,
+			Population: forecast.Location.Population,
+			Timezone:   forecast.Location.Timezone,
+			Sunrise:    forecast.Location.Sunrise,
+			Sunset:     forecast.Location.Sunset,
+		},
+		List: []List{},
+	}
+
+	for _, entry := range forecast.Entries {
+		response.List = append(response.List, List{
+			Dt: entry.Time,
+			Main: Main{
+				Temp:      CelsiusToKelvin(entry.Temp),
+				FeelsLike: CelsiusToKelvin(entry.FeelsLike),
+				TempMin:   CelsiusToKelvin(entry.TempMin),
+				TempMax:   CelsiusToKelvin(entry.TempMax),
+				Pressure:  entry.Pressure,
+				Humidity:  entry.Humidity,
+			},
+			Weather: []Weather{
+				{
+					Id:          entry.ConditionId,
+					Main:        entry.Condition,
+					Description: entry.Description,
+					Icon:        entry.Icon,
+				},
+			},
+			Clouds: Clouds{
+				All: entry.Clouds,
+			},
+			Wind: Wind{
+				Speed: entry.WindSpeed,
+				Deg:   entry.WindDeg,
+				Gust:  entry.WindGust,
+			},
+			Visibility: entry.Visibility,
+			Pop:        entry.PrecipitationChance,
+			DtTxt:      time.Unix(entry.Time, 0).UTC().Format(DT_TXT_FORMAT),
+		})
+	}
+
+	return response
+}
+
+func KelvinToCelsius(kelvin float32) float32 {
+	return kelvin - KELVIN_OFFSET
+}
+
+func CelsiusToKelvin(celsius float32) float32 {
+	return celsius + KELVIN_OFFSET
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A place a name can refer to, from geocoding. The id is its
+// coordinates to four decimal places (about 11 meters), so it
+// is stable across lookups and can be used instead of a city
+// name to get the weather for exactly this place.
+type GeoLocation struct {
+	Id      string  `json:"id"`
+	Name    string  `json:"name"`
+	State   string  `json:"state,omitempty"`
+	Country string  `json:"country"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+}
+
+func NewGeoLocation(coordinates WeatherCoordinates) GeoLocation {
+	return GeoLocation{
+		Id:      LocationId(coordinates.Lat, coordinates.Lon),
+		Name:    coordinates.Name,
+		State:   coordinates.State,
+		Country: coordinates.Country,
+		Lat:     coordinates.Lat,
+		Lon:     coordinates.Lon,
+	}
+}
+
+// The canonical id for coordinates, like "45.5152,-122.6784"
+func LocationId(lat float64, lon float64) string {
+	return strconv.FormatFloat(lat, 'f', 4, 64) + "," + strconv.FormatFloat(lon, 'f', 4, 64)
+}
+
+// Coordinates from a location id. Ok is false if
+// it isn't one, e.g. because it is a city name.
+func ParseLocationId(id string) (float64, float64, bool) {
+	latValue, lonValue, found := strings.Cut(id, ",")
+	if !found {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latValue), 64)
+	if err != nil || math.IsNaN(lat) || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonValue), 64)
+	if err != nil || math.IsNaN(lon) || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
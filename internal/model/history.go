@@ -0,0 +1,101 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	DATE_FORMAT string = "2006-01-02"
+)
+
+// A city's weather snapshots between two times, newest
+// first, with its temperature summarized for each day
+type WeatherHistory struct {
+	City string    `json:"city"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// How many snapshots were in the range
+	Total int `json:"total"`
+	// More snapshots were in the range than were returned
+	Truncated bool               `json:"truncated"`
+	Snapshots []WeatherSnapshot  `json:"snapshots"`
+	Daily     []DailyTemperature `json:"daily"`
+}
+
+// The weather when a forecast was fetched. Only the nearest
+// forecast entry is kept, so snapshots stay small.
+type WeatherSnapshot struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Provider  string    `json:"provider"`
+	// The city's offset from UTC in seconds
+	Timezone int32         `json:"timezone"`
+	Forecast ForecastEntry `json:"forecast"`
+}
+
+// Temperatures in Celsius for one day, in the city's
+// time zone, from the nearest forecast in each snapshot
+type DailyTemperature struct {
+	Date    string  `json:"date"`
+	TempMin float32 `json:"temp_min"`
+	TempMax float32 `json:"temp_max"`
+	TempAvg float32 `json:"temp_avg"`
+	Samples int     `json:"samples"`
+}
+
+// Take a snapshot of fetched weather, or false
+// if it has no forecast to keep
+func NewWeatherSnapshot(weather CachedWeather) (WeatherSnapshot, bool) {
+	forecast := weather.Weather.Normalize(weather.Provider)
+	if len(forecast.Entries) == 0 {
+		return WeatherSnapshot{}, false
+	}
+	return WeatherSnapshot{
+		FetchedAt: weather.FetchedAt,
+		Provider:  weather.Provider,
+		Timezone:  forecast.Location.Timezone,
+		Forecast:  forecast.Entries[0],
+	}, true
+}
+
+// Build the history response from every snapshot in the
+// range, newest first. The days are summarized from all
+// of them, but only the newest limit are returned.
+func NewWeatherHistory(city string, from time.Time, to time.Time, snapshots []WeatherSnapshot, limit int) WeatherHistory {
+	history := WeatherHistory{
+		City:      city,
+		From:      from,
+		To:        to,
+		Total:     len(snapshots),
+		Truncated: len(snapshots) > limit,
+		Snapshots: snapshots[:min(len(snapshots), limit)],
+		Daily:     []DailyTemperature{},
+	}
+
+	days := map[string]int{}
+	sums := map[string]float64{}
+	for _, snapshot := range snapshots {
+		temp := snapshot.Forecast.Temp
+		zone := time.FixedZone("", int(snapshot.Timezone))
+		date := snapshot.FetchedAt.In(zone).Format(DATE_FORMAT)
+
+		i, ok := days[date]
+		if !ok {
+			i = len(history.Daily)
+			days[date] = i
+			history.Daily = append(history.Daily, DailyTemperature{
+				Date:    date,
+				TempMin: temp,
+				TempMax: temp,
+			})
+		}
+		day := &history.Daily[i]
+		day.TempMin = float32(math.Min(float64(day.TempMin), float64(temp)))
+		day.TempMax = float32(math.Max(float64(day.TempMax), float64(temp)))
+		day.Samples++
+		sums[date] += float64(temp)
+		day.TempAvg = float32(math.Round(sums[date]/float64(day.Samples)*100) / 100)
+	}
+
+	return history
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"github.com/bengimbel/go_redis_api/pkg/weatherProto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Map our models to their protobuf messages
+
+// Where the weather came from and how fresh it is
+func (weather CachedWeather) ProtoMeta() *weatherProto.Meta {
+	return &weatherProto.Meta{
+		Source:    weather.Source,
+		Provider:  weather.Provider,
+		FetchedAt: timestamppb.New(weather.FetchedAt),
+		ExpiresAt: timestamppb.New(weather.ExpiresAt),
+		Stale:     weather.Stale,
+	}
+}
+
+func (weather WeatherResponse) Proto() *weatherProto.WeatherResponse {
+	list := make([]*weatherProto.ForecastItem, len(weather.List))
+	for i, item := range weather.List {
+		list[i] = item.proto()
+	}
+
+	return &weatherProto.WeatherResponse{
+		City: &weatherProto.City{
+			Id:   weather.City.Id,
+			Name: weather.City.Name,
+			Coord: &weatherProto.Coord{
+				Lat: weather.City.Coord.Lat,
+				Lon: weather.City.Coord.Lon,
+			},
+			Country:    weather.City.Country,
+			Population: weather.City.Population,
+			Timezone:   weather.City.Timezone,
+			Sunrise:    weather.City.Sunrise,
+			Sunset:     weather.City.Sunset,
+		},
+		List: list,
+	}
+}
+
+func (item List) proto() *weatherProto.ForecastItem {
+	conditions := make([]*weatherProto.Condition, len(item.Weather))
+	for i, condition := range item.Weather {
+		conditions[i] = &weatherProto.Condition{
+			Id:          condition.Id,
+			Main:        condition.Main,
+			Description: condition.Description,
+			Icon:        condition.Icon,
+		}
+	}
+
+	return &weatherProto.ForecastItem{
+		Dt: item.Dt,
+		Main: &weatherProto.Main{
+			Temp:      item.Main.Temp,
+			FeelsLike: item.Main.FeelsLike,
+			TempMin:   item.Main.TempMin,
+			TempMax:   item.Main.TempMax,
+			Pressure:  item.Main.Pressure,
+			SeaLevel:  item.Main.SeaLevel,
+			GrndLevel: item.Main.GrndLevel,
+			Humidity:  item.Main.Humidity,
+			TempKf:    item.Main.TempKf,
+		},
+		Weather: conditions,
+		Clouds: &weatherProto.Clouds{
+			All: item.Clouds.All,
+		},
+		Wind: &weatherProto.Wind{
+			Speed: item.Wind.Speed,
+			Deg:   item.Wind.Deg,
+			Gust:  item.Wind.Gust,
+		},
+		Visibility: item.Visibility,
+		Pop:        item.Pop,
+		Sys: &weatherProto.Sys{
+			Pod: item.Sys.Pod,
+		},
+		DtTxt: item.DtTxt,
+	}
+}
+
+func (forecast Forecast) Proto() *weatherProto.Forecast {
+	entries := make([]*weatherProto.ForecastEntry, len(forecast.Entries))
+	for i, entry := range forecast.Entries {
+		entries[i] = &weatherProto.ForecastEntry{
+			Time:                entry.Time,
+			Temp:                entry.Temp,
+			FeelsLike:           entry.FeelsLike,
+			TempMin:             entry.TempMin,
+			TempMax:             entry.TempMax,
+			Pressure:            entry.Pressure,
+			Humidity:            entry.Humidity,
+			ConditionId:         entry.ConditionId,
+			Condition:           entry.Condition,
+			Description:         entry.Description,
+			Icon:                entry.Icon,
+			Clouds:              entry.Clouds,
+			WindSpeed:           entry.WindSpeed,
+			WindDeg:             entry.WindDeg,
+			WindGust:            entry.WindGust,
+			Visibility:          entry.Visibility,
+			PrecipitationChance: entry.PrecipitationChance,
+		}
+	}
+
+	return &weatherProto.Forecast{
+		Provider: forecast.Provider,
+		Location: &weatherProto.Location{
+			Name:       forecast.Location.Name,
+			State:      forecast.Location.State,
+			Country:    forecast.Location.Country,
+			Lat:        forecast.Location.Lat,
+			Lon:        forecast.Location.Lon,
+			Timezone:   forecast.Location.Timezone,
+			Population: forecast.Location.Population,
+			Sunrise:    forecast.Location.Sunrise,
+			Sunset:     forecast.Location.Sunset,
+		},
+		Entries: entries,
+	}
+}
+
+// Encode the weather as a protobuf WeatherResponse
+func (weather WeatherResponse) MarshalProto() ([]byte, error) {
+	return proto.Marshal(weather.Proto())
+}
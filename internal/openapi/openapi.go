@@ -0,0 +1,91 @@
+package openapi
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const (
+	SPEC_PATH string = "/openapi.json"
+	DOCS_PATH string = "/docs"
+
+	SWAGGER_UI_URL string = "https://unpkg.com/swagger-ui-dist@5.17.14"
+)
+
+// The OpenAPI 3 document for the api. The weather client
+// in pkg/weatherClient is generated from this file, so
+// regenerate it after changing a route.
+//
+//go:embed openapi.json
+var spec []byte
+
+// Swagger UI is loaded from a CDN, then started
+// with this script pointed at our spec
+var docsScript = fmt.Sprintf(`SwaggerUIBundle({ url: %q, dom_id: "#swagger-ui" });`, SPEC_PATH)
+
+var docsPage = []byte(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Weather API</title>
+  <link rel="stylesheet" href="` + SWAGGER_UI_URL + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="` + SWAGGER_UI_URL + `/swagger-ui-bundle.js"></script>
+  <script>` + docsScript + `</script>
+</body>
+</html>
+`)
+
+// Our security headers block everything, so the docs page
+// gets its own policy allowing Swagger UI from the CDN and
+// only our one inline script, by its hash. The trailing slash
+// allows everything under the Swagger UI path.
+var docsPolicy = fmt.Sprintf(
+	"default-src 'none'; script-src %[1]s/ 'sha256-%[2]s'; style-src %[1]s/ 'unsafe-inline'; img-src 'self' data: %[1]s/; connect-src 'self'; frame-ancestors 'none'",
+	SWAGGER_UI_URL, scriptHash(docsScript),
+)
+
+// The raw OpenAPI document
+func Spec() []byte {
+	return spec
+}
+
+// Parse and validate the OpenAPI document
+func Load(ctx context.Context) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load openapi spec: %w", err)
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return nil, fmt.Errorf("invalid openapi spec: %w", err)
+	}
+
+	return doc, nil
+}
+
+// Serve the OpenAPI document
+func HandleSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "max-age=300")
+	w.Write(spec)
+}
+
+// Serve Swagger UI for the OpenAPI document
+func HandleDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", docsPolicy)
+	w.Write(docsPage)
+}
+
+func scriptHash(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
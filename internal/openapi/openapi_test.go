@@ -0,0 +1,30 @@
+package openapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadValidatesSpec(t *testing.T) {
+	doc, err := openapi.Load(context.Background())
+
+	assert.Nil(t, err)
+	assert.NotNil(t, doc.Paths.Find("/api/weather"))
+	assert.NotNil(t, doc.Components.Schemas["WeatherResponse"])
+	assert.NotNil(t, doc.Components.Schemas["Error"])
+}
+
+func TestHandleDocsAllowsSwaggerUI(t *testing.T) {
+	rr := httptest.NewRecorder()
+	openapi.HandleDocs(rr, httptest.NewRequest(http.MethodGet, openapi.DOCS_PATH, nil))
+
+	policy := rr.Header().Get("Content-Security-Policy")
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), openapi.SPEC_PATH)
+	assert.Contains(t, policy, "script-src "+openapi.SWAGGER_UI_URL+"/ 'sha256-")
+}
@@ -0,0 +1,83 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/pkg/errorPkg"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// Checks requests and responses against the OpenAPI document.
+// It buffers every response, so it is meant for tests, where
+// it catches handlers drifting from the documented api.
+type Validator struct {
+	Router routers.Router
+}
+
+func NewValidator(doc *openapi3.T) (*Validator, error) {
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create openapi router: %w", err)
+	}
+
+	return &Validator{
+		Router: router,
+	}, nil
+}
+
+// Middleware that rejects requests that don't match the spec
+// with a 400, and replaces responses that don't with a 500.
+// Auth is left to our own middleware.
+func (v *Validator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := v.Router.FindRoute(r)
+		if err != nil {
+			errorPkg.RenderNotFoundError(w, fmt.Errorf("route is not in the openapi spec: %w", err))
+			return
+		}
+		options := &openapi3filter.Options{
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		}
+		requestInput := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), requestInput); err != nil {
+			errorPkg.RenderBadRequestError(w, fmt.Errorf("request does not match the openapi spec: %w", err))
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: requestInput,
+			Status:                 rec.Code,
+			Header:                 rec.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+			Options:                options,
+		}
+		if err := openapi3filter.ValidateResponse(context.WithoutCancel(r.Context()), responseInput); err != nil {
+			logger.FromContext(r.Context()).ErrorContext(r.Context(), "Response does not match the openapi spec", "path", r.URL.Path, "status", rec.Code, "error", err)
+			errorPkg.RenderInternalServerError(w, fmt.Errorf("response does not match the openapi spec: %w", err))
+			return
+		}
+
+		for key, values := range rec.Header() {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	})
+}
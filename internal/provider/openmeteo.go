@@ -0,0 +1,255 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/metrics"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+)
+
+const (
+	OPEN_METEO_GEOCODING_HOST string = "geocoding-api.open-meteo.com"
+	OPEN_METEO_FORECAST_HOST  string = "api.open-meteo.com"
+	OPEN_METEO_SEARCH_PATH    string = "/v1/search"
+	OPEN_METEO_FORECAST_PATH  string = "/v1/forecast"
+	OPEN_METEO_HOURLY         string = "temperature_2m,apparent_temperature,relative_humidity_2m,pressure_msl,weather_code,cloud_cover,wind_speed_10m,wind_direction_10m,wind_gusts_10m,visibility,precipitation_probability"
+)
+
+// Provider for open-meteo. It doesn't need an api key,
+// which makes it a good fallback for open weather map.
+type OpenMeteo struct {
+	GeocodingClient httpClient.HttpImplementor
+	ForecastClient  httpClient.HttpImplementor
+}
+
+type openMeteoSearchResponse struct {
+	Results []openMeteoPlace `json:"results"`
+}
+
+type openMeteoForecastResponse struct {
+	UtcOffsetSeconds int32 `json:"utc_offset_seconds"`
+	Hourly           struct {
+		Time                     []int64   `json:"time"`
+		Temperature2m            []float32 `json:"temperature_2m"`
+		ApparentTemperature      []float32 `json:"apparent_temperature"`
+		RelativeHumidity2m       []float32 `json:"relative_humidity_2m"`
+		PressureMsl              []float32 `json:"pressure_msl"`
+		WeatherCode              []int32   `json:"weather_code"`
+		CloudCover               []float32 `json:"cloud_cover"`
+		WindSpeed10m             []float32 `json:"wind_speed_10m"`
+		WindDirection10m         []float32 `json:"wind_direction_10m"`
+		WindGusts10m             []float32 `json:"wind_gusts_10m"`
+		Visibility               []float32 `json:"visibility"`
+		PrecipitationProbability []float32 `json:"precipitation_probability"`
+	} `json:"hourly"`
+	Daily struct {
+		Sunrise []int64 `json:"sunrise"`
+		Sunset  []int64 `json:"sunset"`
+	} `json:"daily"`
+}
+
+// Open weather map style condition for a WMO weather code
+type wmoCondition struct {
+	id          int32
+	main        string
+	description string
+	icon        string
+}
+
+var wmoConditions = map[int32]wmoCondition{
+	0:  {800, "Clear", "clear sky", "01"},
+	1:  {801, "Clouds", "few clouds", "02"},
+	2:  {802, "Clouds", "scattered clouds", "03"},
+	3:  {804, "Clouds", "overcast clouds", "04"},
+	45: {741, "Fog", "fog", "50"},
+	48: {741, "Fog", "depositing rime fog", "50"},
+	51: {300, "Drizzle", "light drizzle", "09"},
+	53: {301, "Drizzle", "drizzle", "09"},
+	55: {302, "Drizzle", "heavy drizzle", "09"},
+	56: {300, "Drizzle", "light freezing drizzle", "09"},
+	57: {302, "Drizzle", "freezing drizzle", "09"},
+	61: {500, "Rain", "light rain", "10"},
+	63: {501, "Rain", "moderate rain", "10"},
+	65: {502, "Rain", "heavy rain", "10"},
+	66: {511, "Rain", "light freezing rain", "13"},
+	67: {511, "Rain", "freezing rain", "13"},
+	71: {600, "Snow", "light snow", "13"},
+	73: {601, "Snow", "snow", "13"},
+	75: {602, "Snow", "heavy snow", "13"},
+	77: {600, "Snow", "snow grains", "13"},
+	80: {520, "Rain", "light shower rain", "09"},
+	81: {521, "Rain", "shower rain", "09"},
+	82: {522, "Rain", "heavy shower rain", "09"},
+	85: {620, "Snow", "light shower snow", "13"},
+	86: {621, "Snow", "shower snow", "13"},
+	95: {211, "Thunderstorm", "thunderstorm", "11"},
+	96: {201, "Thunderstorm", "thunderstorm with hail", "11"},
+	99: {202, "Thunderstorm", "thunderstorm with heavy hail", "11"},
+}
+
+func NewOpenMeteo() *OpenMeteo {
+	geocodingClient := httpClient.NewHttpClientWithHost(OPEN_METEO_GEOCODING_HOST, nil)
+	geocodingClient.OnRequest = metrics.ObserveUpstreamRequest
+	forecastClient := httpClient.NewHttpClientWithHost(OPEN_METEO_FORECAST_HOST, nil)
+	forecastClient.OnRequest = metrics.ObserveUpstreamRequest
+
+	return &OpenMeteo{
+		GeocodingClient: geocodingClient,
+		ForecastClient:  forecastClient,
+	}
+}
+
+func (om *OpenMeteo) Name() string {
+	return OPEN_METEO
+}
+
+// Check both open meteo apis are reachable
+func (om *OpenMeteo) Ping(ctx context.Context) error {
+	return pingClients(ctx, om.GeocodingClient, om.ForecastClient)
+}
+
+// Look up the city, then fetch its hourly forecast and return
+// the entry for the current hour, like open weather map does.
+// A location id already has the coordinates, so there is
+// no lookup, but the weather has no place name either.
+func (om *OpenMeteo) RetrieveWeather(ctx context.Context, city string) (model.WeatherResponse, error) {
+	place, err := om.findPlace(ctx, city)
+	if err != nil {
+		return model.WeatherResponse{}, err
+	}
+
+	forecast := openMeteoForecastResponse{}
+	if err := om.ForecastClient.MakeWeatherRequest(ctx, &httpClient.HttpConfig{
+		Path: OPEN_METEO_FORECAST_PATH,
+		Query: []httpClient.QueryParams{
+			{Key: "latitude", Value: fmt.Sprintf("%f", place.Latitude)},
+			{Key: "longitude", Value: fmt.Sprintf("%f", place.Longitude)},
+			{Key: "hourly", Value: OPEN_METEO_HOURLY},
+			{Key: "daily", Value: "sunrise,sunset"},
+			{Key: "timezone", Value: "auto"},
+			{Key: "timeformat", Value: "unixtime"},
+			{Key: "wind_speed_unit", Value: "ms"},
+			{Key: "forecast_days", Value: "2"},
+		},
+	}, &forecast); err != nil {
+		return model.WeatherResponse{}, fmt.Errorf("Error fetching weather from open-meteo: %w", err)
+	}
+
+	normalized := model.Forecast{
+		Provider: OPEN_METEO,
+		Location: model.Location{
+			Name:       place.Name,
+			State:      place.Admin1,
+			Country:    strings.ToUpper(place.CountryCode),
+			Lat:        place.Latitude,
+			Lon:        place.Longitude,
+			Timezone:   forecast.UtcOffsetSeconds,
+			Population: place.Population,
+		},
+	}
+	if len(forecast.Daily.Sunrise) > 0 && len(forecast.Daily.Sunset) > 0 {
+		normalized.Location.Sunrise = forecast.Daily.Sunrise[0]
+		normalized.Location.Sunset = forecast.Daily.Sunset[0]
+	}
+
+	index := currentHour(forecast.Hourly.Time, time.Now().Unix())
+	if index < 0 {
+		return model.WeatherResponse{}, fmt.Errorf("Error fetching weather from open-meteo: no forecast for %s", city)
+	}
+	normalized.Entries = []model.ForecastEntry{normalizeOpenMeteoHour(forecast, index, normalized.Location)}
+
+	return model.NewWeatherResponse(normalized), nil
+}
+
+type openMeteoPlace struct {
+	Name        string  `json:"name"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	CountryCode string  `json:"country_code"`
+	Admin1      string  `json:"admin1"`
+	Population  int64   `json:"population"`
+}
+
+func (om *OpenMeteo) findPlace(ctx context.Context, city string) (openMeteoPlace, error) {
+	if lat, lon, ok := model.ParseLocationId(city); ok {
+		return openMeteoPlace{Latitude: lat, Longitude: lon}, nil
+	}
+
+	search := openMeteoSearchResponse{}
+	if err := om.GeocodingClient.MakeWeatherRequest(ctx, &httpClient.HttpConfig{
+		Path: OPEN_METEO_SEARCH_PATH,
+		Query: []httpClient.QueryParams{
+			{Key: "name", Value: city},
+			{Key: "count", Value: "1"},
+		},
+	}, &search); err != nil {
+		return openMeteoPlace{}, fmt.Errorf("Error fetching city from open-meteo: %w", err)
+	}
+	if len(search.Results) == 0 {
+		return openMeteoPlace{}, fmt.Errorf("Error fetching city coordinates by name: %s", city)
+	}
+	return search.Results[0], nil
+}
+
+// Find the latest hour that has started, or the first hour if
+// they are all in the future. Returns -1 if there are no hours.
+func currentHour(times []int64, now int64) int {
+	if len(times) == 0 {
+		return -1
+	}
+	index := 0
+	for i, t := range times {
+		if t > now {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+func normalizeOpenMeteoHour(forecast openMeteoForecastResponse, i int, location model.Location) model.ForecastEntry {
+	hourly := forecast.Hourly
+	entry := model.ForecastEntry{
+		Time:                hourly.Time[i],
+		Temp:                valueAt(hourly.Temperature2m, i),
+		FeelsLike:           valueAt(hourly.ApparentTemperature, i),
+		TempMin:             valueAt(hourly.Temperature2m, i),
+		TempMax:             valueAt(hourly.Temperature2m, i),
+		Pressure:            int32(valueAt(hourly.PressureMsl, i)),
+		Humidity:            int32(valueAt(hourly.RelativeHumidity2m, i)),
+		Clouds:              int32(valueAt(hourly.CloudCover, i)),
+		WindSpeed:           valueAt(hourly.WindSpeed10m, i),
+		WindDeg:             valueAt(hourly.WindDirection10m, i),
+		WindGust:            valueAt(hourly.WindGusts10m, i),
+		Visibility:          int32(valueAt(hourly.Visibility, i)),
+		PrecipitationChance: valueAt(hourly.PrecipitationProbability, i) / 100,
+	}
+
+	var code int32
+	if i < len(hourly.WeatherCode) {
+		code = hourly.WeatherCode[i]
+	}
+	if condition, ok := wmoConditions[code]; ok {
+		suffix := "n"
+		if location.Sunrise <= entry.Time && entry.Time < location.Sunset {
+			suffix = "d"
+		}
+		entry.ConditionId = condition.id
+		entry.Condition = condition.main
+		entry.Description = condition.description
+		entry.Icon = condition.icon + suffix
+	}
+
+	return entry
+}
+
+func valueAt(values []float32, i int) float32 {
+	if i < len(values) {
+		return values[i]
+	}
+	return 0
+}
@@ -0,0 +1,239 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/metrics"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/secrets"
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	FETCH_COORDIANTES_PATH string = "/geo/1.0/direct"
+	REVERSE_GEOCODE_PATH   string = "/geo/1.0/reverse"
+	FETCH_WEATHER_PATH     string = "/data/2.5/forecast"
+	QUERY_PARAM_LAT        string = "lat"
+	QUERY_PARAM_LON        string = "lon"
+	QUERY_PARAM_Q          string = "q"
+	QUERY_PARAM_LIMIT      string = "limit"
+	// Open weather map returns at most five places
+	MAX_GEO_RESULTS int = 5
+)
+
+// Provider for open weather map. Calls go through our
+// custom http client, which shares the upstream quota
+// and adds an api key from Keys to each of them.
+type OpenWeatherMap struct {
+	HttpClient httpClient.HttpImplementor
+	Keys       *secrets.KeyRing
+	// How often the keys are reloaded, 0 if they can't change
+	KeyReloadInterval time.Duration
+}
+
+func NewOpenWeatherMap(rds *redis.Client, cfg config.UpstreamConfig, log *slog.Logger) (*OpenWeatherMap, error) {
+	source, err := secrets.NewProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := secrets.NewKeyRing(source, cfg.KeyStrategy)
+	if err != nil {
+		return nil, err
+	}
+	if keys.Len() == 0 {
+		log.Warn("No open weather map api keys, calls to it will fail", "source", cfg.KeySource)
+	}
+
+	client := httpClient.NewHttpClient(
+		httpClient.NewRedisRateLimiter(rds, cfg.RatePerMinute, cfg.Burst, cfg.DailyQuota, log),
+	)
+	client.Keys = keys
+	client.OnRequest = metrics.ObserveUpstreamRequest
+
+	owm := &OpenWeatherMap{
+		HttpClient: client,
+		Keys:       keys,
+	}
+	// Only a file can change without a restart
+	if cfg.KeySource == secrets.SOURCE_FILE {
+		owm.KeyReloadInterval = cfg.KeyReloadInterval
+	}
+	return owm, nil
+}
+
+func (owm *OpenWeatherMap) Name() string {
+	return OPEN_WEATHER_MAP
+}
+
+// Reload the api keys every KeyReloadInterval
+// until the context is cancelled
+func (owm *OpenWeatherMap) WatchSecrets(ctx context.Context) {
+	if owm.Keys == nil || owm.KeyReloadInterval <= 0 {
+		return
+	}
+	owm.Keys.Start(ctx, owm.KeyReloadInterval)
+}
+
+// Check the open weather map api is reachable
+func (owm *OpenWeatherMap) Ping(ctx context.Context) error {
+	return pingClients(ctx, owm.HttpClient)
+}
+
+// Build request struct for fetching city coordinates
+func BuildLatLonRequest(city string) *httpClient.HttpConfig {
+	return &httpClient.HttpConfig{
+		Path: FETCH_COORDIANTES_PATH,
+		Query: []httpClient.QueryParams{
+			{
+				Key:   QUERY_PARAM_Q,
+				Value: city,
+			},
+		},
+	}
+}
+
+// Build request struct for every place matching a name,
+// which can be narrowed like "portland,or,us"
+func BuildSearchRequest(query string, limit int) *httpClient.HttpConfig {
+	return &httpClient.HttpConfig{
+		Path: FETCH_COORDIANTES_PATH,
+		Query: []httpClient.QueryParams{
+			{
+				Key:   QUERY_PARAM_Q,
+				Value: query,
+			},
+			{
+				Key:   QUERY_PARAM_LIMIT,
+				Value: strconv.Itoa(limit),
+			},
+		},
+	}
+}
+
+// Build request struct for the places at coordinates
+func BuildReverseRequest(lat float64, lon float64, limit int) *httpClient.HttpConfig {
+	return &httpClient.HttpConfig{
+		Path: REVERSE_GEOCODE_PATH,
+		Query: []httpClient.QueryParams{
+			{
+				Key:   QUERY_PARAM_LAT,
+				Value: fmt.Sprintf("%f", lat),
+			},
+			{
+				Key:   QUERY_PARAM_LON,
+				Value: fmt.Sprintf("%f", lon),
+			},
+			{
+				Key:   QUERY_PARAM_LIMIT,
+				Value: strconv.Itoa(limit),
+			},
+		},
+	}
+}
+
+// Build request struct for fetching city's weather from coordinate request
+func BuildCityWeatherRequest(coordinates model.WeatherCoordinates) *httpClient.HttpConfig {
+	return &httpClient.HttpConfig{
+		Path: FETCH_WEATHER_PATH,
+		Query: []httpClient.QueryParams{
+			{
+				Key:   QUERY_PARAM_LAT,
+				Value: fmt.Sprintf("%f", coordinates.Lat),
+			},
+			{
+				Key:   QUERY_PARAM_LON,
+				Value: fmt.Sprintf("%f", coordinates.Lon),
+			},
+		},
+	}
+}
+
+// Fetch city coordinates using the weatherHTTPClient.
+// If network error, return it. If no results, return a basic error
+// If no error, return results
+func (owm *OpenWeatherMap) FetchCoordinates(ctx context.Context, config *httpClient.HttpConfig) ([]model.WeatherCoordinates, error) {
+	weatherCoordinates := []model.WeatherCoordinates{}
+
+	if err := owm.HttpClient.MakeWeatherRequest(ctx, config, &weatherCoordinates); err != nil {
+		// Pass quota errors through so callers can fall back to stale results
+		var quotaErr *httpClient.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			return weatherCoordinates, err
+		}
+		return weatherCoordinates, fmt.Errorf("Error fetching coordinates: %w", err)
+	} else if len(weatherCoordinates) == 0 {
+		return weatherCoordinates, fmt.Errorf("Error fetching city coordinates by name: %s", config.Query[0].Value)
+	}
+
+	return weatherCoordinates, nil
+}
+
+// Fetch city's weather using lat lon from above request
+// using the weatherHTTPClient.
+// If network error, return it. If no error, return results
+func (owm *OpenWeatherMap) FetchWeatherByCity(ctx context.Context, config *httpClient.HttpConfig) (model.WeatherResponse, error) {
+	weatherResponse := model.WeatherResponse{}
+
+	if err := owm.HttpClient.MakeWeatherRequest(ctx, config, &weatherResponse); err != nil {
+		return weatherResponse, fmt.Errorf("Error fetching city by coordinates: %w", err)
+	}
+
+	// An error body (e.g. a bad api key) decodes to an empty list
+	if len(weatherResponse.List) == 0 {
+		return weatherResponse, errors.New("Error fetching city by coordinates: no forecast returned")
+	}
+
+	// Just saving and returning first entry in the list of results
+	weatherResponse.List = []model.List{weatherResponse.List[0]}
+
+	return weatherResponse, nil
+}
+
+// Every place matching a name, up to limit. No
+// matches is an empty list rather than an error.
+func (owm *OpenWeatherMap) SearchLocations(ctx context.Context, query string, limit int) ([]model.GeoLocation, error) {
+	return owm.fetchLocations(ctx, BuildSearchRequest(query, limit))
+}
+
+// The places at coordinates, nearest first, up to limit
+func (owm *OpenWeatherMap) ReverseGeocode(ctx context.Context, lat float64, lon float64, limit int) ([]model.GeoLocation, error) {
+	return owm.fetchLocations(ctx, BuildReverseRequest(lat, lon, limit))
+}
+
+func (owm *OpenWeatherMap) fetchLocations(ctx context.Context, config *httpClient.HttpConfig) ([]model.GeoLocation, error) {
+	coordinates := []model.WeatherCoordinates{}
+	if err := owm.HttpClient.MakeWeatherRequest(ctx, config, &coordinates); err != nil {
+		return nil, fmt.Errorf("Error fetching locations: %w", err)
+	}
+
+	locations := make([]model.GeoLocation, len(coordinates))
+	for i, c := range coordinates {
+		locations[i] = model.NewGeoLocation(c)
+	}
+	return locations, nil
+}
+
+// We need to make two network requests because we first need
+// to fetch city coordinates (lat lon) by city name
+// then using the lat lon we can fetch the weather.
+// A location id already has the coordinates, so
+// it skips straight to fetching the weather.
+func (owm *OpenWeatherMap) RetrieveWeather(ctx context.Context, city string) (model.WeatherResponse, error) {
+	if lat, lon, ok := model.ParseLocationId(city); ok {
+		return owm.FetchWeatherByCity(ctx, BuildCityWeatherRequest(model.WeatherCoordinates{Lat: lat, Lon: lon}))
+	}
+
+	coordinates, err := owm.FetchCoordinates(ctx, BuildLatLonRequest(city))
+	if err != nil {
+		return model.WeatherResponse{}, err
+	}
+
+	return owm.FetchWeatherByCity(ctx, BuildCityWeatherRequest(coordinates[0]))
+}
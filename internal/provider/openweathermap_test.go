@@ -0,0 +1,214 @@
+package provider_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/provider"
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockHttpClient struct {
+	httpClient.HttpImplementor
+	mock.Mock
+}
+
+func (mhc *MockHttpClient) MakeWeatherRequest(ctx context.Context, config *httpClient.HttpConfig, responseStruct interface{}) error {
+	args := mhc.Called(ctx, config, responseStruct)
+	return args.Error(0)
+}
+
+var mockClient = &MockHttpClient{}
+
+var mockOpenWeatherMap = provider.OpenWeatherMap{
+	HttpClient: mockClient,
+}
+
+func TestFetchCoordinatesSuccess(t *testing.T) {
+	ctx := context.Background()
+	expected := []model.WeatherCoordinates{
+		{
+			Lat: 123.123000,
+			Lon: 456.456000,
+		},
+	}
+	httpConfig := &httpClient.HttpConfig{
+		Path: provider.FETCH_COORDIANTES_PATH,
+		Query: []httpClient.QueryParams{
+			{
+				Key:   provider.QUERY_PARAM_Q,
+				Value: "chicago",
+			},
+		},
+	}
+	coordinates := []model.WeatherCoordinates{}
+	mockClient.On("MakeWeatherRequest", ctx, httpConfig, &coordinates).Return(nil).Once().Return(nil).Once().Run(func(args mock.Arguments) {
+		arg := args.Get(2).(*[]model.WeatherCoordinates)
+		*arg = append(*arg, model.WeatherCoordinates{
+			Lat: 123.123000,
+			Lon: 456.456000,
+		})
+	})
+	actual, _ := mockOpenWeatherMap.FetchCoordinates(ctx, httpConfig)
+
+	assert.EqualValues(t, expected, actual)
+}
+
+func TestFetchWeatherSuccess(t *testing.T) {
+	ctx := context.Background()
+	expected := model.WeatherResponse{
+		City: model.City{
+			Name: "chicago",
+		},
+		List: []model.List{
+			{
+				Dt: 123,
+			},
+		},
+	}
+	httpConfig := &httpClient.HttpConfig{
+		Path: provider.FETCH_WEATHER_PATH,
+		Query: []httpClient.QueryParams{
+			{
+				Key:   provider.QUERY_PARAM_LAT,
+				Value: "123.123000",
+			},
+			{
+				Key:   provider.QUERY_PARAM_LON,
+				Value: "456.456000",
+			},
+		},
+	}
+	weather := model.WeatherResponse{}
+	mockClient.On("MakeWeatherRequest", ctx, httpConfig, &weather).Return(nil).Once().Return(nil).Once().Run(func(args mock.Arguments) {
+		arg := args.Get(2).(*model.WeatherResponse)
+		arg.City.Name = "chicago"
+		arg.List = []model.List{
+			{
+				Dt: 123,
+			},
+		}
+	})
+	actual, _ := mockOpenWeatherMap.FetchWeatherByCity(ctx, httpConfig)
+
+	assert.EqualValues(t, expected, actual)
+}
+
+func TestFetchWeatherEmptyList(t *testing.T) {
+	ctx := context.Background()
+	httpConfig := &httpClient.HttpConfig{
+		Path: provider.FETCH_WEATHER_PATH,
+		Query: []httpClient.QueryParams{
+			{
+				Key:   provider.QUERY_PARAM_LAT,
+				Value: "0.000000",
+			},
+		},
+	}
+	weather := model.WeatherResponse{}
+	mockClient.On("MakeWeatherRequest", ctx, httpConfig, &weather).Return(nil).Once()
+	_, err := mockOpenWeatherMap.FetchWeatherByCity(ctx, httpConfig)
+
+	assert.NotNil(t, err)
+}
+
+func TestRetrieveWeatherSuccess(t *testing.T) {
+	ctx := context.Background()
+	expected := model.WeatherResponse{
+		City: model.City{
+			Name: "chicago",
+		},
+		List: []model.List{
+			{
+				Dt: 123,
+			},
+		},
+	}
+	coordinateConfig := &httpClient.HttpConfig{
+		Path: provider.FETCH_COORDIANTES_PATH,
+		Query: []httpClient.QueryParams{
+			{
+				Key:   provider.QUERY_PARAM_Q,
+				Value: "chicago",
+			},
+		},
+	}
+	weatherConfig := &httpClient.HttpConfig{
+		Path: provider.FETCH_WEATHER_PATH,
+		Query: []httpClient.QueryParams{
+			{
+				Key:   provider.QUERY_PARAM_LAT,
+				Value: "123.123000",
+			},
+			{
+				Key:   provider.QUERY_PARAM_LON,
+				Value: "456.456000",
+			},
+		},
+	}
+	coordinates := []model.WeatherCoordinates{}
+	weather := model.WeatherResponse{}
+	mockClient.On("MakeWeatherRequest", ctx, coordinateConfig, &coordinates).Return(nil).Once().Return(nil).Once().Run(func(args mock.Arguments) {
+		arg := args.Get(2).(*[]model.WeatherCoordinates)
+		*arg = append(*arg, model.WeatherCoordinates{
+			Lat: 123.123000,
+			Lon: 456.456000,
+		})
+	})
+	mockClient.On("MakeWeatherRequest", ctx, weatherConfig, &weather).Return(nil).Once().Return(nil).Once().Run(func(args mock.Arguments) {
+		arg := args.Get(2).(*model.WeatherResponse)
+		arg.City.Name = "chicago"
+		arg.List = []model.List{
+			{
+				Dt: 123,
+			},
+		}
+	})
+
+	actual, _ := mockOpenWeatherMap.RetrieveWeather(ctx, "chicago")
+
+	assert.EqualValues(t, expected, actual)
+}
+
+func TestSearchLocationsReturnsEveryMatch(t *testing.T) {
+	ctx := context.Background()
+	client := &MockHttpClient{}
+	owm := provider.OpenWeatherMap{HttpClient: client}
+	coordinates := []model.WeatherCoordinates{}
+	client.On("MakeWeatherRequest", ctx, provider.BuildSearchRequest("portland,us", 5), &coordinates).Return(nil).Once().Run(func(args mock.Arguments) {
+		arg := args.Get(2).(*[]model.WeatherCoordinates)
+		*arg = append(*arg,
+			model.WeatherCoordinates{Name: "Portland", State: "Oregon", Country: "US", Lat: 45.5202471, Lon: -122.674194},
+			model.WeatherCoordinates{Name: "Portland", State: "Maine", Country: "US", Lat: 43.6573605, Lon: -70.2586618},
+		)
+	})
+
+	actual, err := owm.SearchLocations(ctx, "portland,us", 5)
+
+	assert.NoError(t, err)
+	assert.Len(t, actual, 2)
+	assert.Equal(t, "45.5202,-122.6742", actual[0].Id)
+	assert.Equal(t, "Maine", actual[1].State)
+}
+
+func TestRetrieveWeatherByLocationIdSkipsGeocoding(t *testing.T) {
+	ctx := context.Background()
+	client := &MockHttpClient{}
+	owm := provider.OpenWeatherMap{HttpClient: client}
+	weather := model.WeatherResponse{}
+	config := provider.BuildCityWeatherRequest(model.WeatherCoordinates{Lat: 43.6574, Lon: -70.2587})
+	client.On("MakeWeatherRequest", ctx, config, &weather).Return(nil).Once().Run(func(args mock.Arguments) {
+		arg := args.Get(2).(*model.WeatherResponse)
+		arg.City.Name = "Portland"
+		arg.List = []model.List{{Dt: 123}}
+	})
+
+	actual, err := owm.RetrieveWeather(ctx, "43.6574,-70.2587")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Portland", actual.City.Name)
+	client.AssertExpectations(t)
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	OPEN_WEATHER_MAP string = "openweathermap"
+	OPEN_METEO       string = "openmeteo"
+)
+
+// A source of weather data. Providers fetch a city's forecast
+// from their own api and return it in the response shape our
+// api serves, mapping through model.Forecast if they need to.
+type WeatherProvider interface {
+	Name() string
+	RetrieveWeather(context.Context, string) (model.WeatherResponse, error)
+}
+
+// Providers that can cheaply check their api
+// is reachable, without using up any quota
+type Pinger interface {
+	Ping(context.Context) error
+}
+
+// Providers with secrets that can change while we
+// run. This blocks until the context is cancelled.
+type SecretWatcher interface {
+	WatchSecrets(context.Context)
+}
+
+// Providers that can look up places by name, and by
+// coordinates, returning every match rather than the first
+type Geocoder interface {
+	SearchLocations(context.Context, string, int) ([]model.GeoLocation, error)
+	ReverseGeocode(context.Context, float64, float64, int) ([]model.GeoLocation, error)
+}
+
+// Create a provider by name
+func NewProvider(name string, rds *redis.Client, cfg *config.Config, log *slog.Logger) (WeatherProvider, error) {
+	switch name {
+	case OPEN_WEATHER_MAP:
+		return NewOpenWeatherMap(rds, cfg.Upstream, log)
+	case OPEN_METEO:
+		return NewOpenMeteo(), nil
+	default:
+		return nil, fmt.Errorf("unknown weather provider: %s", name)
+	}
+}
+
+// Ping every client that supports it
+func pingClients(ctx context.Context, clients ...httpClient.HttpImplementor) error {
+	for _, client := range clients {
+		if pinger, ok := client.(Pinger); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
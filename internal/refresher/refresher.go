@@ -0,0 +1,116 @@
+package refresher
+
+import (
+	"context"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	LEADER_LOCK_KEY string = "weather:refresher:leader"
+)
+
+// Background job that keeps the most requested
+// cities fresh in the cache. Every replica runs one,
+// but only the replica holding the leader lock does any work.
+type Refresher struct {
+	Service  service.WeatherServiceImplementor
+	Lock     repository.LockImplementor
+	Interval time.Duration
+	TopN     int
+	// Optional hook called once the first refresh
+	// has finished, e.g. to mark warmup as done
+	OnWarm func()
+}
+
+// Create a new Refresher sharing the app's weather service.
+// An interval that isn't above zero would panic the ticker,
+// so it falls back to the default. Redis rejects a lock TTL
+// that isn't above zero, so it falls back to two intervals.
+func NewRefresher(rds *redis.Client, svc service.WeatherServiceImplementor, cfg config.RefresherConfig) *Refresher {
+	if cfg.Interval <= 0 {
+		cfg.Interval = config.DEFAULT_REFRESH_INTERVAL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = 2 * cfg.Interval
+	}
+	return &Refresher{
+		Service:  svc,
+		Lock:     repository.NewRedisLock(rds, LEADER_LOCK_KEY, cfg.LockTTL),
+		Interval: cfg.Interval,
+		TopN:     cfg.TopN,
+	}
+}
+
+// Start the refresher. This blocks until the context is
+// cancelled, so it should be run on its own go-routine.
+// We refresh once right away, then on every tick.
+func (rf *Refresher) Start(ctx context.Context) {
+	ticker := time.NewTicker(rf.Interval)
+	defer ticker.Stop()
+
+	// Give up leadership on shutdown so another
+	// replica can take over without waiting for the TTL.
+	defer func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if err := rf.Lock.Release(releaseCtx); err != nil {
+			logger.FromContext(ctx).Error("Failed to release refresher lock", "error", err)
+		}
+	}()
+
+	warm := false
+	for {
+		rf.RunOnce(ctx)
+		if !warm && rf.OnWarm != nil {
+			rf.OnWarm()
+		}
+		warm = true
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Take (or renew) the leader lock, and if we are
+// the leader re-fetch the top N cities into the cache.
+// Returns how many cities were refreshed.
+func (rf *Refresher) RunOnce(ctx context.Context) int {
+	isLeader, err := rf.Lock.Acquire(ctx)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "Failed to acquire refresher lock", "error", err)
+		return 0
+	}
+	if !isLeader {
+		return 0
+	}
+
+	cities, err := rf.Service.PopularCities(ctx, rf.TopN)
+	if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "Failed to get popular cities", "error", err)
+		return 0
+	}
+
+	refreshed := 0
+	for _, city := range cities {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := rf.Service.RefreshWeather(ctx, city); err != nil {
+			logger.FromContext(ctx).WarnContext(ctx, "Failed to refresh city weather", "city", city, "error", err)
+			continue
+		}
+		refreshed++
+	}
+
+	logger.FromContext(ctx).InfoContext(ctx, "Refreshed popular cities", "refreshed", refreshed, "cities", len(cities))
+	return refreshed
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/go-redis/cache/v9"
+)
+
+const (
+	GEO_KEY_PREFIX string = "geo:"
+	// Places don't move, so lookups are kept for a day
+	GEO_CACHE_TTL time.Duration = 24 * time.Hour
+)
+
+type GeoCacheImplementor interface {
+	FindLocations(context.Context, string) ([]model.GeoLocation, error)
+	InsertLocations(context.Context, string, []model.GeoLocation) error
+}
+
+// Get the cached results of a geocoding lookup. Like
+// weather, only the local tier is used while redis is down.
+func (rds *RedisRepo) FindLocations(ctx context.Context, key string) ([]model.GeoLocation, error) {
+	locations := []model.GeoLocation{}
+	key = GEO_KEY_PREFIX + strings.ToLower(key)
+
+	c := rds.Cache
+	if !rds.available() {
+		c = rds.LocalOnly
+	}
+	if err := c.Get(ctx, key, &locations); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			return nil, fmt.Errorf("Could not find locations in cache: %s", key)
+		}
+		if IsConnectionError(err) {
+			rds.reportError(err)
+			return nil, fmt.Errorf("Could not look up locations in redis cache: %s: %w", key, ErrRedisUnavailable)
+		}
+		return nil, fmt.Errorf("Could not look up locations in redis cache: %s: %w", key, err)
+	}
+
+	return locations, nil
+}
+
+// Cache the results of a geocoding lookup, even if there were
+// none, so repeated lookups of a name that matches nothing
+// don't use up upstream quota either
+func (rds *RedisRepo) InsertLocations(ctx context.Context, key string, locations []model.GeoLocation) error {
+	item := &cache.Item{
+		Ctx:   ctx,
+		Key:   GEO_KEY_PREFIX + strings.ToLower(key),
+		Value: locations,
+		TTL:   GEO_CACHE_TTL,
+	}
+
+	if !rds.available() {
+		return rds.LocalOnly.Set(item)
+	}
+	if err := rds.Cache.Set(item); err != nil {
+		rds.reportError(err)
+		return fmt.Errorf("failed to insert locations to redis: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	HISTORY_KEY_PREFIX string = "weather:history:"
+)
+
+type HistoryImplementor interface {
+	Append(context.Context, string, model.CachedWeather) error
+	Range(context.Context, string, time.Time, time.Time) ([]model.WeatherSnapshot, error)
+}
+
+// Keeps a snapshot of every weather we fetch for a city in
+// a sorted set scored by when it was fetched. Snapshots older than
+// Retention are trimmed on each append, as are the oldest
+// past MaxSnapshots, and a city that stops being fetched
+// expires after Retention.
+type RedisHistory struct {
+	Client       *redis.Client
+	Retention    time.Duration
+	MaxSnapshots int64
+}
+
+func NewRedisHistory(rds *redis.Client, retention time.Duration, maxSnapshots int64) *RedisHistory {
+	return &RedisHistory{
+		Client:       rds,
+		Retention:    retention,
+		MaxSnapshots: maxSnapshots,
+	}
+}
+
+// Add a snapshot of a city's weather
+func (rh *RedisHistory) Append(ctx context.Context, city string, weather model.CachedWeather) error {
+	snapshot, ok := model.NewWeatherSnapshot(weather)
+	if !ok {
+		return nil
+	}
+	member, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode weather snapshot: %w", err)
+	}
+
+	key := HISTORY_KEY_PREFIX + strings.ToLower(city)
+	pipe := rh.Client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{
+		Score:  score(weather.FetchedAt),
+		Member: member,
+	})
+	if rh.Retention > 0 {
+		cutoff := score(time.Now().Add(-rh.Retention))
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatFloat(cutoff, 'f', -1, 64))
+		pipe.Expire(ctx, key, rh.Retention)
+	}
+	if rh.MaxSnapshots > 0 {
+		pipe.ZRemRangeByRank(ctx, key, 0, -(rh.MaxSnapshots + 1))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to append weather snapshot to redis: %w", err)
+	}
+
+	return nil
+}
+
+// Get every snapshot of a city fetched between from and to
+// (inclusive), newest first. There are at most MaxSnapshots.
+func (rh *RedisHistory) Range(ctx context.Context, city string, from time.Time, to time.Time) ([]model.WeatherSnapshot, error) {
+	members, err := rh.Client.ZRevRangeByScore(ctx, HISTORY_KEY_PREFIX+strings.ToLower(city), &redis.ZRangeBy{
+		Min: strconv.FormatFloat(score(from), 'f', -1, 64),
+		Max: strconv.FormatFloat(score(to), 'f', -1, 64),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weather history from redis: %w", err)
+	}
+
+	snapshots := []model.WeatherSnapshot{}
+	for _, member := range members {
+		snapshot := model.WeatherSnapshot{}
+		// Snapshots from before they only kept the nearest
+		// forecast have none, and are skipped until they expire
+		if err := json.Unmarshal([]byte(member), &snapshot); err != nil || snapshot.Forecast.Time == 0 {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// Sorted set score for a time, in Unix milliseconds
+func score(t time.Time) float64 {
+	return float64(t.UnixMilli())
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type LockImplementor interface {
+	Acquire(context.Context) (bool, error)
+	Release(context.Context) error
+}
+
+// A lock held in redis so only one replica
+// at a time does a piece of work (leader election).
+// Owner is unique per instance so we never
+// release or renew a lock held by someone else.
+type RedisLock struct {
+	Client *redis.Client
+	Key    string
+	Owner  string
+	TTL    time.Duration
+}
+
+// Take the lock if it is free, or extend it if we already hold it.
+var acquireLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// Only delete the lock if we are still the one holding it.
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Create a new lock with a random owner id
+func NewRedisLock(rds *redis.Client, key string, ttl time.Duration) *RedisLock {
+	return &RedisLock{
+		Client: rds,
+		Key:    key,
+		Owner:  newOwnerId(),
+		TTL:    ttl,
+	}
+}
+
+// Try to become (or stay) the lock holder.
+// Returns true if we hold the lock after the call.
+func (l *RedisLock) Acquire(ctx context.Context) (bool, error) {
+	held, err := acquireLockScript.Run(ctx, l.Client, []string{l.Key}, l.Owner, l.TTL.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %w", l.Key, err)
+	}
+
+	return held == 1, nil
+}
+
+// Give up the lock so another replica can take over right away
+func (l *RedisLock) Release(ctx context.Context) error {
+	if err := releaseLockScript.Run(ctx, l.Client, []string{l.Key}, l.Owner).Err(); err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", l.Key, err)
+	}
+
+	return nil
+}
+
+func newOwnerId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -2,22 +2,56 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/metrics"
 	"github.com/bengimbel/go_redis_api/internal/model"
 	"github.com/go-redis/cache/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	CACHE_TTL          time.Duration = 10 * time.Minute
+	POPULAR_CITIES_KEY string        = "weather:popular"
+	// Requests are counted in hourly buckets, merged
+	// over the popularity window when they are read
+	POPULAR_CITIES_BUCKET time.Duration = time.Hour
+	STALE_KEY_PREFIX      string        = "weather:stale:"
+	// Refreshed weather is published on this prefix
+	// plus the city, so every replica can push it to
+	// clients streaming that city
+	UPDATES_CHANNEL_PREFIX string = "weather:updates:"
+)
+
 type RedisImplementor interface {
-	Insert(context.Context, string, model.WeatherResponse) error
-	FindByCity(context.Context, string) (model.WeatherResponse, error)
+	Insert(context.Context, string, model.CachedWeather) error
+	FindByCity(context.Context, string) (model.CachedWeather, error)
+	FindStaleByCity(context.Context, string) (model.CachedWeather, error)
 	DoesKeyExist(context.Context, string) bool
+	Delete(context.Context, string) error
+	IncrementCityHits(context.Context, string) error
+	TopCities(context.Context, int) ([]string, error)
 }
 type RedisRepo struct {
 	Cache *cache.Cache
+	Local cache.LocalCache
+	// Same local tier without redis behind it,
+	// used while redis is unavailable
+	LocalOnly           *cache.Cache
+	Client              *redis.Client
+	Status              *RedisStatus
+	PopularCitiesMax    int64
+	PopularCitiesWindow time.Duration
+	StaleTTL            time.Duration
+	// Optional, every value we insert is also
+	// kept here as a snapshot
+	History HistoryImplementor
 }
 
 // Setting Cache to use local in-process storage
@@ -25,47 +59,304 @@ type RedisRepo struct {
 // Key/Values use LRU (least recently used)
 // for 1 minute in local in-process storage
 // before looking into the Redis Cache.
-func NewRedisRepo(rds *redis.Client) *RedisRepo {
+// A second copy of every value is kept in redis only,
+// for StaleTTL, so we still have something to serve
+// when we can't call the upstream api.
+// While status reports redis as down, only the local tier is used.
+// Popular cities are counted over the last popularCitiesWindow.
+func NewRedisRepo(rds *redis.Client, status *RedisStatus, popularCitiesMax int64, popularCitiesWindow time.Duration, staleTTL time.Duration) *RedisRepo {
+	local := cache.NewTinyLFU(1000, time.Minute)
 	return &RedisRepo{
 		Cache: cache.New(&cache.Options{
-			Redis:      rds,
-			LocalCache: cache.NewTinyLFU(1000, time.Minute),
+			Redis:        rds,
+			LocalCache:   local,
+			StatsEnabled: true,
+		}),
+		Local: local,
+		LocalOnly: cache.New(&cache.Options{
+			LocalCache: local,
 		}),
+		Client:              rds,
+		Status:              status,
+		PopularCitiesMax:    popularCitiesMax,
+		PopularCitiesWindow: popularCitiesWindow,
+		StaleTTL:            staleTTL,
 	}
 }
 
-// Insert city weather into redis cache.
-func (rds *RedisRepo) Insert(ctx context.Context, city string, weather model.WeatherResponse) error {
+// Insert city weather into redis cache. It is kept until
+// the entry's ExpiresAt, so the cache TTL and the max-age we
+// tell clients agree. Once written the new value is added to the
+// city's history and published for streaming clients. Inserts made
+// while redis is down only reach this replica's local cache, so
+// they aren't kept in history or published.
+func (rds *RedisRepo) Insert(ctx context.Context, city string, weather model.CachedWeather) error {
 	// Save city name as key
 	key := strings.ToLower(city)
-	// Redis cache saves values for 10 minutes.
-	// Return an error if there is one
-	if err := rds.Cache.Set(&cache.Item{
+	// The insert can outlive the request that started it,
+	// so keep the trace but drop the request's cancellation.
+	ctx = context.WithoutCancel(ctx)
+	ttl := weather.RemainingTTL(time.Now())
+	if ttl <= 0 {
+		return nil
+	}
+	item := &cache.Item{
+		Ctx:   ctx,
 		Key:   key,
 		Value: weather,
-		TTL:   10 * time.Minute,
-	}); err != nil {
+		TTL:   ttl,
+	}
+
+	// Without redis we can still keep it in the local tier
+	if !rds.available() {
+		return rds.LocalOnly.Set(item)
+	}
+
+	// Return an error if there is one
+	if err := rds.Cache.Set(item); err != nil {
+		rds.reportError(err)
 		return fmt.Errorf("failed to insert weather object to redis: %w", err)
 	}
 
+	// Keep a longer lived stale copy, skipping the local
+	// cache since it is only read when upstream is unavailable
+	if rds.StaleTTL > 0 {
+		if err := rds.Cache.Set(&cache.Item{
+			Ctx:            ctx,
+			Key:            STALE_KEY_PREFIX + key,
+			Value:          weather,
+			TTL:            rds.StaleTTL,
+			SkipLocalCache: true,
+		}); err != nil {
+			return fmt.Errorf("failed to insert stale weather object to redis: %w", err)
+		}
+	}
+
+	// The value is already cached, so failing to keep a
+	// snapshot or publish it is logged rather than returned
+	if rds.History != nil {
+		if err := rds.History.Append(ctx, key, weather); err != nil {
+			logger.FromContext(ctx).WarnContext(ctx, "Failed to keep weather snapshot", "city", key, "error", err)
+		}
+	}
+	if err := rds.publish(ctx, key, weather); err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "Failed to publish weather update", "city", key, "error", err)
+	}
+
+	return nil
+}
+
+// Publish a city's new weather to its updates channel
+func (rds *RedisRepo) publish(ctx context.Context, city string, weather model.CachedWeather) error {
+	payload, err := json.Marshal(weather)
+	if err != nil {
+		return fmt.Errorf("failed to encode weather update: %w", err)
+	}
+	if err := rds.Client.Publish(ctx, UPDATES_CHANNEL_PREFIX+city, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish weather update: %w", err)
+	}
+
 	return nil
 }
 
 // Get city weather from redis cache.
-func (rds *RedisRepo) FindByCity(ctx context.Context, city string) (model.WeatherResponse, error) {
+func (rds *RedisRepo) FindByCity(ctx context.Context, city string) (model.CachedWeather, error) {
 	// Response struct for results
-	weatherModel := model.WeatherResponse{}
+	weatherModel := model.CachedWeather{}
+
+	// Check the local in-process tier ourselves first,
+	// so we can tell which tier the value came from.
+	if b, ok := rds.Local.Get(city); ok {
+		if err := rds.Cache.Unmarshal(b, &weatherModel); err == nil && isCachedWeather(weatherModel) {
+			metrics.ObserveCacheLookup(metrics.TIER_LOCAL, metrics.RESULT_HIT)
+			weatherModel.Source = model.SOURCE_LOCAL
+			return weatherModel, nil
+		}
+	}
+	metrics.ObserveCacheLookup(metrics.TIER_LOCAL, metrics.RESULT_MISS)
+
+	if !rds.available() {
+		metrics.ObserveCacheLookup(metrics.TIER_REDIS, metrics.RESULT_ERROR)
+		return weatherModel, fmt.Errorf("Could not look up city in redis cache: %s: %w", city, ErrRedisUnavailable)
+	}
 
 	// Get city weather from redis cache using the city as a key.
 	if err := rds.Cache.Get(ctx, city, &weatherModel); err != nil {
+		if errors.Is(err, cache.ErrCacheMiss) {
+			metrics.ObserveCacheLookup(metrics.TIER_REDIS, metrics.RESULT_MISS)
+		} else {
+			metrics.ObserveCacheLookup(metrics.TIER_REDIS, metrics.RESULT_ERROR)
+			if IsConnectionError(err) {
+				rds.reportError(err)
+				return weatherModel, fmt.Errorf("Could not look up city in redis cache: %s: %w", city, ErrRedisUnavailable)
+			}
+		}
 		return weatherModel, fmt.Errorf("Could not find city in redis cache: %s", city)
 	}
+	if !isCachedWeather(weatherModel) {
+		metrics.ObserveCacheLookup(metrics.TIER_REDIS, metrics.RESULT_MISS)
+		return model.CachedWeather{}, fmt.Errorf("Could not find city in redis cache: %s", city)
+	}
+	metrics.ObserveCacheLookup(metrics.TIER_REDIS, metrics.RESULT_HIT)
+	weatherModel.Source = model.SOURCE_REDIS
+
+	return weatherModel, nil
+}
+
+// Get the stale copy of city weather from redis.
+// This can be older than the cache TTL.
+func (rds *RedisRepo) FindStaleByCity(ctx context.Context, city string) (model.CachedWeather, error) {
+	weatherModel := model.CachedWeather{}
+
+	if !rds.available() {
+		return weatherModel, fmt.Errorf("Could not look up stale city in redis cache: %s: %w", city, ErrRedisUnavailable)
+	}
+
+	if err := rds.Cache.GetSkippingLocalCache(ctx, STALE_KEY_PREFIX+city, &weatherModel); err != nil || !isCachedWeather(weatherModel) {
+		return model.CachedWeather{}, fmt.Errorf("Could not find stale city in redis cache: %s", city)
+	}
+	weatherModel.Source = model.SOURCE_REDIS
+	weatherModel.Stale = true
 
 	return weatherModel, nil
 }
 
 // Check if city is in redis cache.
 func (rds *RedisRepo) DoesKeyExist(ctx context.Context, city string) bool {
-	// Check cache if key exists
-	return rds.Cache.Exists(ctx, city)
+	// Check cache if key exists, only
+	// looking locally if redis is down
+	var exists bool
+	if rds.available() {
+		exists = rds.Cache.Exists(ctx, city)
+	} else {
+		exists = rds.LocalOnly.Exists(ctx, city)
+	}
+
+	// Hits are counted when the value is read by
+	// FindByCity, so only count misses here
+	if !exists {
+		metrics.ObserveCacheLookup(metrics.TIER_LOCAL, metrics.RESULT_MISS)
+		metrics.ObserveCacheLookup(metrics.TIER_REDIS, metrics.RESULT_MISS)
+	}
+
+	return exists
+}
+
+// Remove a city from the local and redis cache,
+// along with its stale copy.
+func (rds *RedisRepo) Delete(ctx context.Context, city string) error {
+	key := strings.ToLower(city)
+	if !rds.available() {
+		rds.LocalOnly.Delete(ctx, key)
+		return fmt.Errorf("failed to delete city from redis cache: %w", ErrRedisUnavailable)
+	}
+	if err := rds.Cache.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete city from redis cache: %w", err)
+	}
+	if err := rds.Client.Del(ctx, STALE_KEY_PREFIX+key).Err(); err != nil {
+		return fmt.Errorf("failed to delete stale city from redis cache: %w", err)
+	}
+
+	return nil
+}
+
+// Count a request for a city in the current bucket. Past
+// PopularCitiesMax cities, the least requested are dropped,
+// but never the one just counted, so a new city gets a chance.
+// While redis is down the hit is dropped.
+func (rds *RedisRepo) IncrementCityHits(ctx context.Context, city string) error {
+	if !rds.available() {
+		return nil
+	}
+
+	now := time.Now()
+	expiry := rds.popularWindow() + POPULAR_CITIES_BUCKET
+	err := incrementCityScript.Run(ctx, rds.Client,
+		[]string{popularBucketKey(now)},
+		strings.ToLower(city), rds.PopularCitiesMax, expiry.Milliseconds(),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to increment city hits in redis: %w", err)
+	}
+
+	return nil
+}
+
+// Get the n most requested cities over the popularity
+// window, most popular first. The buckets in the window
+// are summed into a short lived key and read from there.
+func (rds *RedisRepo) TopCities(ctx context.Context, n int) ([]string, error) {
+	if n <= 0 {
+		return []string{}, nil
+	}
+	if !rds.available() {
+		return nil, fmt.Errorf("failed to get top cities from redis: %w", ErrRedisUnavailable)
+	}
+
+	now := time.Now()
+	buckets := int(rds.popularWindow() / POPULAR_CITIES_BUCKET)
+	keys := make([]string, 0, buckets)
+	for i := 0; i < buckets; i++ {
+		keys = append(keys, popularBucketKey(now.Add(-time.Duration(i)*POPULAR_CITIES_BUCKET)))
+	}
+
+	pipe := rds.Client.TxPipeline()
+	pipe.ZUnionStore(ctx, POPULAR_CITIES_KEY, &redis.ZStore{Keys: keys})
+	pipe.Expire(ctx, POPULAR_CITIES_KEY, time.Minute)
+	top := pipe.ZRevRange(ctx, POPULAR_CITIES_KEY, 0, int64(n-1))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get top cities from redis: %w", err)
+	}
+
+	return top.Val(), nil
+}
+
+// Count a hit, then drop the least requested cities past the max,
+// skipping the city just counted. Returns the city's new count.
+var incrementCityScript = redis.NewScript(`
+local score = redis.call("ZINCRBY", KEYS[1], 1, ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+
+local max = tonumber(ARGV[2])
+if max > 0 then
+	local extra = redis.call("ZCARD", KEYS[1]) - max
+	if extra > 0 then
+		local removed = 0
+		for _, member in ipairs(redis.call("ZRANGE", KEYS[1], 0, extra)) do
+			if removed < extra and member ~= ARGV[1] then
+				redis.call("ZREM", KEYS[1], member)
+				removed = removed + 1
+			end
+		end
+	end
+end
+
+return score
+`)
+
+// The window popularity is counted over, at least one bucket
+func (rds *RedisRepo) popularWindow() time.Duration {
+	return max(rds.PopularCitiesWindow, POPULAR_CITIES_BUCKET)
+}
+
+// Key of the bucket counting requests made at t
+func popularBucketKey(t time.Time) string {
+	return POPULAR_CITIES_KEY + ":" + strconv.FormatInt(t.UnixMilli()/POPULAR_CITIES_BUCKET.Milliseconds(), 10)
+}
+
+// Redis is assumed to be up if we aren't tracking its status
+func (rds *RedisRepo) available() bool {
+	return rds.Status == nil || rds.Status.Available()
+}
+
+func (rds *RedisRepo) reportError(err error) {
+	if rds.Status != nil {
+		rds.Status.ReportError(err)
+	}
+}
+
+// Entries cached before we stored the fetch time decode
+// without one, so treat them as misses and refetch
+func isCachedWeather(weather model.CachedWeather) bool {
+	return !weather.FetchedAt.IsZero()
 }
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrRedisUnavailable = errors.New("redis is unavailable")
+
+// Tracks whether redis is reachable. While it is down the repo
+// skips redis and only uses the local in-process cache, rather
+// than making every request wait on a dead connection. A
+// background loop keeps pinging redis so we recover on our own.
+type RedisStatus struct {
+	Client    *redis.Client
+	Interval  time.Duration
+	Logger    *slog.Logger
+	available atomic.Bool
+}
+
+// Create a new RedisStatus, assuming redis is up until told otherwise
+func NewRedisStatus(rds *redis.Client, interval time.Duration, log *slog.Logger) *RedisStatus {
+	status := &RedisStatus{
+		Client:   rds,
+		Interval: interval,
+		Logger:   log,
+	}
+	status.available.Store(true)
+	metrics.RedisUp.Set(1)
+	return status
+}
+
+// Whether redis was reachable the last time we checked
+func (rs *RedisStatus) Available() bool {
+	return rs.available.Load()
+}
+
+// Ping redis and record whether it answered
+func (rs *RedisStatus) Check(ctx context.Context) error {
+	err := rs.Client.Ping(ctx).Err()
+	rs.setAvailable(err == nil)
+	if err != nil {
+		return errors.Join(ErrRedisUnavailable, err)
+	}
+	return nil
+}
+
+// Mark redis as down if err means we lost the connection.
+// Other errors, like a cache miss, leave the status alone.
+func (rs *RedisStatus) ReportError(err error) {
+	if IsConnectionError(err) {
+		rs.setAvailable(false)
+	}
+}
+
+// Keep checking redis until the context is cancelled,
+// so we notice when it goes away and when it comes back.
+// This blocks, so it should be run on its own go-routine.
+func (rs *RedisStatus) Start(ctx context.Context) {
+	ticker := time.NewTicker(rs.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, rs.Interval)
+			rs.Check(checkCtx)
+			cancel()
+		}
+	}
+}
+
+func (rs *RedisStatus) setAvailable(available bool) {
+	if rs.available.Swap(available) != available {
+		if available {
+			rs.Logger.Info("Redis is available, leaving degraded mode")
+		} else {
+			rs.Logger.Warn("Redis is unavailable, running in degraded mode")
+		}
+	}
+
+	if available {
+		metrics.RedisUp.Set(1)
+	} else {
+		metrics.RedisUp.Set(0)
+	}
+}
+
+// Whether err means redis couldn't be reached,
+// as opposed to a bad command or a missing key
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, redis.ErrClosed)
+}
@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/internal/health"
+	"github.com/bengimbel/go_redis_api/internal/middleware"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/pkg/weatherProto"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	grpcHealth "google.golang.org/grpc/health"
+	healthProto "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+const (
+	// How often gRPC health is updated when readiness
+	// checks aren't cached (HEALTH_CACHE_TTL=0)
+	MIN_HEALTH_WATCH_INTERVAL time.Duration = time.Second
+)
+
+// The gRPC server, with the weather service,
+// gRPC health checking and reflection
+type Server struct {
+	GRPC   *grpc.Server
+	Health *grpcHealth.Server
+}
+
+// Every call is traced, logged with a request id, recovered
+// from panics and has its errors mapped to status codes. Calls
+// need an api key with the weather:read scope if authenticator is set,
+// and are rate limited per client if limiter is set.
+func NewServer(svc service.WeatherServiceImplementor, authenticator *middleware.Authenticator, limiter *middleware.RateLimiter, log *slog.Logger, opts ...grpc.ServerOption) *Server {
+	interceptors := []grpc.UnaryServerInterceptor{
+		LoggingInterceptor(log),
+		RecoveryInterceptor,
+		ErrorInterceptor,
+	}
+	if authenticator != nil {
+		interceptors = append(interceptors, AuthInterceptor(authenticator, auth.SCOPE_WEATHER_READ))
+	}
+	if limiter != nil {
+		interceptors = append(interceptors, RateLimitInterceptor(limiter))
+	}
+	opts = append(opts,
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	)
+
+	server := &Server{
+		GRPC:   grpc.NewServer(opts...),
+		Health: grpcHealth.NewServer(),
+	}
+	weatherProto.RegisterWeatherServiceServer(server.GRPC, NewWeatherServer(svc))
+	healthProto.RegisterHealthServer(server.GRPC, server.Health)
+	reflection.Register(server.GRPC)
+
+	return server
+}
+
+// Keep gRPC health in step with our readiness checks, for the
+// server as a whole and the weather service, until ctx is done.
+// The interval is at least MIN_HEALTH_WATCH_INTERVAL.
+func (s *Server) WatchHealth(ctx context.Context, h health.HealthImplementor, interval time.Duration) {
+	ticker := time.NewTicker(max(interval, MIN_HEALTH_WATCH_INTERVAL))
+	defer ticker.Stop()
+
+	for {
+		status := healthProto.HealthCheckResponse_SERVING
+		if h.Ready(ctx).Status == health.STATUS_FAIL {
+			status = healthProto.HealthCheckResponse_NOT_SERVING
+		}
+		s.Health.SetServingStatus("", status)
+		s.Health.SetServingStatus(weatherProto.WeatherService_ServiceDesc.ServiceName, status)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report every service as not serving, so clients
+// stop sending calls before we stop the server
+func (s *Server) Drain() {
+	s.Health.Shutdown()
+}
+
+// Stop taking calls and wait for running ones to finish.
+// If ctx is done first they are cancelled.
+func (s *Server) Stop(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.GRPC.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.GRPC.Stop()
+		return ctx.Err()
+	}
+}
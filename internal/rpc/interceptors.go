@@ -0,0 +1,216 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/auth"
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/middleware"
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	REQUEST_ID_METADATA    string        = "x-request-id"
+	API_KEY_METADATA       string        = "x-api-key"
+	AUTHORIZATION_METADATA string        = "authorization"
+	REDIS_RETRY_AFTER      time.Duration = 5 * time.Second
+)
+
+// Gives every call a request id and the logger, like the RequestID
+// and RequestLogger middleware, and logs it once it is done with its status code
+func LoggingInterceptor(log *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		requestID := firstMetadata(ctx, REQUEST_ID_METADATA)
+		if !middleware.IsValidRequestID(requestID) {
+			requestID = middleware.NewRequestID()
+		}
+		ctx = logger.WithRequestID(ctx, requestID)
+		ctx = logger.WithLogger(ctx, log)
+		grpc.SetHeader(ctx, metadata.Pairs(REQUEST_ID_METADATA, requestID))
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		code := status.Code(err)
+
+		level := slog.LevelInfo
+		if code == codes.Internal || code == codes.Unknown {
+			level = slog.LevelError
+		}
+		attrs := []any{
+			"method", info.FullMethod,
+			"code", code.String(),
+			"duration", time.Since(start),
+		}
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		}
+		log.Log(ctx, level, "gRPC request", attrs...)
+
+		return resp, err
+	}
+}
+
+// Turns a panic in a handler into an Internal error, like the Recoverer middleware
+func RecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "Recovered from panic",
+				"panic", recovered,
+				"stack", string(debug.Stack()),
+			)
+			err = status.Error(codes.Internal, "internal server error")
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// Maps our errors to gRPC status codes, the same way
+// the rest api picks http status codes
+func ErrorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return resp, ToStatus(err)
+	}
+	return resp, nil
+}
+
+// Checks the api key in the x-api-key or authorization metadata
+// has the scope, like the Authenticator and RequireScope middleware.
+// Health checks and reflection don't need a key.
+func AuthInterceptor(authenticator *middleware.Authenticator, scope string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, "/weather.") {
+			return handler(ctx, req)
+		}
+
+		plainKey := requestApiKey(ctx)
+		if plainKey == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing api key")
+		}
+		key, err := authenticator.FindKey(ctx, plainKey)
+		if errors.Is(err, auth.ErrApiKeyNotFound) {
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		} else if err != nil {
+			logger.FromContext(ctx).ErrorContext(ctx, "Failed to check api key", "error", err)
+			return nil, withRetryInfo(status.New(codes.Unavailable, "failed to check api key"), middleware.KEY_STORE_RETRY_AFTER)
+		}
+		if !key.HasScope(scope) {
+			return nil, status.Errorf(codes.PermissionDenied, "api key is missing scope: %s", scope)
+		}
+
+		return handler(auth.WithApiKey(ctx, key), req)
+	}
+}
+
+// Counts calls against the same per client limits as the RateLimiter
+// middleware, keyed by api key id or else by the peer's IP. It must
+// run after AuthInterceptor so keys get their tier's limit. Health
+// checks and reflection aren't counted, and if redis can't be
+// reached the call is let through.
+func RateLimitInterceptor(limiter *middleware.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, "/weather.") {
+			return handler(ctx, req)
+		}
+
+		limit := limiter.LimitFor(ctx)
+		allowed, _, reset, err := limiter.Take(ctx, middleware.ClientKeyFor(ctx, peerIP(ctx)), limit)
+		if err != nil {
+			logger.FromContext(ctx).WarnContext(ctx, "Rate limiter unavailable, allowing call", "error", err)
+			return handler(ctx, req)
+		}
+		if !allowed {
+			return nil, withRetryInfo(status.Newf(codes.ResourceExhausted, "rate limit of %d requests per %s exceeded", limit, limiter.Window), reset)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// Get the gRPC status for an error. Redis being down and a used
+// up daily quota are Unavailable, and the per minute quota is
+// ResourceExhausted, each with how long to wait before retrying.
+// Anything else we don't know about is InvalidArgument, like
+// the rest api's 400.
+func ToStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var quotaErr *httpClient.QuotaExceededError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, repository.ErrRedisUnavailable):
+		return withRetryInfo(status.New(codes.Unavailable, err.Error()), REDIS_RETRY_AFTER)
+	case errors.As(err, &quotaErr):
+		code := codes.ResourceExhausted
+		if quotaErr.Reason == httpClient.QUOTA_REASON_DAILY {
+			code = codes.Unavailable
+		}
+		return withRetryInfo(status.New(code, err.Error()), quotaErr.RetryAfter)
+	default:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+func withRetryInfo(st *status.Status, retryAfter time.Duration) error {
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryAfter),
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+func requestApiKey(ctx context.Context) string {
+	if apiKey := firstMetadata(ctx, API_KEY_METADATA); apiKey != "" {
+		return apiKey
+	}
+
+	authorization := firstMetadata(ctx, AUTHORIZATION_METADATA)
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "bearer ") {
+		return strings.TrimSpace(authorization[7:])
+	}
+	return ""
+}
+
+// The IP the call came from. gRPC clients connect to us
+// directly, so there is no X-Forwarded-For to look at.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return ip
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
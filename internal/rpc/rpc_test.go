@@ -0,0 +1,217 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/health"
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/middleware"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/bengimbel/go_redis_api/internal/rpc"
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+	"github.com/bengimbel/go_redis_api/pkg/weatherProto"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthProto "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (ms *MockService) RetrieveAndCacheWeatherAsync(ctx context.Context, city string) (model.CachedWeather, error) {
+	args := ms.Called(ctx, city)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
+}
+func (ms *MockService) RetrieveWeatherFromCache(ctx context.Context, city string) (model.CachedWeather, error) {
+	args := ms.Called(ctx, city)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
+}
+func (ms *MockService) DoesKeyExist(ctx context.Context, city string) bool {
+	args := ms.Called(ctx, city)
+	return args.Bool(0)
+}
+func (ms *MockService) InsertToCacheAsync(ctx context.Context, city string, weather model.CachedWeather) error {
+	args := ms.Called(ctx, city, weather)
+	return args.Error(0)
+}
+func (ms *MockService) RecordCityRequest(ctx context.Context, city string) error {
+	args := ms.Called(ctx, city)
+	return args.Error(0)
+}
+func (ms *MockService) PopularCities(ctx context.Context, n int) ([]string, error) {
+	args := ms.Called(ctx, n)
+	return args.Get(0).([]string), args.Error(1)
+}
+func (ms *MockService) RefreshWeather(ctx context.Context, city string) (model.CachedWeather, error) {
+	args := ms.Called(ctx, city)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
+}
+func (ms *MockService) InvalidateCity(ctx context.Context, city string) error {
+	args := ms.Called(ctx, city)
+	return args.Error(0)
+}
+
+type MockHealth struct {
+	mock.Mock
+}
+
+func (mh *MockHealth) Ready(ctx context.Context) health.Response {
+	args := mh.Called(ctx)
+	return args.Get(0).(health.Response)
+}
+
+// Serve a gRPC server in memory and connect to it
+func dial(t *testing.T, server *rpc.Server) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	go server.GRPC.Serve(listener)
+	t.Cleanup(server.GRPC.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestGetWeather(t *testing.T) {
+	svc := &MockService{}
+	client := weatherProto.NewWeatherServiceClient(dial(t, rpc.NewServer(svc, nil, nil, logger.Discard())))
+	weather := model.NewCachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "chicago",
+		},
+		List: []model.List{
+			{
+				Dt:   123,
+				Main: model.Main{Temp: 280},
+			},
+		},
+	}, "mock", time.Now(), time.Minute)
+
+	svc.On("DoesKeyExist", mock.Anything, "chicago").Return(false).Twice()
+	svc.On("RetrieveAndCacheWeatherAsync", mock.Anything, "chicago").Return(weather, nil).Twice()
+	svc.On("RecordCityRequest", mock.Anything, "chicago").Return(nil).Twice()
+
+	var header metadata.MD
+	resp, err := client.GetWeather(context.Background(), &weatherProto.GetWeatherRequest{City: "Chicago"}, grpc.Header(&header))
+	assert.Nil(t, err)
+	assert.EqualValues(t, "chicago", resp.GetWeather().GetCity().GetName())
+	assert.EqualValues(t, 123, resp.GetWeather().GetList()[0].GetDt())
+	assert.EqualValues(t, model.SOURCE_UPSTREAM, resp.GetMeta().GetSource())
+	assert.EqualValues(t, "mock", resp.GetMeta().GetProvider())
+	assert.NotEmpty(t, header.Get(rpc.REQUEST_ID_METADATA))
+
+	forecast, err := client.GetForecast(context.Background(), &weatherProto.GetWeatherRequest{City: "chicago"})
+	assert.Nil(t, err)
+	assert.EqualValues(t, "mock", forecast.GetForecast().GetProvider())
+	assert.InDelta(t, 280-model.KELVIN_OFFSET, forecast.GetForecast().GetEntries()[0].GetTemp(), 0.01)
+}
+
+func TestGetWeatherErrors(t *testing.T) {
+	svc := &MockService{}
+	client := weatherProto.NewWeatherServiceClient(dial(t, rpc.NewServer(svc, nil, nil, logger.Discard())))
+
+	svc.On("RetrieveWeatherFromCache", mock.Anything, "austin").Return(model.CachedWeather{}, errors.New("Could not find city in redis cache: austin")).Once()
+	svc.On("RetrieveWeatherFromCache", mock.Anything, "boise").Return(model.CachedWeather{}, repository.ErrRedisUnavailable).Once()
+
+	_, err := client.GetWeather(context.Background(), &weatherProto.GetWeatherRequest{})
+	assert.EqualValues(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.GetCachedWeather(context.Background(), &weatherProto.GetWeatherRequest{City: "austin"})
+	assert.EqualValues(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetCachedWeather(context.Background(), &weatherProto.GetWeatherRequest{City: "boise"})
+	st := status.Convert(err)
+	assert.EqualValues(t, codes.Unavailable, st.Code())
+	assert.Len(t, st.Details(), 1)
+	assert.EqualValues(t, 5*time.Second, st.Details()[0].(*errdetails.RetryInfo).GetRetryDelay().AsDuration())
+}
+
+func TestToStatus(t *testing.T) {
+	rate := &httpClient.QuotaExceededError{Reason: httpClient.QUOTA_REASON_RATE, RetryAfter: time.Second}
+	daily := &httpClient.QuotaExceededError{Reason: httpClient.QUOTA_REASON_DAILY, RetryAfter: time.Hour}
+
+	assert.EqualValues(t, codes.ResourceExhausted, status.Code(rpc.ToStatus(rate)))
+	assert.EqualValues(t, codes.Unavailable, status.Code(rpc.ToStatus(daily)))
+	assert.EqualValues(t, codes.DeadlineExceeded, status.Code(rpc.ToStatus(context.DeadlineExceeded)))
+	assert.EqualValues(t, codes.NotFound, status.Code(rpc.ToStatus(status.Error(codes.NotFound, "missing"))))
+	assert.EqualValues(t, codes.InvalidArgument, status.Code(rpc.ToStatus(errors.New("bad city"))))
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	svc := &MockService{}
+	authenticator := middleware.NewAuthenticator(nil, config.AuthConfig{AdminKey: "admin-key"})
+	conn := dial(t, rpc.NewServer(svc, authenticator, nil, logger.Discard()))
+	client := weatherProto.NewWeatherServiceClient(conn)
+
+	svc.On("RetrieveWeatherFromCache", mock.Anything, "reno").Return(model.CachedWeather{}, errors.New("not cached")).Once()
+
+	_, err := client.GetCachedWeather(context.Background(), &weatherProto.GetWeatherRequest{City: "reno"})
+	assert.EqualValues(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), rpc.API_KEY_METADATA, "admin-key")
+	_, err = client.GetCachedWeather(ctx, &weatherProto.GetWeatherRequest{City: "reno"})
+	assert.EqualValues(t, codes.NotFound, status.Code(err))
+
+	// Health checks don't need a key
+	_, err = healthProto.NewHealthClient(conn).Check(context.Background(), &healthProto.HealthCheckRequest{})
+	assert.Nil(t, err)
+}
+
+func TestRateLimitInterceptorAllowsCallsWithoutRedis(t *testing.T) {
+	svc := &MockService{}
+	rds := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	limiter := middleware.NewRateLimiter(rds, 1, time.Minute, nil, nil, logger.Discard())
+	client := weatherProto.NewWeatherServiceClient(dial(t, rpc.NewServer(svc, nil, limiter, logger.Discard())))
+
+	svc.On("RetrieveWeatherFromCache", mock.Anything, "reno").Return(model.CachedWeather{}, errors.New("not cached"))
+
+	// Redis being down fails open, like the http rate limiter
+	for i := 0; i < 2; i++ {
+		_, err := client.GetCachedWeather(context.Background(), &weatherProto.GetWeatherRequest{City: "reno"})
+		assert.EqualValues(t, codes.NotFound, status.Code(err))
+	}
+}
+
+func TestWatchHealth(t *testing.T) {
+	mockHealth := &MockHealth{}
+	server := rpc.NewServer(&MockService{}, nil, nil, logger.Discard())
+	client := healthProto.NewHealthClient(dial(t, server))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockHealth.On("Ready", mock.Anything).Return(health.Response{Status: health.STATUS_FAIL})
+	// An interval of 0 (HEALTH_CACHE_TTL=0) must not panic the ticker
+	go server.WatchHealth(ctx, mockHealth, 0)
+
+	assert.Eventually(t, func() bool {
+		resp, err := client.Check(context.Background(), &healthProto.HealthCheckRequest{
+			Service: weatherProto.WeatherService_ServiceDesc.ServiceName,
+		})
+		return err == nil && resp.GetStatus() == healthProto.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 10*time.Millisecond)
+
+	server.Drain()
+	resp, err := client.Check(context.Background(), &healthProto.HealthCheckRequest{})
+	assert.Nil(t, err)
+	assert.EqualValues(t, healthProto.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+}
@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/bengimbel/go_redis_api/pkg/weatherProto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Serves weather over gRPC with the same weather
+// service, and so the same cache, as the rest api
+type WeatherServer struct {
+	weatherProto.UnimplementedWeatherServiceServer
+	Service service.WeatherServiceImplementor
+}
+
+func NewWeatherServer(svc service.WeatherServiceImplementor) *WeatherServer {
+	return &WeatherServer{
+		Service: svc,
+	}
+}
+
+// Weather for a city, from the cache or fetched from upstream
+func (ws *WeatherServer) GetWeather(ctx context.Context, req *weatherProto.GetWeatherRequest) (*weatherProto.GetWeatherResponse, error) {
+	city, err := requestCity(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := service.RetrieveWeather(ctx, ws.Service, city)
+	if err != nil {
+		return nil, err
+	}
+
+	return &weatherProto.GetWeatherResponse{
+		Weather: result.Weather.Proto(),
+		Meta:    result.ProtoMeta(),
+	}, nil
+}
+
+// Weather for a city only if it is cached. A city that
+// isn't cached is NotFound, unless redis is down.
+func (ws *WeatherServer) GetCachedWeather(ctx context.Context, req *weatherProto.GetWeatherRequest) (*weatherProto.GetWeatherResponse, error) {
+	city, err := requestCity(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ws.Service.RetrieveWeatherFromCache(ctx, city)
+	if errors.Is(err, repository.ErrRedisUnavailable) {
+		return nil, err
+	} else if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	return &weatherProto.GetWeatherResponse{
+		Weather: result.Weather.Proto(),
+		Meta:    result.ProtoMeta(),
+	}, nil
+}
+
+// Weather for a city in the normalized model
+func (ws *WeatherServer) GetForecast(ctx context.Context, req *weatherProto.GetWeatherRequest) (*weatherProto.GetForecastResponse, error) {
+	city, err := requestCity(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := service.RetrieveWeather(ctx, ws.Service, city)
+	if err != nil {
+		return nil, err
+	}
+
+	return &weatherProto.GetForecastResponse{
+		Forecast: result.Weather.Normalize(result.Provider).Proto(),
+		Meta:     result.ProtoMeta(),
+	}, nil
+}
+
+// Cities are cached by lowercase name, like the rest api
+func requestCity(req *weatherProto.GetWeatherRequest) (string, error) {
+	city := strings.ToLower(req.GetCity())
+	if city == "" {
+		return "", status.Error(codes.InvalidArgument, "city is required")
+	}
+	return city, nil
+}
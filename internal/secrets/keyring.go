@@ -0,0 +1,194 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+)
+
+const (
+	STRATEGY_FAILOVER    string = "failover"
+	STRATEGY_ROUND_ROBIN string = "round-robin"
+	// How long a rejected key is left out before it is tried
+	// again, in case it was only waiting to be activated
+	REJECTED_KEY_BACKOFF time.Duration = 10 * time.Minute
+)
+
+var ErrNoKeys = errors.New("no upstream api keys are available")
+
+// The api keys we can call upstream with. It picks a key for
+// each call, leaving out keys upstream has rejected or rate
+// limited until they might work again.
+type KeyRing struct {
+	Provider SecretProvider
+	Strategy string
+
+	mu   sync.Mutex
+	keys []*ringKey
+	next int
+}
+
+type ringKey struct {
+	value string
+	// Not used until then
+	disabledUntil time.Time
+	// Why it was disabled, a rate limit or a rejection
+	rateLimited bool
+}
+
+// Create a KeyRing, loading the keys straight away so
+// a missing key file stops us from starting
+func NewKeyRing(provider SecretProvider, strategy string) (*KeyRing, error) {
+	if strategy != STRATEGY_FAILOVER && strategy != STRATEGY_ROUND_ROBIN {
+		return nil, fmt.Errorf("unknown api key strategy: %s", strategy)
+	}
+	ring := &KeyRing{
+		Provider: provider,
+		Strategy: strategy,
+	}
+	if _, err := ring.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ring, nil
+}
+
+// The key to use for the next call. With failover it is the first
+// key that isn't left out, and with round-robin the one after the
+// last key we gave out. If every key is rate limited the error says
+// when the first one can be used again.
+func (kr *KeyRing) Key() (string, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if len(kr.keys) == 0 {
+		return "", ErrNoKeys
+	}
+
+	now := time.Now()
+	start := 0
+	if kr.Strategy == STRATEGY_ROUND_ROBIN {
+		start = kr.next % len(kr.keys)
+	}
+	for i := range kr.keys {
+		index := (start + i) % len(kr.keys)
+		if key := kr.keys[index]; !now.Before(key.disabledUntil) {
+			kr.next = index + 1
+			return key.value, nil
+		}
+	}
+
+	var retryAt time.Time
+	for _, key := range kr.keys {
+		if key.rateLimited && (retryAt.IsZero() || key.disabledUntil.Before(retryAt)) {
+			retryAt = key.disabledUntil
+		}
+	}
+	if retryAt.IsZero() {
+		return "", ErrNoKeys
+	}
+	return "", &httpClient.QuotaExceededError{
+		Reason:     httpClient.QUOTA_REASON_RATE,
+		RetryAfter: retryAt.Sub(now),
+	}
+}
+
+// Leave a key out after upstream rate limits it, until its
+// Retry-After, or after it is rejected, for a while
+func (kr *KeyRing) Report(ctx context.Context, value string, err error) {
+	var quotaErr *httpClient.QuotaExceededError
+	var backoff time.Duration
+	switch {
+	case errors.As(err, &quotaErr):
+		backoff = quotaErr.RetryAfter
+	case errors.Is(err, httpClient.ErrKeyRejected):
+		backoff = REJECTED_KEY_BACKOFF
+	default:
+		return
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for _, key := range kr.keys {
+		if key.value == value {
+			key.disabledUntil = time.Now().Add(backoff)
+			key.rateLimited = quotaErr != nil
+			logger.FromContext(ctx).WarnContext(ctx, "Leaving out upstream api key", "key", Fingerprint(value), "for", backoff, "error", err)
+		}
+	}
+}
+
+// Load the keys again. Returns whether they changed. Keys
+// we already had stay left out if they were, and if the
+// keys can't be loaded we keep using the old ones.
+func (kr *KeyRing) Reload() (bool, error) {
+	values, err := kr.Provider.Load()
+	if err != nil {
+		return false, err
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	current := make([]string, len(kr.keys))
+	for i, key := range kr.keys {
+		current[i] = key.value
+	}
+	if kr.keys != nil && slices.Equal(current, values) {
+		return false, nil
+	}
+
+	keys := make([]*ringKey, len(values))
+	for i, value := range values {
+		keys[i] = &ringKey{value: value}
+		if j := slices.Index(current, value); j >= 0 {
+			keys[i] = kr.keys[j]
+		}
+	}
+	kr.keys = keys
+	kr.next = 0
+
+	return true, nil
+}
+
+// Check for changed keys every interval until the context is
+// cancelled. This blocks, so it should be run on its own go-routine.
+func (kr *KeyRing) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := kr.Reload()
+			if err != nil {
+				logger.FromContext(ctx).Error("Failed to reload upstream api keys, keeping the current ones", "error", err)
+			} else if reloaded {
+				logger.FromContext(ctx).Info("Reloaded upstream api keys", "keys", kr.Len())
+			}
+		}
+	}
+}
+
+// How many keys there are
+func (kr *KeyRing) Len() int {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	return len(kr.keys)
+}
+
+// Enough of a key to tell which one it is in logs
+func Fingerprint(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
@@ -0,0 +1,134 @@
+package secrets_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/secrets"
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticProvider struct {
+	keys []string
+	err  error
+}
+
+func (sp *staticProvider) Load() ([]string, error) {
+	return sp.keys, sp.err
+}
+
+func TestKeyRingFailover(t *testing.T) {
+	ring, err := secrets.NewKeyRing(&staticProvider{keys: []string{"first", "second"}}, secrets.STRATEGY_FAILOVER)
+	assert.Nil(t, err)
+
+	key, _ := ring.Key()
+	assert.Equal(t, "first", key)
+	key, _ = ring.Key()
+	assert.Equal(t, "first", key)
+
+	ring.Report(context.Background(), "first", httpClient.ErrKeyRejected)
+	key, _ = ring.Key()
+	assert.Equal(t, "second", key)
+
+	// Errors that aren't the key's fault don't leave it out
+	ring.Report(context.Background(), "second", errors.New("connection reset"))
+	key, _ = ring.Key()
+	assert.Equal(t, "second", key)
+}
+
+func TestKeyRingRoundRobin(t *testing.T) {
+	ring, err := secrets.NewKeyRing(&staticProvider{keys: []string{"a", "b", "c"}}, secrets.STRATEGY_ROUND_ROBIN)
+	assert.Nil(t, err)
+
+	picked := []string{}
+	for i := 0; i < 4; i++ {
+		key, _ := ring.Key()
+		picked = append(picked, key)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a"}, picked)
+
+	ring.Report(context.Background(), "b", &httpClient.QuotaExceededError{Reason: httpClient.QUOTA_REASON_RATE, RetryAfter: time.Minute})
+	key, _ := ring.Key()
+	assert.Equal(t, "c", key)
+	key, _ = ring.Key()
+	assert.Equal(t, "a", key)
+}
+
+func TestKeyRingEveryKeyRateLimited(t *testing.T) {
+	ring, _ := secrets.NewKeyRing(&staticProvider{keys: []string{"a", "b"}}, secrets.STRATEGY_FAILOVER)
+	ring.Report(context.Background(), "a", &httpClient.QuotaExceededError{Reason: httpClient.QUOTA_REASON_RATE, RetryAfter: time.Minute})
+	ring.Report(context.Background(), "b", &httpClient.QuotaExceededError{Reason: httpClient.QUOTA_REASON_RATE, RetryAfter: 30 * time.Second})
+
+	_, err := ring.Key()
+
+	var quotaErr *httpClient.QuotaExceededError
+	if assert.ErrorAs(t, err, &quotaErr) {
+		assert.InDelta(t, 30*time.Second, quotaErr.RetryAfter, float64(time.Second))
+	}
+
+	ring.Report(context.Background(), "a", httpClient.ErrKeyRejected)
+	ring.Report(context.Background(), "b", httpClient.ErrKeyRejected)
+	_, err = ring.Key()
+	assert.ErrorIs(t, err, secrets.ErrNoKeys)
+}
+
+func TestKeyRingReloadKeepsLeftOutKeys(t *testing.T) {
+	provider := &staticProvider{keys: []string{"a", "b"}}
+	ring, _ := secrets.NewKeyRing(provider, secrets.STRATEGY_FAILOVER)
+	ring.Report(context.Background(), "a", httpClient.ErrKeyRejected)
+
+	reloaded, err := ring.Reload()
+	assert.Nil(t, err)
+	assert.False(t, reloaded)
+
+	provider.keys = []string{"c", "a"}
+	reloaded, err = ring.Reload()
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	key, _ := ring.Key()
+	assert.Equal(t, "c", key)
+	ring.Report(context.Background(), "c", httpClient.ErrKeyRejected)
+	_, err = ring.Key()
+	assert.ErrorIs(t, err, secrets.ErrNoKeys)
+
+	// A bad load keeps the keys we have
+	provider.err = errors.New("secret volume not mounted")
+	_, err = ring.Reload()
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, ring.Len())
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	assert.Nil(t, os.WriteFile(path, []byte("# rotated 2024-01-01\nfirst\n\n second ,third\n"), 0600))
+
+	provider, err := secrets.NewProvider(config.UpstreamConfig{KeySource: secrets.SOURCE_FILE, KeyFile: path})
+	assert.Nil(t, err)
+	keys, err := provider.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "second", "third"}, keys)
+
+	_, err = secrets.NewProvider(config.UpstreamConfig{KeySource: secrets.SOURCE_FILE})
+	assert.NotNil(t, err)
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("TEST_WEATHER_KEYS", "first, second")
+
+	provider, err := secrets.NewProvider(config.UpstreamConfig{KeySource: secrets.SOURCE_ENV, KeyEnv: "TEST_WEATHER_KEYS"})
+	assert.Nil(t, err)
+	keys, err := provider.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "second"}, keys)
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, "****cdef", secrets.Fingerprint("0123456789abcdef"))
+	assert.Equal(t, "****", secrets.Fingerprint("abc"))
+}
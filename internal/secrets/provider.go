@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bengimbel/go_redis_api/internal/config"
+)
+
+const (
+	SOURCE_ENV  string = "env"
+	SOURCE_FILE string = "file"
+)
+
+// Somewhere api keys are kept. Load is called again
+// on every reload, so it should return the current keys.
+type SecretProvider interface {
+	Load() ([]string, error)
+}
+
+// Keys from a comma separated environment variable
+type EnvProvider struct {
+	Name string
+}
+
+// Keys from a file, one per line or comma separated. Blank
+// lines and lines starting with # are skipped. It is read
+// again on every load, so a secret volume that is updated
+// in place (as kubernetes does) is picked up.
+type FileProvider struct {
+	Path string
+}
+
+// Create the provider the upstream config asks for
+func NewProvider(cfg config.UpstreamConfig) (SecretProvider, error) {
+	switch cfg.KeySource {
+	case SOURCE_ENV:
+		return &EnvProvider{Name: cfg.KeyEnv}, nil
+	case SOURCE_FILE:
+		if cfg.KeyFile == "" {
+			return nil, fmt.Errorf("a key file is required for the %s key source", SOURCE_FILE)
+		}
+		return &FileProvider{Path: cfg.KeyFile}, nil
+	default:
+		return nil, fmt.Errorf("unknown api key source: %s", cfg.KeySource)
+	}
+}
+
+func (ep *EnvProvider) Load() ([]string, error) {
+	return splitKeys(os.Getenv(ep.Name)), nil
+}
+
+func (fp *FileProvider) Load() ([]string, error) {
+	contents, err := os.ReadFile(fp.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api key file: %w", err)
+	}
+
+	keys := []string{}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		keys = append(keys, splitKeys(line)...)
+	}
+	return keys, nil
+}
+
+func splitKeys(value string) []string {
+	keys := []string{}
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
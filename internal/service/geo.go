@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/provider"
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/bengimbel/go_redis_api/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// States are only supported in the US
+	STATE_COUNTRY string = "us"
+)
+
+// A place name to look up, optionally narrowed to an ISO
+// 3166 country code, and for the US to a state code too
+type GeoQuery struct {
+	Query   string
+	State   string
+	Country string
+	Limit   int
+}
+
+// Create a query. A state without a country is taken to be in the
+// US, otherwise the provider would read the state as a country.
+func NewGeoQuery(query string, state string, country string, limit int) GeoQuery {
+	if strings.TrimSpace(state) != "" && strings.TrimSpace(country) == "" {
+		country = STATE_COUNTRY
+	}
+	return GeoQuery{
+		Query:   query,
+		State:   state,
+		Country: country,
+		Limit:   limit,
+	}
+}
+
+type GeoServiceImplementor interface {
+	SearchLocations(context.Context, GeoQuery) ([]model.GeoLocation, error)
+	ReverseGeocode(context.Context, float64, float64, int) ([]model.GeoLocation, error)
+}
+
+// Geocoding through the weather provider, with
+// results cached since places rarely change
+type GeoService struct {
+	Cache    repository.GeoCacheImplementor
+	Geocoder provider.Geocoder
+}
+
+func NewGeoService(cache repository.GeoCacheImplementor, geocoder provider.Geocoder) *GeoService {
+	return &GeoService{
+		Cache:    cache,
+		Geocoder: geocoder,
+	}
+}
+
+// Every place matching the query, up to its limit
+func (gs *GeoService) SearchLocations(ctx context.Context, query GeoQuery) ([]model.GeoLocation, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "GeoService.SearchLocations",
+		trace.WithAttributes(attribute.String("geo.query", query.Query)),
+	)
+	defer span.End()
+
+	q := query.String()
+	key := "search:" + q + "|" + strconv.Itoa(query.Limit)
+	return gs.cached(ctx, key, func() ([]model.GeoLocation, error) {
+		return gs.Geocoder.SearchLocations(ctx, q, query.Limit)
+	})
+}
+
+// The places at coordinates, nearest first, up to limit
+func (gs *GeoService) ReverseGeocode(ctx context.Context, lat float64, lon float64, limit int) ([]model.GeoLocation, error) {
+	id := model.LocationId(lat, lon)
+	ctx, span := tracing.Tracer().Start(ctx, "GeoService.ReverseGeocode",
+		trace.WithAttributes(attribute.String("geo.location", id)),
+	)
+	defer span.End()
+
+	key := "reverse:" + id + "|" + strconv.Itoa(limit)
+	return gs.cached(ctx, key, func() ([]model.GeoLocation, error) {
+		return gs.Geocoder.ReverseGeocode(ctx, lat, lon, limit)
+	})
+}
+
+// Serve a lookup from the cache, or make it and cache the
+// result. The cache is only an optimisation, so failing to
+// use it is logged and we go to the provider.
+func (gs *GeoService) cached(ctx context.Context, key string, lookup func() ([]model.GeoLocation, error)) ([]model.GeoLocation, error) {
+	if locations, err := gs.Cache.FindLocations(ctx, key); err == nil {
+		return locations, nil
+	}
+
+	locations, err := lookup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to geocode: %w", err)
+	}
+	if err := gs.Cache.InsertLocations(ctx, key, locations); err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "Failed to cache locations", "key", key, "error", err)
+	}
+
+	return locations, nil
+}
+
+// The query as the provider takes it, like "portland,or,us"
+func (gq GeoQuery) String() string {
+	parts := []string{strings.TrimSpace(gq.Query)}
+	for _, part := range []string{gq.State, gq.Country} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.ToLower(strings.Join(parts, ","))
+}
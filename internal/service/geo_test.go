@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockGeoCache struct {
+	mock.Mock
+}
+
+type MockGeocoder struct {
+	mock.Mock
+}
+
+func (mgc *MockGeoCache) FindLocations(ctx context.Context, key string) ([]model.GeoLocation, error) {
+	args := mgc.Called(ctx, key)
+	locations, _ := args.Get(0).([]model.GeoLocation)
+	return locations, args.Error(1)
+}
+
+func (mgc *MockGeoCache) InsertLocations(ctx context.Context, key string, locations []model.GeoLocation) error {
+	args := mgc.Called(ctx, key, locations)
+	return args.Error(0)
+}
+
+func (mg *MockGeocoder) SearchLocations(ctx context.Context, query string, limit int) ([]model.GeoLocation, error) {
+	args := mg.Called(ctx, query, limit)
+	locations, _ := args.Get(0).([]model.GeoLocation)
+	return locations, args.Error(1)
+}
+
+func (mg *MockGeocoder) ReverseGeocode(ctx context.Context, lat float64, lon float64, limit int) ([]model.GeoLocation, error) {
+	args := mg.Called(ctx, lat, lon, limit)
+	locations, _ := args.Get(0).([]model.GeoLocation)
+	return locations, args.Error(1)
+}
+
+var portlands = []model.GeoLocation{
+	model.NewGeoLocation(model.WeatherCoordinates{Name: "Portland", State: "Oregon", Country: "US", Lat: 45.5152, Lon: -122.6784}),
+	model.NewGeoLocation(model.WeatherCoordinates{Name: "Portland", State: "Maine", Country: "US", Lat: 43.6591, Lon: -70.2568}),
+}
+
+func TestSearchLocationsCachesEveryCandidate(t *testing.T) {
+	cache := new(MockGeoCache)
+	geocoder := new(MockGeocoder)
+	geoService := service.NewGeoService(cache, geocoder)
+
+	cache.On("FindLocations", mock.Anything, "search:portland,us|5").Return(nil, errors.New("miss"))
+	geocoder.On("SearchLocations", mock.Anything, "portland,us", 5).Return(portlands, nil)
+	cache.On("InsertLocations", mock.Anything, "search:portland,us|5", portlands).Return(nil)
+
+	locations, err := geoService.SearchLocations(context.Background(), service.GeoQuery{Query: " Portland", Country: "US", Limit: 5})
+
+	assert.NoError(t, err)
+	assert.Equal(t, portlands, locations)
+	assert.Equal(t, "45.5152,-122.6784", locations[0].Id)
+	cache.AssertExpectations(t)
+	geocoder.AssertExpectations(t)
+}
+
+func TestSearchLocationsServesFromCache(t *testing.T) {
+	cache := new(MockGeoCache)
+	geocoder := new(MockGeocoder)
+	geoService := service.NewGeoService(cache, geocoder)
+
+	cache.On("FindLocations", mock.Anything, "search:portland,or,us|5").Return(portlands[:1], nil)
+
+	locations, err := geoService.SearchLocations(context.Background(), service.GeoQuery{Query: "portland", State: "OR", Country: "us", Limit: 5})
+
+	assert.NoError(t, err)
+	assert.Equal(t, portlands[:1], locations)
+	geocoder.AssertNotCalled(t, "SearchLocations", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReverseGeocodeIgnoresCacheErrors(t *testing.T) {
+	cache := new(MockGeoCache)
+	geocoder := new(MockGeocoder)
+	geoService := service.NewGeoService(cache, geocoder)
+
+	cache.On("FindLocations", mock.Anything, "reverse:43.6591,-70.2568|1").Return(nil, errors.New("redis is down"))
+	geocoder.On("ReverseGeocode", mock.Anything, 43.6591, -70.2568, 1).Return(portlands[1:], nil)
+	cache.On("InsertLocations", mock.Anything, "reverse:43.6591,-70.2568|1", portlands[1:]).Return(errors.New("redis is down"))
+
+	locations, err := geoService.ReverseGeocode(context.Background(), 43.6591, -70.2568, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, portlands[1:], locations)
+}
+
+func TestSearchLocationsReturnsGeocoderError(t *testing.T) {
+	cache := new(MockGeoCache)
+	geocoder := new(MockGeocoder)
+	geoService := service.NewGeoService(cache, geocoder)
+
+	cache.On("FindLocations", mock.Anything, mock.Anything).Return(nil, errors.New("miss"))
+	geocoder.On("SearchLocations", mock.Anything, "paris", 5).Return(nil, errors.New("upstream failed"))
+
+	_, err := geoService.SearchLocations(context.Background(), service.GeoQuery{Query: "Paris", Limit: 5})
+
+	assert.ErrorContains(t, err, "upstream failed")
+	cache.AssertNotCalled(t, "InsertLocations", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -4,127 +4,93 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"sync"
+	"time"
 
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/metrics"
 	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/provider"
 	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/bengimbel/go_redis_api/internal/tracing"
 	"github.com/bengimbel/go_redis_api/pkg/httpClient"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const (
-	FETCH_COORDIANTES_PATH string = "/geo/1.0/direct"
-	FETCH_WEATHER_PATH     string = "/data/2.5/forecast"
-	QUERY_PARAM_LAT        string = "lat"
-	QUERY_PARAM_LON        string = "lon"
-	QUERY_PARAM_Q          string = "q"
-	APP_ID_KEY             string = "appid"
-	APIKEY                 string = "APIKEY"
-)
-
+// Fallback is optional. It is used when the
+// Provider fails to fetch a city's weather.
 type WeatherService struct {
-	Repo       repository.RedisImplementor
-	HttpClient httpClient.HttpImplementor
+	Repo     repository.RedisImplementor
+	Provider provider.WeatherProvider
+	Fallback provider.WeatherProvider
+	// Optional, told about every city's weather
+	// we fetch, e.g. to check alert rules
+	Observer WeatherObserver
+	// Async inserts still running, so
+	// shutdown can wait for them to finish
+	inserts sync.WaitGroup
 }
 
 type WeatherServiceImplementor interface {
-	FetchCoordinates(*httpClient.HttpConfig) ([]model.WeatherCoordinates, error)
-	FetchWeatherByCity(*httpClient.HttpConfig) (model.WeatherResponse, error)
-	RetrieveAndCacheWeatherAsync(context.Context, string) (model.WeatherResponse, error)
-	RetrieveWeatherFromCache(context.Context, string) (model.WeatherResponse, error)
+	RetrieveAndCacheWeatherAsync(context.Context, string) (model.CachedWeather, error)
+	RetrieveWeatherFromCache(context.Context, string) (model.CachedWeather, error)
 	DoesKeyExist(context.Context, string) bool
-	InsertToCacheAsync(context.Context, string, model.WeatherResponse) error
+	InsertToCacheAsync(context.Context, string, model.CachedWeather) error
+	RecordCityRequest(context.Context, string) error
+	PopularCities(context.Context, int) ([]string, error)
+	RefreshWeather(context.Context, string) (model.CachedWeather, error)
+	InvalidateCity(context.Context, string) error
 }
 
-func NewWeatherService(rds *redis.Client) *WeatherService {
-	return &WeatherService{
-		Repo:       repository.NewRedisRepo(rds),
-		HttpClient: httpClient.NewHttpClient(),
-	}
+// Gets a city's weather each time it is fetched from
+// upstream. It is called on the request's go-routine,
+// so it must not block.
+type WeatherObserver interface {
+	ObserveWeather(context.Context, string, model.CachedWeather)
 }
 
-// Build request struct for fetching city coordinates
-func BuildLatLonRequest(city string) *httpClient.HttpConfig {
-	return &httpClient.HttpConfig{
-		Path: FETCH_COORDIANTES_PATH,
-		Query: []httpClient.QueryParams{
-			{
-				Key:   QUERY_PARAM_Q,
-				Value: city,
-			},
-			{
-				Key:   APP_ID_KEY,
-				Value: os.Getenv(APIKEY),
-			},
-		},
-	}
-}
-
-// Build request struct for fetching city's weather from coordinate request
-func BuildCityWeatherRequest(coordinates model.WeatherCoordinates) *httpClient.HttpConfig {
-	return &httpClient.HttpConfig{
-		Path: FETCH_WEATHER_PATH,
-		Query: []httpClient.QueryParams{
-			{
-				Key:   QUERY_PARAM_LAT,
-				Value: fmt.Sprintf("%f", coordinates.Lat),
-			},
-			{
-				Key:   QUERY_PARAM_LON,
-				Value: fmt.Sprintf("%f", coordinates.Lon),
-			},
-			{
-				Key:   APP_ID_KEY,
-				Value: os.Getenv(APIKEY),
-			},
-		},
-	}
-}
-
-// Fetch city coordinates using the weatherHTTPClient.
-// If network error, return it. If no results, return a basic error
-// If no error, return results
-func (ws *WeatherService) FetchCoordinates(config *httpClient.HttpConfig) ([]model.WeatherCoordinates, error) {
-	weatherCoordinates := []model.WeatherCoordinates{}
-
-	if err := ws.HttpClient.MakeWeatherRequest(config, &weatherCoordinates); err != nil {
-		return weatherCoordinates, errors.New("Error fetching coordinates. Check if API key is valid.")
-	} else if len(weatherCoordinates) == 0 {
-		return weatherCoordinates, fmt.Errorf("Error fetching city coordinates by name: %s", config.Query[0].Value)
+func NewWeatherService(repo repository.RedisImplementor, rds *redis.Client, cfg *config.Config, log *slog.Logger) (*WeatherService, error) {
+	primary, err := provider.NewProvider(cfg.Provider.Primary, rds, cfg, log)
+	if err != nil {
+		return nil, err
 	}
 
-	return weatherCoordinates, nil
-}
-
-// Fetch city's weather using lat lon from above request
-// using the weatherHTTPClient.
-// If network error, return it. If no error, return results
-func (ws *WeatherService) FetchWeatherByCity(config *httpClient.HttpConfig) (model.WeatherResponse, error) {
-	weatherResponse := model.WeatherResponse{}
-
-	if err := ws.HttpClient.MakeWeatherRequest(config, &weatherResponse); err != nil {
-		return weatherResponse, fmt.Errorf("Error fetching city by coordinates: %s", err)
+	var fallback provider.WeatherProvider
+	if cfg.Provider.Fallback != "" {
+		if fallback, err = provider.NewProvider(cfg.Provider.Fallback, rds, cfg, log); err != nil {
+			return nil, err
+		}
 	}
 
-	// Just saving and returning first entry in the list of results
-	weatherResponse.List = []model.List{weatherResponse.List[0]}
-
-	return weatherResponse, nil
+	return &WeatherService{
+		Repo:     repo,
+		Provider: primary,
+		Fallback: fallback,
+	}, nil
 }
 
 // Function that will asynchronously add result to the redis cache
-func (ws *WeatherService) InsertToCacheAsync(ctx context.Context, city string, weatherResponse model.WeatherResponse) error {
+func (ws *WeatherService) InsertToCacheAsync(ctx context.Context, city string, weatherResponse model.CachedWeather) error {
 	// Error channel to communicate the error back to the main function
 	errChannel := make(chan error, 1)
 
+	metrics.AsyncInsertsInFlight.Inc()
+	ws.inserts.Add(1)
 	go func() {
+		defer ws.inserts.Done()
+		defer metrics.AsyncInsertsInFlight.Dec()
+
 		// Async insert to redis
 		if err := ws.Repo.Insert(ctx, city, weatherResponse); err != nil {
 			// If error, Sending error to channel
 			errChannel <- fmt.Errorf("error adding city weather to redis cache: %w", err)
 		} else {
-			log.Println("Successfully added city weather to redis cache")
+			logger.FromContext(ctx).DebugContext(ctx, "Added city weather to redis cache", "city", city)
 		}
 	}()
 
@@ -138,45 +104,121 @@ func (ws *WeatherService) InsertToCacheAsync(ctx context.Context, city string, w
 	}
 }
 
-// This wraps the two functions above, making sure that
-// coordinate request succeeds first before moving to the
-// second network request. We need to make two network requests
-// because we first need to fetch city coordinates (lat lon) by city name
-// then using the lat lon we can fetch the weather.
-// If an error, we return the error with a empty struct.
+// Wait for async inserts to finish, or
+// until the context is done if that is sooner
+func (ws *WeatherService) WaitForInserts(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		ws.inserts.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("gave up waiting for async cache inserts: %w", ctx.Err())
+	}
+}
+
+// Fetch a city's weather from our providers, then
+// cache it. If an error, we return the error with a empty struct.
 // If no error we return the results struct with nil as error.
-func (ws *WeatherService) RetrieveAndCacheWeatherAsync(ctx context.Context, city string) (model.WeatherResponse, error) {
-	// Fetch lat and lon by city name
-	coordinates, err := ws.FetchCoordinates(BuildLatLonRequest(city))
+func (ws *WeatherService) RetrieveAndCacheWeatherAsync(ctx context.Context, city string) (model.CachedWeather, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "WeatherService.RetrieveAndCacheWeatherAsync",
+		trace.WithAttributes(attribute.String("weather.city", city)),
+	)
+	defer span.End()
+
+	weatherResponse, err := ws.retrieveWeather(ctx, city)
 	if err != nil {
-		return model.WeatherResponse{}, err
+		// If we are out of upstream quota, serve the
+		// stale copy rather than an error if we have one.
+		var quotaErr *httpClient.QuotaExceededError
+		if errors.As(err, &quotaErr) {
+			if stale, staleErr := ws.Repo.FindStaleByCity(ctx, city); staleErr == nil {
+				logger.FromContext(ctx).WarnContext(ctx, "Serving stale weather", "city", city, "error", err)
+				span.SetAttributes(attribute.Bool("weather.stale", true))
+				return stale, nil
+			}
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return model.CachedWeather{}, err
 	}
-	if len(coordinates) == 0 {
-		return model.WeatherResponse{}, err
+	ws.observe(ctx, city, weatherResponse)
+	// If both requests are successful,
+	// Insert result into redis cache asynchronously
+	if err := ws.InsertToCacheAsync(ctx, city, weatherResponse); err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "Failed to cache city weather", "city", city, "error", err)
 	}
 
-	// Fetch city's weather by lat and lon
-	weatherResponse, err := ws.FetchWeatherByCity(BuildCityWeatherRequest(coordinates[0]))
+	return weatherResponse, nil
+}
+
+// Re-fetch a city's weather and write it to the cache
+// before returning. Unlike RetrieveAndCacheWeatherAsync
+// the insert is synchronous, since the background refresher
+// wants to know if the cache was actually updated.
+func (ws *WeatherService) RefreshWeather(ctx context.Context, city string) (model.CachedWeather, error) {
+	weatherResponse, err := ws.retrieveWeather(ctx, city)
 	if err != nil {
-		return model.WeatherResponse{}, err
+		return model.CachedWeather{}, err
 	}
-	// If both above requests are successful,
-	// Insert result into redis cache asynchronously
-	if err := ws.InsertToCacheAsync(ctx, city, weatherResponse); err != nil {
-		log.Println(err)
+
+	ws.observe(ctx, city, weatherResponse)
+
+	if err := ws.Repo.Insert(ctx, city, weatherResponse); err != nil {
+		return model.CachedWeather{}, fmt.Errorf("error refreshing city weather in redis cache: %w", err)
 	}
 
 	return weatherResponse, nil
 }
 
+func (ws *WeatherService) observe(ctx context.Context, city string, weather model.CachedWeather) {
+	if ws.Observer != nil {
+		ws.Observer.ObserveWeather(ctx, city, weather)
+	}
+}
+
+// Fetch a city's weather from the primary provider.
+// If that fails and we have a fallback provider, try it instead.
+// If both fail we return the primary's error, so
+// quota errors still reach the caller.
+// The result is stamped with the time it was fetched,
+// and is fresh for the cache TTL.
+func (ws *WeatherService) retrieveWeather(ctx context.Context, city string) (model.CachedWeather, error) {
+	weatherResponse, err := ws.Provider.RetrieveWeather(ctx, city)
+	if err == nil {
+		return model.NewCachedWeather(weatherResponse, ws.Provider.Name(), time.Now(), repository.CACHE_TTL), nil
+	}
+	if ws.Fallback == nil {
+		return model.CachedWeather{}, err
+	}
+
+	trace.SpanFromContext(ctx).AddEvent("provider fallback", trace.WithAttributes(
+		attribute.String("weather.provider", ws.Provider.Name()),
+		attribute.String("weather.fallback", ws.Fallback.Name()),
+	))
+
+	logger.FromContext(ctx).WarnContext(ctx, "Provider failed, falling back", "provider", ws.Provider.Name(), "fallback", ws.Fallback.Name(), "error", err)
+	weatherResponse, fallbackErr := ws.Fallback.RetrieveWeather(ctx, city)
+	if fallbackErr != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "Fallback provider failed", "fallback", ws.Fallback.Name(), "error", fallbackErr)
+		return model.CachedWeather{}, err
+	}
+
+	return model.NewCachedWeather(weatherResponse, ws.Fallback.Name(), time.Now(), repository.CACHE_TTL), nil
+}
+
 // Function that wraps logic to interact with
 // redis cache and find results
-func (ws *WeatherService) RetrieveWeatherFromCache(ctx context.Context, city string) (model.WeatherResponse, error) {
+func (ws *WeatherService) RetrieveWeatherFromCache(ctx context.Context, city string) (model.CachedWeather, error) {
 	// Finds city's weather by key
 	weatherResponse, err := ws.Repo.FindByCity(ctx, city)
 	if err != nil {
-		log.Println(err)
-		return model.WeatherResponse{}, err
+		logger.FromContext(ctx).DebugContext(ctx, "City weather not in cache", "city", city, "error", err)
+		return model.CachedWeather{}, err
 	}
 	return weatherResponse, nil
 }
@@ -187,3 +229,56 @@ func (ws *WeatherService) DoesKeyExist(ctx context.Context, city string) bool {
 	// Checks if key exists
 	return ws.Repo.DoesKeyExist(ctx, city)
 }
+
+// Record that a city was requested so the
+// background refresher knows which cities are popular
+func (ws *WeatherService) RecordCityRequest(ctx context.Context, city string) error {
+	return ws.Repo.IncrementCityHits(ctx, city)
+}
+
+// Get the n most requested cities
+func (ws *WeatherService) PopularCities(ctx context.Context, n int) ([]string, error) {
+	return ws.Repo.TopCities(ctx, n)
+}
+
+// Remove a city's weather from the cache so
+// the next request fetches it from upstream
+func (ws *WeatherService) InvalidateCity(ctx context.Context, city string) error {
+	return ws.Repo.Delete(ctx, city)
+}
+
+// Get a city's weather cache-aside, for the weather handlers
+// and graphql. It is served from the cache if we have it, or
+// fetched from upstream and cached. A failed cache read falls
+// through to upstream rather than failing the request. Requests
+// are counted so popular cities are kept warm by the refresher.
+func RetrieveWeather(ctx context.Context, svc WeatherServiceImplementor, city string) (model.CachedWeather, error) {
+	var result model.CachedWeather
+
+	// Check the cache before fetching
+	keyExists := svc.DoesKeyExist(ctx, city)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("weather.cache_hit", keyExists))
+	if keyExists {
+		value, err := svc.RetrieveWeatherFromCache(ctx, city)
+		if err != nil {
+			// Redis may have gone away since we checked,
+			// so bypass the cache rather than fail the request
+			logger.FromContext(ctx).WarnContext(ctx, "Failed to read city weather from cache, fetching from upstream", "city", city, "error", err)
+			keyExists = false
+		}
+		result = value
+	}
+	if !keyExists {
+		value, err := svc.RetrieveAndCacheWeatherAsync(ctx, city)
+		if err != nil {
+			return model.CachedWeather{}, err
+		}
+		result = value
+	}
+
+	if err := svc.RecordCityRequest(ctx, city); err != nil {
+		logger.FromContext(ctx).WarnContext(ctx, "Failed to record city request", "city", city, "error", err)
+	}
+
+	return result, nil
+}
@@ -2,7 +2,9 @@ package service_test
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/bengimbel/go_redis_api/internal/model"
 	"github.com/bengimbel/go_redis_api/internal/repository"
@@ -13,8 +15,7 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
-type MockHttpClient struct {
-	httpClient.HttpImplementor
+type MockProvider struct {
 	mock.Mock
 }
 
@@ -25,18 +26,23 @@ type MockRedisRepo struct {
 }
 
 type MockService struct {
-	Repo       *MockRedisRepo
-	HttpClient *MockHttpClient
+	Repo     *MockRedisRepo
+	Provider *MockProvider
 }
 
-func (mds *MockRedisRepo) Insert(ctx context.Context, city string, weather model.WeatherResponse) error {
+func (mds *MockRedisRepo) Insert(ctx context.Context, city string, weather model.CachedWeather) error {
 	args := mds.Called(ctx, city, weather)
 	return args.Error(0)
 }
 
-func (mds *MockRedisRepo) FindByCity(ctx context.Context, city string) (model.WeatherResponse, error) {
+func (mds *MockRedisRepo) FindByCity(ctx context.Context, city string) (model.CachedWeather, error) {
 	args := mds.Called(ctx, city)
-	return args.Get(0).(model.WeatherResponse), args.Error(1)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
+}
+
+func (mds *MockRedisRepo) FindStaleByCity(ctx context.Context, city string) (model.CachedWeather, error) {
+	args := mds.Called(ctx, city)
+	return args.Get(0).(model.CachedWeather), args.Error(1)
 }
 
 func (mds *MockRedisRepo) DoesKeyExist(ctx context.Context, city string) bool {
@@ -44,99 +50,44 @@ func (mds *MockRedisRepo) DoesKeyExist(ctx context.Context, city string) bool {
 	return args.Bool(0)
 }
 
-func (mhc *MockHttpClient) MakeWeatherRequest(config *httpClient.HttpConfig, responseStruct interface{}) error {
-	args := mhc.Called(config, responseStruct)
+func (mds *MockRedisRepo) Delete(ctx context.Context, city string) error {
+	args := mds.Called(ctx, city)
 	return args.Error(0)
 }
 
-var mockRepo = &MockRedisRepo{}
+func (mds *MockRedisRepo) IncrementCityHits(ctx context.Context, city string) error {
+	args := mds.Called(ctx, city)
+	return args.Error(0)
+}
 
-var mockClient = &MockHttpClient{}
+func (mds *MockRedisRepo) TopCities(ctx context.Context, n int) ([]string, error) {
+	args := mds.Called(ctx, n)
+	return args.Get(0).([]string), args.Error(1)
+}
 
-var mockService = MockService{
-	Repo:       mockRepo,
-	HttpClient: mockClient,
+func (mp *MockProvider) Name() string {
+	return "mock"
 }
 
-var mockWeatherService = service.WeatherService{
-	Repo:       mockRepo,
-	HttpClient: mockClient,
+func (mp *MockProvider) RetrieveWeather(ctx context.Context, city string) (model.WeatherResponse, error) {
+	args := mp.Called(ctx, city)
+	return args.Get(0).(model.WeatherResponse), args.Error(1)
 }
 
-func TestFetchCoordinatesSuccess(t *testing.T) {
-	expected := []model.WeatherCoordinates{
-		{
-			Lat: 123.123000,
-			Lon: 456.456000,
-		},
-	}
-	httpConfig := &httpClient.HttpConfig{
-		Path: service.FETCH_COORDIANTES_PATH,
-		Query: []httpClient.QueryParams{
-			{
-				Key:   service.QUERY_PARAM_Q,
-				Value: "chicago",
-			},
-			{
-				Key:   service.APP_ID_KEY,
-				Value: "",
-			},
-		},
-	}
-	coordinates := []model.WeatherCoordinates{}
-	mockClient.On("MakeWeatherRequest", httpConfig, &coordinates).Return(nil).Once().Return(nil).Once().Run(func(args mock.Arguments) {
-		arg := args.Get(1).(*[]model.WeatherCoordinates)
-		*arg = append(*arg, model.WeatherCoordinates{
-			Lat: 123.123000,
-			Lon: 456.456000,
-		})
-	})
-	actual, _ := mockWeatherService.FetchCoordinates(httpConfig)
+var mockRepo = &MockRedisRepo{}
 
-	assert.EqualValues(t, expected, actual)
-}
+var mockProvider = &MockProvider{}
 
-func TestFetchWeatherSuccess(t *testing.T) {
-	expected := model.WeatherResponse{
-		City: model.City{
-			Name: "chicago",
-		},
-		List: []model.List{
-			{
-				Dt: 123,
-			},
-		},
-	}
-	httpConfig := &httpClient.HttpConfig{
-		Path: service.FETCH_WEATHER_PATH,
-		Query: []httpClient.QueryParams{
-			{
-				Key:   service.QUERY_PARAM_LAT,
-				Value: "123.123000",
-			},
-			{
-				Key:   service.QUERY_PARAM_LON,
-				Value: "456.456000",
-			},
-			{
-				Key:   service.APP_ID_KEY,
-				Value: "",
-			},
-		},
-	}
-	weather := model.WeatherResponse{}
-	mockClient.On("MakeWeatherRequest", httpConfig, &weather).Return(nil).Once().Return(nil).Once().Run(func(args mock.Arguments) {
-		arg := args.Get(1).(*model.WeatherResponse)
-		arg.City.Name = "chicago"
-		arg.List = []model.List{
-			{
-				Dt: 123,
-			},
-		}
-	})
-	actual, _ := mockWeatherService.FetchWeatherByCity(httpConfig)
+var mockFallback = &MockProvider{}
 
-	assert.EqualValues(t, expected, actual)
+var mockService = MockService{
+	Repo:     mockRepo,
+	Provider: mockProvider,
+}
+
+var mockWeatherService = service.WeatherService{
+	Repo:     mockRepo,
+	Provider: mockProvider,
 }
 
 func TestRetrieveAndCacheWeatherAsyncSuccess(t *testing.T) {
@@ -151,59 +102,13 @@ func TestRetrieveAndCacheWeatherAsyncSuccess(t *testing.T) {
 			},
 		},
 	}
-	coordinateConfig := &httpClient.HttpConfig{
-		Path: service.FETCH_COORDIANTES_PATH,
-		Query: []httpClient.QueryParams{
-			{
-				Key:   service.QUERY_PARAM_Q,
-				Value: "chicago",
-			},
-			{
-				Key:   service.APP_ID_KEY,
-				Value: "",
-			},
-		},
-	}
-	weatherConfig := &httpClient.HttpConfig{
-		Path: service.FETCH_WEATHER_PATH,
-		Query: []httpClient.QueryParams{
-			{
-				Key:   service.QUERY_PARAM_LAT,
-				Value: "123.123000",
-			},
-			{
-				Key:   service.QUERY_PARAM_LON,
-				Value: "456.456000",
-			},
-			{
-				Key:   service.APP_ID_KEY,
-				Value: "",
-			},
-		},
-	}
-	coordinates := []model.WeatherCoordinates{}
-	weather := model.WeatherResponse{}
-	mockClient.On("MakeWeatherRequest", coordinateConfig, &coordinates).Return(nil).Once().Return(nil).Once().Run(func(args mock.Arguments) {
-		arg := args.Get(1).(*[]model.WeatherCoordinates)
-		*arg = append(*arg, model.WeatherCoordinates{
-			Lat: 123.123000,
-			Lon: 456.456000,
-		})
-	})
-	mockClient.On("MakeWeatherRequest", weatherConfig, &weather).Return(nil).Once().Return(nil).Once().Run(func(args mock.Arguments) {
-		arg := args.Get(1).(*model.WeatherResponse)
-		arg.City.Name = "chicago"
-		arg.List = []model.List{
-			{
-				Dt: 123,
-			},
-		}
-	})
-
-	mockRepo.On("Insert", ctx, "chicago", expected).Return(nil).Once()
+	mockProvider.On("RetrieveWeather", mock.Anything, "chicago").Return(expected, nil).Once()
+	mockRepo.On("Insert", mock.Anything, "chicago", mock.AnythingOfType("model.CachedWeather")).Return(nil).Once()
 	actual, _ := mockWeatherService.RetrieveAndCacheWeatherAsync(ctx, "chicago")
 
-	assert.EqualValues(t, expected, actual)
+	assert.EqualValues(t, expected, actual.Weather)
+	assert.False(t, actual.FetchedAt.IsZero())
+	assert.EqualValues(t, repository.CACHE_TTL, actual.ExpiresAt.Sub(actual.FetchedAt))
 }
 
 func TestRetrieveWeatherFromCacheSuccess(t *testing.T) {
@@ -218,10 +123,11 @@ func TestRetrieveWeatherFromCacheSuccess(t *testing.T) {
 			},
 		},
 	}
-	mockRepo.On("FindByCity", ctx, "chicago").Return(expected, nil).Once()
+	cached := model.NewCachedWeather(expected, "mock", time.Now(), repository.CACHE_TTL)
+	mockRepo.On("FindByCity", ctx, "chicago").Return(cached, nil).Once()
 	actual, _ := mockWeatherService.RetrieveWeatherFromCache(ctx, "chicago")
 
-	assert.EqualValues(t, expected, actual)
+	assert.EqualValues(t, cached, actual)
 }
 
 func TestDoesKeyExist(t *testing.T) {
@@ -232,3 +138,108 @@ func TestDoesKeyExist(t *testing.T) {
 
 	assert.EqualValues(t, expected, actual)
 }
+
+func TestPopularCities(t *testing.T) {
+	ctx := context.Background()
+	expected := []string{"chicago", "miami"}
+	mockRepo.On("TopCities", ctx, 2).Return(expected, nil).Once()
+	actual, err := mockWeatherService.PopularCities(ctx, 2)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, expected, actual)
+}
+
+func TestRetrieveAndCacheWeatherAsyncServesStaleWhenQuotaExceeded(t *testing.T) {
+	ctx := context.Background()
+	expected := model.WeatherResponse{
+		City: model.City{
+			Name: "detroit",
+		},
+	}
+	quotaErr := &httpClient.QuotaExceededError{
+		Reason:     httpClient.QUOTA_REASON_RATE,
+		RetryAfter: time.Second,
+	}
+	mockProvider.On("RetrieveWeather", mock.Anything, "detroit").Return(model.WeatherResponse{}, quotaErr).Once()
+	mockRepo.On("FindStaleByCity", mock.Anything, "detroit").Return(model.CachedWeather{Weather: expected}, nil).Once()
+	actual, err := mockWeatherService.RetrieveAndCacheWeatherAsync(ctx, "detroit")
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, expected, actual.Weather)
+}
+
+func TestRetrieveAndCacheWeatherAsyncUsesFallbackProvider(t *testing.T) {
+	ctx := context.Background()
+	expected := model.WeatherResponse{
+		City: model.City{
+			Name: "denver",
+		},
+	}
+	weatherService := service.WeatherService{
+		Repo:     mockRepo,
+		Provider: mockProvider,
+		Fallback: mockFallback,
+	}
+	mockProvider.On("RetrieveWeather", mock.Anything, "denver").Return(model.WeatherResponse{}, errors.New("primary down")).Once()
+	mockFallback.On("RetrieveWeather", mock.Anything, "denver").Return(expected, nil).Once()
+	mockRepo.On("Insert", mock.Anything, "denver", mock.AnythingOfType("model.CachedWeather")).Return(nil).Once()
+	actual, err := weatherService.RetrieveAndCacheWeatherAsync(ctx, "denver")
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, expected, actual.Weather)
+}
+
+func TestWaitForInsertsWaitsForAsyncInsert(t *testing.T) {
+	ctx := context.Background()
+	repo := &MockRedisRepo{}
+	weatherService := service.WeatherService{
+		Repo: repo,
+	}
+	weather := model.NewCachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "boise",
+		},
+	}, "mock", time.Now(), time.Minute)
+	repo.On("Insert", mock.Anything, "boise", weather).After(50 * time.Millisecond).Return(nil).Once()
+
+	weatherService.InsertToCacheAsync(ctx, "boise", weather)
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	err := weatherService.WaitForInserts(timeout)
+
+	assert.Nil(t, err)
+	repo.AssertCalled(t, "Insert", mock.Anything, "boise", weather)
+}
+
+type MockObserver struct {
+	mock.Mock
+}
+
+func (mo *MockObserver) ObserveWeather(ctx context.Context, city string, weather model.CachedWeather) {
+	mo.Called(ctx, city, weather)
+}
+
+func TestRefreshWeatherNotifiesObserver(t *testing.T) {
+	ctx := context.Background()
+	observer := &MockObserver{}
+	weatherService := service.WeatherService{
+		Repo:     mockRepo,
+		Provider: mockProvider,
+		Observer: observer,
+	}
+	expected := model.WeatherResponse{
+		City: model.City{
+			Name: "fargo",
+		},
+	}
+	mockProvider.On("RetrieveWeather", mock.Anything, "fargo").Return(expected, nil).Once()
+	mockRepo.On("Insert", mock.Anything, "fargo", mock.AnythingOfType("model.CachedWeather")).Return(nil).Once()
+	observer.On("ObserveWeather", mock.Anything, "fargo", mock.MatchedBy(func(weather model.CachedWeather) bool {
+		return weather.Weather.City.Name == "fargo"
+	})).Once()
+
+	_, err := weatherService.RefreshWeather(ctx, "fargo")
+
+	assert.Nil(t, err)
+	observer.AssertExpectations(t)
+}
@@ -0,0 +1,176 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/bengimbel/go_redis_api/internal/logger"
+	"github.com/bengimbel/go_redis_api/internal/metrics"
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrTooManySubscribers = errors.New("too many clients streaming weather")
+	ErrHubClosed          = errors.New("weather stream is shutting down")
+)
+
+type HubImplementor interface {
+	Subscribe(string) (*Subscription, error)
+	Unsubscribe(*Subscription)
+}
+
+// One client streaming a city. Updates only holds the
+// latest value, so a slow client skips to the newest
+// weather instead of backing up the hub. It is closed
+// when the hub shuts down.
+type Subscription struct {
+	City    string
+	Updates chan model.CachedWeather
+}
+
+// Fans weather updates published to redis out to the
+// clients streaming each city. Every replica has one
+// hub with a single redis subscription, however many
+// clients it is serving.
+type Hub struct {
+	Client     *redis.Client
+	MaxClients int
+
+	mu          sync.Mutex
+	subscribers map[string]map[*Subscription]struct{}
+	count       int
+	closed      bool
+}
+
+// Create a new Hub. A MaxClients of 0 means no limit.
+func NewHub(rds *redis.Client, maxClients int) *Hub {
+	return &Hub{
+		Client:      rds,
+		MaxClients:  maxClients,
+		subscribers: map[string]map[*Subscription]struct{}{},
+	}
+}
+
+// Start listening for weather updates. This blocks until
+// the context is cancelled, so it should be run on its own
+// go-routine. go-redis resubscribes on its own if the
+// connection drops.
+func (h *Hub) Start(ctx context.Context) {
+	pubsub := h.Client.PSubscribe(ctx, repository.UPDATES_CHANNEL_PREFIX+"*")
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var weather model.CachedWeather
+			if err := json.Unmarshal([]byte(msg.Payload), &weather); err != nil {
+				logger.FromContext(ctx).WarnContext(ctx, "Failed to decode weather update", "channel", msg.Channel, "error", err)
+				continue
+			}
+			h.Publish(strings.TrimPrefix(msg.Channel, repository.UPDATES_CHANNEL_PREFIX), weather)
+		}
+	}
+}
+
+// Send weather to everyone streaming the city
+func (h *Hub) Publish(city string, weather model.CachedWeather) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers[strings.ToLower(city)] {
+		replaceLatest(sub.Updates, weather)
+	}
+}
+
+// Start streaming a city
+func (h *Hub) Subscribe(city string) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	if h.MaxClients > 0 && h.count >= h.MaxClients {
+		return nil, ErrTooManySubscribers
+	}
+
+	city = strings.ToLower(city)
+	sub := &Subscription{
+		City:    city,
+		Updates: make(chan model.CachedWeather, 1),
+	}
+	if h.subscribers[city] == nil {
+		h.subscribers[city] = map[*Subscription]struct{}{}
+	}
+	h.subscribers[city][sub] = struct{}{}
+	h.count++
+	metrics.StreamClients.Inc()
+
+	return sub, nil
+}
+
+// Stop streaming. It is safe to call more than once,
+// and after the hub is closed.
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs, ok := h.subscribers[sub.City]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.City)
+	}
+	h.count--
+	metrics.StreamClients.Dec()
+}
+
+// Close every subscription so open streams end, and refuse
+// new ones. Streams never go idle on their own, so this
+// has to run before the http server can shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for city, subs := range h.subscribers {
+		for sub := range subs {
+			close(sub.Updates)
+		}
+		delete(h.subscribers, city)
+	}
+	metrics.StreamClients.Sub(float64(h.count))
+	h.count = 0
+}
+
+// Put weather on the channel, dropping
+// whatever value the client hasn't read yet
+func replaceLatest(updates chan model.CachedWeather, weather model.CachedWeather) {
+	for {
+		select {
+		case updates <- weather:
+			return
+		default:
+		}
+		select {
+		case <-updates:
+		default:
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package stream_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/stream"
+	"github.com/stretchr/testify/assert"
+)
+
+func weatherFetchedAt(fetchedAt time.Time) model.CachedWeather {
+	return model.NewCachedWeather(model.WeatherResponse{
+		City: model.City{
+			Name: "chicago",
+		},
+	}, "mock", fetchedAt, time.Minute)
+}
+
+func TestPublishKeepsOnlyLatestUpdate(t *testing.T) {
+	hub := stream.NewHub(nil, 0)
+	sub, err := hub.Subscribe("Chicago")
+	assert.Nil(t, err)
+
+	first := weatherFetchedAt(time.Now().Add(-time.Minute))
+	latest := weatherFetchedAt(time.Now())
+	hub.Publish("chicago", first)
+	hub.Publish("chicago", latest)
+	hub.Publish("miami", first)
+
+	assert.EqualValues(t, latest, <-sub.Updates)
+	assert.Len(t, sub.Updates, 0)
+}
+
+func TestSubscribeLimitsClients(t *testing.T) {
+	hub := stream.NewHub(nil, 1)
+	sub, err := hub.Subscribe("chicago")
+	assert.Nil(t, err)
+
+	_, err = hub.Subscribe("miami")
+	assert.ErrorIs(t, err, stream.ErrTooManySubscribers)
+
+	hub.Unsubscribe(sub)
+	hub.Unsubscribe(sub)
+	_, err = hub.Subscribe("miami")
+	assert.Nil(t, err)
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	hub := stream.NewHub(nil, 0)
+	sub, err := hub.Subscribe("chicago")
+	assert.Nil(t, err)
+
+	hub.Close()
+	_, ok := <-sub.Updates
+	assert.False(t, ok)
+
+	_, err = hub.Subscribe("chicago")
+	assert.ErrorIs(t, err, stream.ErrHubClosed)
+	hub.Unsubscribe(sub)
+}
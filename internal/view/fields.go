@@ -0,0 +1,149 @@
+package view
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	MAX_FIELDS int = 50
+)
+
+var ErrUnknownField = errors.New("unknown field")
+
+// A set of selected fields, by json name. A nil
+// subtree means the whole field was selected.
+type fieldTree map[string]fieldTree
+
+// Parse a comma separated list of fields, like
+// "city.name,list.dt,list.main.temp", checking each against
+// the json names of value's type. A dot selects a field of an
+// object, or of every object in a list.
+func ParseFields(value string, of interface{}) ([]string, error) {
+	fields := []string{}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !hasField(reflect.TypeOf(of), strings.Split(field, ".")) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownField, field)
+		}
+		fields = append(fields, field)
+	}
+
+	if len(fields) == 0 {
+		return nil, errors.New("fields must name at least one field")
+	}
+	if len(fields) > MAX_FIELDS {
+		return nil, fmt.Errorf("at most %d fields can be selected", MAX_FIELDS)
+	}
+	return fields, nil
+}
+
+// Keep only the selected fields of a value, as it would be
+// rendered as json. The result is a map rather than the value's
+// type, so fields that weren't selected are left out entirely.
+func SelectFields(value interface{}, fields []string) (map[string]interface{}, error) {
+	tree := fieldTree{}
+	for _, field := range fields {
+		tree.add(strings.Split(field, "."))
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode value for field selection: %w", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("can only select fields of an object: %w", err)
+	}
+
+	return tree.selectFrom(decoded).(map[string]interface{}), nil
+}
+
+func (ft fieldTree) add(path []string) {
+	subtree, ok := ft[path[0]]
+	if ok && subtree == nil {
+		// The whole field is already selected
+		return
+	}
+	if len(path) == 1 {
+		ft[path[0]] = nil
+		return
+	}
+	if subtree == nil {
+		subtree = fieldTree{}
+		ft[path[0]] = subtree
+	}
+	subtree.add(path[1:])
+}
+
+func (ft fieldTree) selectFrom(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		selected := map[string]interface{}{}
+		for name, subtree := range ft {
+			field, ok := v[name]
+			if !ok {
+				continue
+			}
+			if subtree == nil {
+				selected[name] = field
+			} else {
+				selected[name] = subtree.selectFrom(field)
+			}
+		}
+		return selected
+	case []interface{}:
+		selected := make([]interface{}, len(v))
+		for i, item := range v {
+			selected[i] = ft.selectFrom(item)
+		}
+		return selected
+	default:
+		return value
+	}
+}
+
+// Whether a dotted path of json names exists on a type, looking
+// through pointers, into slice elements and embedded structs
+func hasField(typ reflect.Type, path []string) bool {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	if len(path) == 0 {
+		return true
+	}
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// Embedded fields are flattened into the parent
+		if field.Anonymous && name == "" {
+			if hasField(field.Type, path) {
+				return true
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if name == path[0] {
+			return hasField(field.Type, path[1:])
+		}
+	}
+	return false
+}
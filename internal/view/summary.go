@@ -0,0 +1,121 @@
+// Views map our weather models into the shapes clients ask
+// for, so the api can change what it returns without the
+// models, which follow upstream providers, changing with it.
+package view
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+)
+
+const (
+	ICON_URL_FORMAT string = "https://openweathermap.org/img/wn/%s@2x.png"
+)
+
+// Flattened, human oriented weather with just what a client
+// shows: Celsius, meters per second and local times
+type Summary struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+	// e.g. UTC-07:00, or the zone the caller asked for
+	TimeZone  string            `json:"time_zone"`
+	Forecasts []SummaryForecast `json:"forecasts"`
+}
+
+type SummaryForecast struct {
+	Time time.Time `json:"time"`
+	// The same time in the city's time zone,
+	// or the zone the caller asked for
+	LocalTime string `json:"local_time"`
+	// Whether the sun is up
+	IsDay     *bool   `json:"is_day,omitempty"`
+	Temp      float32 `json:"temp"`
+	FeelsLike float32 `json:"feels_like"`
+	// e.g. "light snow"
+	Condition string  `json:"condition"`
+	IconURL   string  `json:"icon_url,omitempty"`
+	WindSpeed float32 `json:"wind_speed"`
+	WindGust  float32 `json:"wind_gust"`
+	// Where the wind comes from, in degrees
+	WindDeg float32 `json:"wind_deg"`
+	// Chance of precipitation from 0 to 1
+	PrecipitationChance float32 `json:"precipitation_chance"`
+}
+
+// Summarize a normalized forecast, with local times in
+// zone, or in the city's offset from UTC if zone is nil
+func NewSummary(forecast model.Forecast, zone *time.Location) Summary {
+	location := forecast.Location
+	local := timeZone(location.Timezone, zone)
+	summary := Summary{
+		City:      location.Name,
+		Country:   location.Country,
+		TimeZone:  local.String(),
+		Forecasts: []SummaryForecast{},
+	}
+
+	for _, entry := range forecast.Entries {
+		at := time.Unix(entry.Time, 0)
+		condition := entry.Description
+		if condition == "" {
+			condition = entry.Condition
+		}
+
+		summary.Forecasts = append(summary.Forecasts, SummaryForecast{
+			Time:                at.UTC(),
+			LocalTime:           at.In(local).Format(time.RFC3339),
+			IsDay:               isDay(entry.Time, location.Sunrise, location.Sunset, location.Timezone),
+			Temp:                entry.Temp,
+			FeelsLike:           entry.FeelsLike,
+			Condition:           condition,
+			IconURL:             IconURL(entry.Icon),
+			WindSpeed:           entry.WindSpeed,
+			WindGust:            entry.WindGust,
+			WindDeg:             entry.WindDeg,
+			PrecipitationChance: entry.PrecipitationChance,
+		})
+	}
+
+	return summary
+}
+
+// Url of an open weather map icon, if there is one
+func IconURL(icon string) string {
+	if icon == "" {
+		return ""
+	}
+	return fmt.Sprintf(ICON_URL_FORMAT, icon)
+}
+
+// The summary as csv, one row per forecast
+func (s Summary) MarshalCSV() ([][]string, error) {
+	records := [][]string{{
+		"city", "country", "time", "local_time", "temp", "feels_like",
+		"condition", "icon_url", "wind_speed", "wind_gust", "wind_deg", "precipitation_chance",
+	}}
+	for _, forecast := range s.Forecasts {
+		records = append(records, []string{
+			s.City,
+			s.Country,
+			forecast.Time.Format(time.RFC3339),
+			forecast.LocalTime,
+			formatFloat(forecast.Temp),
+			formatFloat(forecast.FeelsLike),
+			forecast.Condition,
+			forecast.IconURL,
+			formatFloat(forecast.WindSpeed),
+			formatFloat(forecast.WindGust),
+			formatFloat(forecast.WindDeg),
+			formatFloat(forecast.PrecipitationChance),
+		})
+	}
+
+	return records, nil
+}
+
+func formatFloat(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
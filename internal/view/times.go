@@ -0,0 +1,58 @@
+package view
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	SECONDS_PER_DAY int64 = 24 * 60 * 60
+)
+
+// Where times are rendered: the zone the caller asked
+// for, or else the city's own offset from UTC
+func timeZone(offset int32, zone *time.Location) *time.Location {
+	if zone != nil {
+		return zone
+	}
+	return CityZone(offset)
+}
+
+// A fixed zone for a city's offset from UTC in
+// seconds, named like UTC-07:00 or UTC+05:30
+func CityZone(offset int32) *time.Location {
+	sign := "+"
+	abs := offset
+	if offset < 0 {
+		sign = "-"
+		abs = -offset
+	}
+	name := fmt.Sprintf("UTC%s%02d:%02d", sign, abs/3600, abs%3600/60)
+	return time.FixedZone(name, int(offset))
+}
+
+// Format Unix seconds as ISO 8601 in a zone
+func formatUnix(seconds int64, zone *time.Location) string {
+	return time.Unix(seconds, 0).In(zone).Format(time.RFC3339)
+}
+
+// Whether the sun is up at a time, going by the time of day in
+// the city, so sunrise and sunset for one day work for the rest
+// of the forecast too. Nil if we don't know when the sun rises.
+func isDay(at int64, sunrise int64, sunset int64, offset int32) *bool {
+	if sunrise == 0 || sunset == 0 {
+		return nil
+	}
+	timeOfDay := func(seconds int64) int64 {
+		return ((seconds+int64(offset))%SECONDS_PER_DAY + SECONDS_PER_DAY) % SECONDS_PER_DAY
+	}
+
+	now, rise, set := timeOfDay(at), timeOfDay(sunrise), timeOfDay(sunset)
+	day := now >= rise && now < set
+	// Sunset can be past midnight UTC but before sunrise in
+	// time of day, e.g. with a large offset from UTC
+	if set < rise {
+		day = now >= rise || now < set
+	}
+	return &day
+}
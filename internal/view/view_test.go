@@ -0,0 +1,125 @@
+package view_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"github.com/bengimbel/go_redis_api/internal/view"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSummary(t *testing.T) {
+	summary := view.NewSummary(model.Forecast{
+		Location: model.Location{
+			Name:     "boise",
+			Country:  "US",
+			Timezone: -7 * 60 * 60,
+			// 07:45 and 17:15 in Boise
+			Sunrise: 1704120300,
+			Sunset:  1704154500,
+		},
+		Entries: []model.ForecastEntry{
+			{
+				Time:                1704067200,
+				Temp:                -2.5,
+				Condition:           "Snow",
+				Description:         "light snow",
+				Icon:                "13n",
+				WindSpeed:           3.1,
+				PrecipitationChance: 0.4,
+			},
+		},
+	}, nil)
+
+	assert.EqualValues(t, "boise", summary.City)
+	assert.EqualValues(t, "UTC-07:00", summary.TimeZone)
+	assert.Len(t, summary.Forecasts, 1)
+	forecast := summary.Forecasts[0]
+	assert.EqualValues(t, "2024-01-01T00:00:00Z", forecast.Time.Format("2006-01-02T15:04:05Z07:00"))
+	assert.EqualValues(t, "2023-12-31T17:00:00-07:00", forecast.LocalTime)
+	assert.EqualValues(t, "light snow", forecast.Condition)
+	// Just before sunset
+	assert.True(t, *forecast.IsDay)
+	assert.EqualValues(t, "https://openweathermap.org/img/wn/13n@2x.png", forecast.IconURL)
+}
+
+func TestSelectFields(t *testing.T) {
+	weather := model.WeatherResponse{
+		City: model.City{Name: "boise", Country: "US"},
+		List: []model.List{
+			{Dt: 1, Main: model.Main{Temp: 270, Humidity: 80}},
+			{Dt: 2, Main: model.Main{Temp: 271, Humidity: 81}},
+		},
+	}
+
+	fields, err := view.ParseFields("city.name, list.dt,list.main.temp", model.WeatherResponse{})
+	assert.Nil(t, err)
+	selected, err := view.SelectFields(weather, fields)
+	assert.Nil(t, err)
+	assert.EqualValues(t, map[string]interface{}{
+		"city": map[string]interface{}{"name": "boise"},
+		"list": []interface{}{
+			map[string]interface{}{"dt": float64(1), "main": map[string]interface{}{"temp": float64(270)}},
+			map[string]interface{}{"dt": float64(2), "main": map[string]interface{}{"temp": float64(271)}},
+		},
+	}, selected)
+
+	// A whole field wins over its parts
+	fields, _ = view.ParseFields("city.name,city", model.WeatherResponse{})
+	selected, _ = view.SelectFields(weather, fields)
+	assert.Len(t, selected["city"], 8)
+}
+
+func TestParseFieldsRejectsUnknownFields(t *testing.T) {
+	for _, value := range []string{"city.mayor", "list.main.temp.value", "List", ","} {
+		_, err := view.ParseFields(value, model.WeatherResponse{})
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestNewWeatherTimes(t *testing.T) {
+	weather := model.WeatherResponse{
+		City: model.City{
+			Name:     "boise",
+			Timezone: -7 * 60 * 60,
+			// 07:45 and 17:15 in Boise on 2024-01-01
+			Sunrise: 1704120300,
+			Sunset:  1704154500,
+		},
+		List: []model.List{
+			// 12:00 on 2024-01-02 in Boise
+			{Dt: 1704222000},
+			// 21:00 on 2024-01-02 in Boise
+			{Dt: 1704254400},
+		},
+	}
+	now := time.Unix(1704150000, 0)
+
+	result := view.NewWeather(weather, nil, now)
+	assert.EqualValues(t, "UTC-07:00", result.City.TimeZone)
+	assert.EqualValues(t, "2024-01-01T07:45:00-07:00", result.City.SunriseLocal)
+	assert.EqualValues(t, "2024-01-01T14:45:00Z", result.City.SunriseUTC)
+	assert.EqualValues(t, 34200, result.City.DayLength)
+	assert.True(t, *result.City.IsDay)
+	assert.EqualValues(t, 4500, *result.City.UntilSunset)
+	assert.EqualValues(t, "2024-01-02T12:00:00-07:00", result.List[0].DtLocal)
+	assert.EqualValues(t, "2024-01-02T19:00:00Z", result.List[0].DtUTC)
+	assert.True(t, *result.List[0].IsDay)
+	assert.False(t, *result.List[1].IsDay)
+
+	// In a zone the caller picked, after sunset
+	zone, _ := time.LoadLocation("America/New_York")
+	result = view.NewWeather(weather, zone, now.Add(2*time.Hour))
+	assert.EqualValues(t, "America/New_York", result.City.TimeZone)
+	assert.EqualValues(t, "2024-01-01T09:45:00-05:00", result.City.SunriseLocal)
+	assert.False(t, *result.City.IsDay)
+	// Counting down to the next day's sunset
+	assert.EqualValues(t, 83700, *result.City.UntilSunset)
+
+	// Without a time the city has nothing relative to now
+	result = view.NewWeather(weather, nil, time.Time{})
+	assert.EqualValues(t, 34200, result.City.DayLength)
+	assert.Nil(t, result.City.IsDay)
+	assert.Nil(t, result.City.UntilSunset)
+}
@@ -0,0 +1,110 @@
+package view
+
+import (
+	"time"
+
+	"github.com/bengimbel/go_redis_api/internal/model"
+	"google.golang.org/protobuf/proto"
+)
+
+// The full weather, shaped like the provider's response,
+// with times worked out so clients don't do date math.
+// Local times are in the zone the caller asked for, or
+// else the city's own offset from UTC.
+type Weather struct {
+	City City       `json:"city"`
+	List []Forecast `json:"list"`
+
+	weather model.WeatherResponse
+}
+
+type City struct {
+	model.City
+	// e.g. UTC-07:00, or the zone the caller asked for
+	TimeZone     string `json:"time_zone"`
+	SunriseLocal string `json:"sunrise_local,omitempty"`
+	SunriseUTC   string `json:"sunrise_utc,omitempty"`
+	SunsetLocal  string `json:"sunset_local,omitempty"`
+	SunsetUTC    string `json:"sunset_utc,omitempty"`
+	// From sunrise to sunset, in seconds
+	DayLength int64 `json:"day_length,omitempty"`
+	// Whether the sun is up now, only when asked for
+	IsDay *bool `json:"is_day,omitempty"`
+	// Seconds from now until the next sunset, only when asked for
+	UntilSunset *int64 `json:"until_sunset,omitempty"`
+}
+
+type Forecast struct {
+	model.List
+	DtLocal string `json:"dt_local"`
+	DtUTC   string `json:"dt_utc"`
+	IsDay   *bool  `json:"is_day,omitempty"`
+}
+
+// Add times to the weather, rendered in zone, or in the city's
+// offset from UTC if zone is nil. The city's is_day and
+// until_sunset are as of now, and left out if now is zero.
+func NewWeather(weather model.WeatherResponse, zone *time.Location, now time.Time) Weather {
+	city := weather.City
+	local := timeZone(city.Timezone, zone)
+	result := Weather{
+		City: City{
+			City:     city,
+			TimeZone: local.String(),
+		},
+		weather: weather,
+	}
+	// Keep a missing list missing, as it was upstream
+	if weather.List != nil {
+		result.List = make([]Forecast, 0, len(weather.List))
+	}
+
+	if city.Sunrise != 0 {
+		result.City.SunriseLocal = formatUnix(city.Sunrise, local)
+		result.City.SunriseUTC = formatUnix(city.Sunrise, time.UTC)
+	}
+	if city.Sunset != 0 {
+		result.City.SunsetLocal = formatUnix(city.Sunset, local)
+		result.City.SunsetUTC = formatUnix(city.Sunset, time.UTC)
+	}
+	if city.Sunrise != 0 && city.Sunset != 0 {
+		result.City.DayLength = city.Sunset - city.Sunrise
+	}
+	if city.Sunrise != 0 && city.Sunset != 0 && !now.IsZero() {
+		result.City.IsDay = isDay(now.Unix(), city.Sunrise, city.Sunset, city.Timezone)
+
+		// Once the sun has set, count down to the next sunset,
+		// taking it to be a day after the one we know
+		untilSunset := (city.Sunset - now.Unix()) % SECONDS_PER_DAY
+		if untilSunset < 0 {
+			untilSunset += SECONDS_PER_DAY
+		}
+		result.City.UntilSunset = &untilSunset
+	}
+
+	for _, item := range weather.List {
+		result.List = append(result.List, Forecast{
+			List:    item,
+			DtLocal: formatUnix(item.Dt, local),
+			DtUTC:   formatUnix(item.Dt, time.UTC),
+			IsDay:   isDay(item.Dt, city.Sunrise, city.Sunset, city.Timezone),
+		})
+	}
+
+	return result
+}
+
+// The weather without the added times
+func (w Weather) Model() model.WeatherResponse {
+	return w.weather
+}
+
+// The forecast list as csv, like the provider's shape
+func (w Weather) MarshalCSV() ([][]string, error) {
+	return w.weather.MarshalCSV()
+}
+
+// The weather as a protobuf WeatherResponse
+func (w Weather) MarshalProto() ([]byte, error) {
+	return proto.Marshal(w.weather.Proto())
+}
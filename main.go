@@ -2,23 +2,42 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
+	// Embed the time zone database, so ?tz= works
+	// on images that don't have one
+	_ "time/tzdata"
 
 	"github.com/bengimbel/go_redis_api/internal/application"
+	"github.com/bengimbel/go_redis_api/internal/config"
+	"github.com/bengimbel/go_redis_api/internal/logger"
 )
 
 // Main entry point for our application.
 // Creating a new app intance, and starting the app
 func main() {
-	app := application.NewApp()
+	cfg := config.Load()
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	// The app is handed its logger. It is also made slog's
+	// default, only for third party libraries and anything
+	// still using the log package.
+	log := logger.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	slog.SetDefault(log)
+
+	app, err := application.NewApp(cfg, log)
+	if err != nil {
+		log.Error("Failed to create app", "error", err)
+		os.Exit(1)
+	}
+
+	// Docker and Kubernetes stop containers with SIGTERM
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	err := app.Start(ctx)
+	err = app.Start(ctx)
 	if err != nil {
-		log.Println("Failed to start app", err)
+		log.Error("Failed to start app", "error", err)
 	}
 }
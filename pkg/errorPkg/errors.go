@@ -1,13 +1,24 @@
 package errorPkg
 
 import (
-	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/pkg/render"
+	"github.com/bengimbel/go_redis_api/pkg/weatherProto"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	REQUEST_ID_HEADER string = "X-Request-ID"
 )
 
 type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // Function to create new error instance
@@ -18,26 +29,105 @@ func NewError(code int, message string) *Error {
 	}
 }
 
+// The error as a csv header and row
+func (e *Error) MarshalCSV() ([][]string, error) {
+	return [][]string{
+		{"code", "message", "request_id"},
+		{strconv.Itoa(e.Code), e.Message, e.RequestID},
+	}, nil
+}
+
+// The error as a protobuf Error message
+func (e *Error) MarshalProto() ([]byte, error) {
+	return proto.Marshal(&weatherProto.Error{
+		Code:      int32(e.Code),
+		Message:   e.Message,
+		RequestId: e.RequestID,
+	})
+}
+
 // Render http internal server error response
 func RenderInternalServerError(w http.ResponseWriter, err error) {
-	code := http.StatusInternalServerError
-	errResponse := NewError(code, err.Error())
-	result, _ := json.Marshal(errResponse)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write(result)
-	return
+	writeError(w, http.StatusInternalServerError, err)
 }
 
 // Render a basic http error response
 func RenderBadRequestError(w http.ResponseWriter, err error) {
-	code := http.StatusBadRequest
+	writeError(w, http.StatusBadRequest, err)
+}
+
+// Render a 401 response asking the
+// client to authenticate
+func RenderUnauthorizedError(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeError(w, http.StatusUnauthorized, err)
+}
+
+// Render a 403 response when the client
+// is authenticated but not allowed
+func RenderForbiddenError(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusForbidden, err)
+}
+
+// Render a 404 response
+func RenderNotFoundError(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusNotFound, err)
+}
+
+// Render a 406 response when we can't respond
+// in a format the client accepts
+func RenderNotAcceptableError(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusNotAcceptable, err)
+}
+
+// Render a 413 response when the request body is too large
+func RenderRequestTooLargeError(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusRequestEntityTooLarge, err)
+}
+
+// Render a 504 response when we ran out of time
+func RenderGatewayTimeoutError(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusGatewayTimeout, err)
+}
+
+// Render a 429 response telling the client
+// when they can try again
+func RenderTooManyRequestsError(w http.ResponseWriter, err error, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	writeError(w, http.StatusTooManyRequests, err)
+}
+
+// Render a 503 response telling the client
+// when they can try again
+func RenderServiceUnavailableError(w http.ResponseWriter, err error, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	writeError(w, http.StatusServiceUnavailable, err)
+}
+
+// Write the error in the format negotiated for the response,
+// json if there wasn't one. The request id is picked up from
+// the response's X-Request-ID header, which our request id
+// middleware sets, so clients can quote it when reporting issues.
+func writeError(w http.ResponseWriter, code int, err error) {
 	errResponse := NewError(code, err.Error())
-	result, _ := json.Marshal(errResponse)
+	errResponse.RequestID = w.Header().Get(REQUEST_ID_HEADER)
+	renderer := render.FromResponseWriter(w)
+	result, err := renderer.Marshal(errResponse)
+	if err != nil {
+		renderer = render.JSON
+		result, _ = renderer.Marshal(errResponse)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", renderer.ContentType())
 	w.WriteHeader(code)
 	w.Write(result)
-	return
+}
+
+// Retry-After is in whole seconds, rounded up
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
 }
@@ -1,17 +1,39 @@
 package httpClient
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	HTTPS string = "https"
-	HOST  string = "api.openweathermap.org"
+	HTTPS                    string        = "https"
+	HOST                     string        = "api.openweathermap.org"
+	DEFAULT_UPSTREAM_RETRY   time.Duration = time.Minute
+	RATE_LIMIT_CHECK_TIMEOUT time.Duration = time.Second
+	TRACER_NAME              string        = "github.com/bengimbel/go_redis_api/pkg/httpClient"
+	API_KEY_PARAM            string        = "appid"
+	REDACTED                 string        = "REDACTED"
+	// Most times a call is tried with another api key
+	// after upstream rejects or rate limits one
+	MAX_KEY_ATTEMPTS int = 3
 )
 
+// Returned when upstream doesn't accept our api key,
+// e.g. because it was revoked or hasn't been activated
+var ErrKeyRejected = errors.New("upstream rejected the api key")
+
 type QueryParams struct {
 	Key   string
 	Value string
@@ -24,22 +46,48 @@ type HttpConfig struct {
 
 type HttpClient struct {
 	Client *http.Client
-	ApiKey string
 	URL    url.URL
+	// Optional, the api key for each call is taken from
+	// here and added to the query as API_KEY_PARAM
+	Keys    KeySource
+	Limiter RateLimiter
+	// Optional hook called after every upstream call,
+	// e.g. to record metrics. Calls blocked by the
+	// rate limiter never reach upstream and aren't reported.
+	OnRequest func(host string, path string, duration time.Duration, err error)
+	// Send the trace context (traceparent, tracestate) upstream.
+	// Off by default, since the weather apis are third parties
+	// and shouldn't see our trace ids. Only turn it on for hosts we own.
+	PropagateTrace bool
 }
 
 type HttpImplementor interface {
-	MakeWeatherRequest(config *HttpConfig, responseStruct interface{}) error
+	MakeWeatherRequest(ctx context.Context, config *HttpConfig, responseStruct interface{}) error
+}
+
+// Where the client gets api keys. Report is told about
+// every call a key was rejected or rate limited on, so
+// the next Key can be a different one.
+type KeySource interface {
+	Key() (string, error)
+	Report(ctx context.Context, key string, err error)
+}
+
+// Create an instance of our client for open weather map.
+// Limiter can be nil to make calls without rate limiting.
+func NewHttpClient(limiter RateLimiter) *HttpClient {
+	return NewHttpClientWithHost(HOST, limiter)
 }
 
-// Create an instance of our client
-func NewHttpClient() *HttpClient {
+// Create an instance of our client for another weather api host
+func NewHttpClientWithHost(host string, limiter RateLimiter) *HttpClient {
 	return &HttpClient{
 		Client: &http.Client{},
 		URL: url.URL{
 			Scheme: HTTPS,
-			Host:   HOST,
+			Host:   host,
 		},
+		Limiter: limiter,
 	}
 }
 
@@ -49,36 +97,153 @@ func NewHttpClient() *HttpClient {
 // Since we are passing in pointer to the response struct, that address in memory is
 // filled in with results, and we don't need to return it. We only return an error
 // if there is one.
-func (hwc *HttpClient) MakeWeatherRequest(config *HttpConfig, responseStruct interface{}) error {
+//
+// Every call gets a client span. Only the host and path are recorded
+// on it, since the query can carry the api key.
+//
+// The api key is added here rather than in the config, so it never
+// leaves the client. If upstream rejects or rate limits the key, the
+// call is tried again with the next one the key source gives us.
+func (hwc *HttpClient) MakeWeatherRequest(ctx context.Context, config *HttpConfig, responseStruct interface{}) error {
+	ctx, span := otel.Tracer(TRACER_NAME).Start(ctx, "HttpClient.MakeWeatherRequest",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("server.address", hwc.URL.Host),
+			attribute.String("url.path", config.Path),
+		),
+	)
+	defer span.End()
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var key string
+		if hwc.Keys != nil {
+			if key, err = hwc.Keys.Key(); err != nil {
+				break
+			}
+		}
+
+		// Don't burn an upstream call if our quota is used up
+		if err = hwc.allow(ctx); err != nil {
+			break
+		}
+
+		start := time.Now()
+		err = hwc.doRequest(ctx, config, key, responseStruct)
+		if hwc.OnRequest != nil {
+			hwc.OnRequest(hwc.URL.Host, config.Path, time.Since(start), err)
+		}
+		if hwc.Keys == nil || !isKeyError(err) {
+			break
+		}
+		hwc.Keys.Report(ctx, key, err)
+		if attempt >= MAX_KEY_ATTEMPTS {
+			break
+		}
+		span.AddEvent("Retrying with another api key", trace.WithAttributes(attribute.String("error", err.Error())))
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+func (hwc *HttpClient) allow(ctx context.Context) error {
+	if hwc.Limiter == nil {
+		return nil
+	}
+	limitCtx, cancel := context.WithTimeout(ctx, RATE_LIMIT_CHECK_TIMEOUT)
+	defer cancel()
+	return hwc.Limiter.Allow(limitCtx)
+}
+
+// Errors that are down to the key we used,
+// so another key might work
+func isKeyError(err error) bool {
+	var quotaErr *QuotaExceededError
+	return errors.Is(err, ErrKeyRejected) || errors.As(err, &quotaErr)
+}
+
+// Check the api host is reachable with a HEAD request to its root.
+// Any response counts, since we only care that it answers, and
+// it skips the rate limiter since it isn't a weather call.
+func (hwc *HttpClient) Ping(ctx context.Context) error {
+	endpoint := url.URL{
+		Scheme: hwc.URL.Scheme,
+		Host:   hwc.URL.Host,
+		Path:   "/",
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := hwc.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (hwc *HttpClient) doRequest(ctx context.Context, config *HttpConfig, key string, responseStruct interface{}) error {
 	query := url.Values{}
 
 	// Loop over config query values and set them to url.Values{}
 	for _, v := range config.Query {
 		query.Set(v.Key, v.Value)
 	}
+	if key != "" {
+		query.Set(API_KEY_PARAM, key)
+	}
 
-	// encode path and query params onto the url
-	hwc.URL.Path = config.Path
-	hwc.URL.RawQuery = query.Encode()
-
-	// Resolve the URI
-	endpoint := hwc.URL.ResolveReference(&hwc.URL)
+	// encode path and query params onto a copy of the url,
+	// since the client is shared between requests
+	endpoint := hwc.URL
+	endpoint.Path = config.Path
+	endpoint.RawQuery = query.Encode()
 
 	// Make Request Object
-	req, err := http.NewRequest(http.MethodGet, endpoint.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
 	if err != nil {
 		return err
 	}
 
 	req.Header.Add("Accept", "application/json")
+	if hwc.PropagateTrace {
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
 
 	// Execute the request
+	// The url in a transport error carries the api key,
+	// so redact it before the error is logged or rendered
 	res, err := hwc.Client.Do(req)
 	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(endpoint)
+		}
 		return err
 	}
 
 	defer res.Body.Close()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+
+	// Upstream doesn't accept our key, or is rate limiting
+	// us, so pass that on instead of a decoding error
+	if res.StatusCode == http.StatusUnauthorized {
+		return ErrKeyRejected
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		return &QuotaExceededError{
+			Reason:     QUOTA_REASON_RATE,
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+		}
+	}
 
 	// Decode the json results to the response struct pointer we passed in.
 	if err := json.NewDecoder(res.Body).Decode(&responseStruct); err != nil {
@@ -87,3 +252,23 @@ func (hwc *HttpClient) MakeWeatherRequest(config *HttpConfig, responseStruct int
 
 	return nil
 }
+
+// Return the url as a string with the api key replaced
+func redactURL(endpoint url.URL) string {
+	query := endpoint.Query()
+	if query.Has(API_KEY_PARAM) {
+		query.Set(API_KEY_PARAM, REDACTED)
+		endpoint.RawQuery = query.Encode()
+	}
+	return endpoint.String()
+}
+
+// Parse a Retry-After header given in seconds,
+// falling back to a default if it is missing
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds <= 0 {
+		return DEFAULT_UPSTREAM_RETRY
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,120 @@
+package httpClient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/pkg/httpClient"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Gives out keys in order, remembering which were reported
+type testKeys struct {
+	keys     []string
+	reported []string
+}
+
+func (tk *testKeys) Key() (string, error) {
+	return tk.keys[len(tk.reported)%len(tk.keys)], nil
+}
+
+func (tk *testKeys) Report(ctx context.Context, key string, err error) {
+	tk.reported = append(tk.reported, key)
+}
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *httpClient.HttpClient {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	endpoint, _ := url.Parse(server.URL)
+	client := httpClient.NewHttpClientWithHost(endpoint.Host, nil)
+	client.URL.Scheme = endpoint.Scheme
+	return client
+}
+
+func TestMakeWeatherRequestAddsApiKey(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.URL.Query().Get(httpClient.API_KEY_PARAM))
+		assert.Equal(t, "chicago", r.URL.Query().Get("q"))
+		w.Write([]byte(`{"name":"chicago"}`))
+	})
+	client.Keys = &testKeys{keys: []string{"secret"}}
+
+	response := struct {
+		Name string `json:"name"`
+	}{}
+	err := client.MakeWeatherRequest(context.Background(), &httpClient.HttpConfig{
+		Path:  "/geo",
+		Query: []httpClient.QueryParams{{Key: "q", Value: "chicago"}},
+	}, &response)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "chicago", response.Name)
+}
+
+func TestMakeWeatherRequestFailsOverToNextKey(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get(httpClient.API_KEY_PARAM) {
+		case "revoked":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"cod":401,"message":"Invalid API key"}`))
+		case "limited":
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+	keys := &testKeys{keys: []string{"revoked", "limited", "good"}}
+	client.Keys = keys
+
+	err := client.MakeWeatherRequest(context.Background(), &httpClient.HttpConfig{Path: "/geo"}, &struct{}{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"revoked", "limited"}, keys.reported)
+}
+
+func TestMakeWeatherRequestGivesUpAfterMaxAttempts(t *testing.T) {
+	calls := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	client.Keys = &testKeys{keys: []string{"revoked"}}
+
+	err := client.MakeWeatherRequest(context.Background(), &httpClient.HttpConfig{Path: "/geo"}, &struct{}{})
+
+	assert.ErrorIs(t, err, httpClient.ErrKeyRejected)
+	assert.Equal(t, httpClient.MAX_KEY_ATTEMPTS, calls)
+}
+
+func TestMakeWeatherRequestOnlyPropagatesTraceWhenEnabled(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator()) })
+
+	var traceparent string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{}`))
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	response := struct{}{}
+
+	err := client.MakeWeatherRequest(ctx, &httpClient.HttpConfig{Path: "/weather"}, &response)
+	assert.Nil(t, err)
+	assert.Empty(t, traceparent)
+
+	client.PropagateTrace = true
+	err = client.MakeWeatherRequest(ctx, &httpClient.HttpConfig{Path: "/weather"}, &response)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, traceparent)
+}
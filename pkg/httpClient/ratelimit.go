@@ -0,0 +1,170 @@
+package httpClient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RATE_LIMIT_KEY     string = "upstream:ratelimit"
+	QUOTA_KEY_PREFIX   string = "upstream:quota:"
+	QUOTA_REASON_RATE  string = "rate"
+	QUOTA_REASON_DAILY string = "daily"
+)
+
+type RateLimiter interface {
+	Allow(context.Context) error
+	Usage(context.Context) (QuotaUsage, error)
+}
+
+// Returned when we are not allowed to call the upstream api.
+// RetryAfter is how long until a call would be allowed again.
+type QuotaExceededError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("upstream %s quota exceeded, retry after %s", e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+// How much of today's upstream quota has been used
+type QuotaUsage struct {
+	UsedToday  int64
+	DailyQuota int64
+}
+
+// Token bucket rate limiter stored in redis so every
+// replica shares the same budget of upstream calls.
+// Calls are also counted per UTC day, and if DailyQuota
+// is set no more calls are allowed once it is used up.
+type RedisRateLimiter struct {
+	Client        *redis.Client
+	RatePerMinute int
+	Burst         int
+	DailyQuota    int64
+	Logger        *slog.Logger
+}
+
+// Refill the bucket based on the time since the last call,
+// then take a token if there is one. A rate of 0 skips the
+// bucket, so the daily quota works on its own. The daily counter
+// is checked and incremented in the same script so
+// concurrent replicas can't both take the last call.
+// Returns {allowed, retry after ms, reason}.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local daily_quota = tonumber(ARGV[3])
+local day_ttl = tonumber(ARGV[4])
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local used = tonumber(redis.call("GET", KEYS[2]) or "0")
+if daily_quota > 0 and used >= daily_quota then
+	local ttl = redis.call("PTTL", KEYS[2])
+	if ttl < 0 then
+		ttl = day_ttl
+	end
+	return {0, ttl, "daily"}
+end
+
+if rate > 0 then
+	local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+	local tokens = tonumber(bucket[1]) or capacity
+	local last = tonumber(bucket[2]) or now
+	tokens = math.min(capacity, tokens + math.max(0, now - last) * rate)
+
+	if tokens < 1 then
+		return {0, math.ceil((1 - tokens) / rate), "rate"}
+	end
+
+	redis.call("HSET", KEYS[1], "tokens", tostring(tokens - 1), "ts", now)
+	redis.call("PEXPIRE", KEYS[1], math.ceil(capacity / rate) + 1000)
+end
+
+if redis.call("INCR", KEYS[2]) == 1 then
+	redis.call("PEXPIRE", KEYS[2], day_ttl)
+end
+
+return {1, 0, ""}
+`)
+
+// Create a new rate limiter. Burst defaults
+// to the per minute rate if it is not set.
+func NewRedisRateLimiter(rds *redis.Client, ratePerMinute int, burst int, dailyQuota int64, log *slog.Logger) *RedisRateLimiter {
+	if burst <= 0 {
+		burst = ratePerMinute
+	}
+	return &RedisRateLimiter{
+		Client:        rds,
+		RatePerMinute: ratePerMinute,
+		Burst:         burst,
+		DailyQuota:    dailyQuota,
+		Logger:        log,
+	}
+}
+
+// Take a token for one upstream call. Returns a *QuotaExceededError
+// if the budget is used up. The per minute rate and the daily quota
+// are each off when 0, and are checked independently. If redis can't
+// be reached we let the call through rather than taking the whole api down.
+func (rl *RedisRateLimiter) Allow(ctx context.Context) error {
+	if rl.RatePerMinute <= 0 && rl.DailyQuota <= 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	ratePerMs := 0.0
+	if rl.RatePerMinute > 0 {
+		ratePerMs = float64(rl.RatePerMinute) / float64(time.Minute.Milliseconds())
+	}
+
+	result, err := tokenBucketScript.Run(ctx, rl.Client,
+		[]string{RATE_LIMIT_KEY, quotaKey(now)},
+		ratePerMs, rl.Burst, rl.DailyQuota, untilEndOfDay(now).Milliseconds(),
+	).Slice()
+	if err != nil {
+		rl.Logger.WarnContext(ctx, "Upstream rate limiter unavailable, allowing call", "error", err)
+		return nil
+	}
+
+	if allowed, _ := result[0].(int64); allowed == 1 {
+		return nil
+	}
+
+	retryAfter, _ := result[1].(int64)
+	reason, _ := result[2].(string)
+	return &QuotaExceededError{
+		Reason:     reason,
+		RetryAfter: time.Duration(retryAfter) * time.Millisecond,
+	}
+}
+
+// Get how many upstream calls have been made today
+func (rl *RedisRateLimiter) Usage(ctx context.Context) (QuotaUsage, error) {
+	used, err := rl.Client.Get(ctx, quotaKey(time.Now().UTC())).Int64()
+	if err != nil && err != redis.Nil {
+		return QuotaUsage{}, fmt.Errorf("failed to get upstream quota usage: %w", err)
+	}
+
+	return QuotaUsage{
+		UsedToday:  used,
+		DailyQuota: rl.DailyQuota,
+	}, nil
+}
+
+// Daily quota counters are keyed by UTC date
+func quotaKey(now time.Time) string {
+	return QUOTA_KEY_PREFIX + now.Format("20060102")
+}
+
+func untilEndOfDay(now time.Time) time.Duration {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC).Sub(now)
+}
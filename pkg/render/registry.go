@@ -0,0 +1,172 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	FORMAT_PARAM string = "format"
+
+	FORMAT_JSON     string = "json"
+	FORMAT_PRETTY   string = "pretty"
+	FORMAT_MSGPACK  string = "msgpack"
+	FORMAT_CSV      string = "csv"
+	FORMAT_PROTOBUF string = "protobuf"
+)
+
+// The client asked for a format we don't have
+var ErrNotAcceptable = errors.New("not acceptable")
+
+type registration struct {
+	format     string
+	mediaTypes []string
+	renderer   Renderer
+}
+
+// Renderers by format name and by the media
+// types clients can ask for in Accept
+type Registry struct {
+	registrations []registration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// The registry with every format we serve. Pretty
+// json can only be asked for with ?format=pretty.
+func NewDefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(FORMAT_JSON, JSON, "application/json")
+	registry.Register(FORMAT_PRETTY, PrettyJSON)
+	registry.Register(FORMAT_MSGPACK, MsgPack, "application/msgpack", "application/x-msgpack", "application/vnd.msgpack")
+	registry.Register(FORMAT_CSV, CSV, "text/csv")
+	registry.Register(FORMAT_PROTOBUF, Protobuf, "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf")
+	return registry
+}
+
+// Add a renderer for a format and the media types that select it.
+// The first renderer registered is the default, used for */*
+// or when the client doesn't say.
+func (rg *Registry) Register(format string, renderer Renderer, mediaTypes ...string) {
+	for i := range mediaTypes {
+		mediaTypes[i] = strings.ToLower(mediaTypes[i])
+	}
+	rg.registrations = append(rg.registrations, registration{
+		format:     strings.ToLower(format),
+		mediaTypes: mediaTypes,
+		renderer:   renderer,
+	})
+}
+
+// Get the renderer for a format name
+func (rg *Registry) Lookup(format string) (Renderer, bool) {
+	format = strings.ToLower(format)
+	for _, reg := range rg.registrations {
+		if reg.format == format {
+			return reg.renderer, true
+		}
+	}
+	return nil, false
+}
+
+// The format names, in the order they were registered
+func (rg *Registry) Formats() []string {
+	formats := make([]string, len(rg.registrations))
+	for i, reg := range rg.registrations {
+		formats[i] = reg.format
+	}
+	return formats
+}
+
+// Pick the renderer for a request. ?format= wins and must name
+// a registered format. Otherwise the Accept header's media ranges
+// are tried by quality, and if none of them match (or there is no
+// header) we fall back to the default rather than refusing, which
+// RFC 9110 allows and keeps browsers and tools working.
+func (rg *Registry) Negotiate(r *http.Request) (Renderer, error) {
+	if len(rg.registrations) == 0 {
+		return nil, fmt.Errorf("%w: no renderers registered", ErrNotAcceptable)
+	}
+
+	if format := r.URL.Query().Get(FORMAT_PARAM); format != "" {
+		renderer, ok := rg.Lookup(format)
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported format %q, use one of %s",
+				ErrNotAcceptable, format, strings.Join(rg.Formats(), ", "))
+		}
+		return renderer, nil
+	}
+
+	for _, mediaRange := range parseAccept(r.Header.Get("Accept")) {
+		if renderer, ok := rg.match(mediaRange); ok {
+			return renderer, nil
+		}
+	}
+
+	return rg.registrations[0].renderer, nil
+}
+
+// Renderer for a media range like application/json,
+// text/* or */*, with wildcards taking the first match
+func (rg *Registry) match(mediaRange string) (Renderer, bool) {
+	if mediaRange == "*/*" {
+		return rg.registrations[0].renderer, true
+	}
+	prefix, wildcard := strings.CutSuffix(mediaRange, "/*")
+	for _, reg := range rg.registrations {
+		for _, mediaType := range reg.mediaTypes {
+			if mediaType == mediaRange || (wildcard && strings.HasPrefix(mediaType, prefix+"/")) {
+				return reg.renderer, true
+			}
+		}
+	}
+	return nil, false
+}
+
+type acceptRange struct {
+	mediaRange string
+	quality    float64
+}
+
+// The media ranges in an Accept header, best first. Ranges
+// with the same quality keep the order the client sent them
+// in, and ones with a quality of 0 are left out.
+func parseAccept(header string) []string {
+	ranges := []acceptRange{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaRange == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		ranges = append(ranges, acceptRange{mediaRange: mediaRange, quality: quality})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+	mediaRanges := make([]string, len(ranges))
+	for i, r := range ranges {
+		mediaRanges[i] = r.mediaRange
+	}
+	return mediaRanges
+}
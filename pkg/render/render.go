@@ -0,0 +1,74 @@
+// Renders response bodies in whichever format the client asked
+// for. A Registry maps formats and media types to Renderers and
+// picks one per request from the ?format= query parameter or the
+// Accept header. The chosen renderer rides along on the response
+// writer, so anything holding only the writer, like errorPkg,
+// renders in the same format as the handler would.
+package render
+
+import (
+	"errors"
+	"net/http"
+)
+
+// The value has no form in the negotiated format,
+// like an alert rule as csv
+var ErrUnsupportedValue = errors.New("response is not available in this format")
+
+type Renderer interface {
+	// The Content-Type of the rendered body
+	ContentType() string
+	Marshal(interface{}) ([]byte, error)
+}
+
+// Implemented by values that can be rendered as csv.
+// The first record is the header.
+type CSVMarshaler interface {
+	MarshalCSV() ([][]string, error)
+}
+
+// Implemented by values with a protobuf message
+type ProtoMarshaler interface {
+	MarshalProto() ([]byte, error)
+}
+
+// Response writer carrying the negotiated renderer
+type responseWriter struct {
+	http.ResponseWriter
+	renderer Renderer
+}
+
+// Attach a renderer to the response writer
+func WithRenderer(w http.ResponseWriter, renderer Renderer) http.ResponseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+		renderer:       renderer,
+	}
+}
+
+// The renderer negotiated for the response. Middleware
+// wrapping the writer afterwards is seen through as long
+// as it has an Unwrap method, as http.ResponseController
+// expects. Responses that weren't negotiated are json.
+func FromResponseWriter(w http.ResponseWriter) Renderer {
+	for {
+		if rw, ok := w.(*responseWriter); ok {
+			return rw.renderer
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return JSON
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// Writers wrapping ours look for http.Flusher
+// directly, so it is passed through for streaming
+func (rw *responseWriter) Flush() {
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
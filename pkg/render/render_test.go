@@ -0,0 +1,80 @@
+package render_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/pkg/render"
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiate(t *testing.T) {
+	registry := render.NewDefaultRegistry()
+	tests := []struct {
+		target string
+		accept string
+		want   render.Renderer
+	}{
+		{"/", "", render.JSON},
+		{"/", "*/*", render.JSON},
+		{"/", "application/msgpack", render.MsgPack},
+		{"/", "application/x-protobuf", render.Protobuf},
+		{"/", "text/html, text/*;q=0.5", render.CSV},
+		{"/", "application/json;q=0.5, text/csv", render.CSV},
+		{"/", "text/csv;q=0, application/msgpack;q=0.1", render.MsgPack},
+		// Nothing we have, so the default
+		{"/", "application/xml", render.JSON},
+		// The query parameter wins
+		{"/?format=pretty", "text/csv", render.PrettyJSON},
+		{"/?format=MSGPACK", "", render.MsgPack},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.target, nil)
+		req.Header.Set("Accept", test.accept)
+		renderer, err := registry.Negotiate(req)
+		assert.Nil(t, err, test.target, test.accept)
+		assert.Same(t, test.want, renderer, "%s %s", test.target, test.accept)
+	}
+
+	_, err := registry.Negotiate(httptest.NewRequest(http.MethodGet, "/?format=xml", nil))
+	assert.ErrorIs(t, err, render.ErrNotAcceptable)
+}
+
+func TestFromResponseWriterUnwraps(t *testing.T) {
+	rr := httptest.NewRecorder()
+	assert.Same(t, render.JSON, render.FromResponseWriter(rr))
+
+	w := chiMiddleware.NewWrapResponseWriter(render.WithRenderer(rr, render.CSV), 1)
+	assert.Same(t, render.CSV, render.FromResponseWriter(w))
+
+	// Still flushes for streaming
+	_, ok := w.(http.Flusher)
+	assert.True(t, ok)
+}
+
+type point struct {
+	Lat  float64 `json:"lat"`
+	Name string  `json:"name,omitempty"`
+	Skip string  `json:"-"`
+}
+
+func TestMarshalMsgPackUsesJSONNames(t *testing.T) {
+	body, err := render.MsgPack.Marshal(point{Lat: 41.5, Skip: "secret"})
+	assert.Nil(t, err)
+
+	decoded := map[string]interface{}{}
+	assert.Nil(t, msgpack.Unmarshal(body, &decoded))
+	assert.EqualValues(t, map[string]interface{}{"lat": 41.5}, decoded)
+}
+
+func TestMarshalUnsupportedValue(t *testing.T) {
+	_, err := render.CSV.Marshal(point{})
+	assert.ErrorIs(t, err, render.ErrUnsupportedValue)
+
+	_, err = render.Protobuf.Marshal(point{})
+	assert.ErrorIs(t, err, render.ErrUnsupportedValue)
+}
@@ -0,0 +1,101 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	JSON       Renderer = NewRenderer("application/json", json.Marshal)
+	PrettyJSON Renderer = NewRenderer("application/json", marshalPrettyJSON)
+	MsgPack    Renderer = NewRenderer("application/msgpack", marshalMsgPack)
+	CSV        Renderer = NewRenderer("text/csv", marshalCSV)
+	Protobuf   Renderer = NewRenderer("application/x-protobuf", marshalProtobuf)
+)
+
+func init() {
+	// Raw json, like a graphql result or a webhook payload, goes
+	// out as the value it holds rather than as opaque bytes
+	msgpack.Register(json.RawMessage{}, func(enc *msgpack.Encoder, v reflect.Value) error {
+		raw := v.Bytes()
+		if len(raw) == 0 {
+			return enc.EncodeNil()
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return fmt.Errorf("failed to decode raw json: %w", err)
+		}
+		return enc.Encode(value)
+	}, nil)
+}
+
+type renderer struct {
+	contentType string
+	marshal     func(interface{}) ([]byte, error)
+}
+
+// Function to create a renderer from a marshal function
+func NewRenderer(contentType string, marshal func(interface{}) ([]byte, error)) Renderer {
+	return &renderer{
+		contentType: contentType,
+		marshal:     marshal,
+	}
+}
+
+func (rr *renderer) ContentType() string {
+	return rr.contentType
+}
+
+func (rr *renderer) Marshal(value interface{}) ([]byte, error) {
+	return rr.marshal(value)
+}
+
+func marshalPrettyJSON(value interface{}) ([]byte, error) {
+	return json.MarshalIndent(value, "", "  ")
+}
+
+// Fields are named by their json tags, so the
+// msgpack and json responses have the same shape
+func marshalMsgPack(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalCSV(value interface{}) ([]byte, error) {
+	marshaler, ok := value.(CSVMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%w: csv", ErrUnsupportedValue)
+	}
+	records, err := marshaler.MarshalCSV()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalProtobuf(value interface{}) ([]byte, error) {
+	switch message := value.(type) {
+	case proto.Message:
+		return proto.Marshal(message)
+	case ProtoMarshaler:
+		return message.MarshalProto()
+	default:
+		return nil, fmt.Errorf("%w: protobuf", ErrUnsupportedValue)
+	}
+}
@@ -0,0 +1,19 @@
+package weatherClient
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	API_KEY_HEADER string = "X-API-Key"
+)
+
+// Request editor that sends our api key
+// with every request, for when auth is on
+func WithApiKey(key string) RequestEditorFn {
+	return func(ctx context.Context, req *http.Request) error {
+		req.Header.Set(API_KEY_HEADER, key)
+		return nil
+	}
+}
@@ -0,0 +1,2393 @@
+// Package weatherClient provides primitives to interact with the openapi HTTP API.
+//
+// Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.3.0 DO NOT EDIT.
+package weatherClient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oapi-codegen/runtime"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+const (
+	ApiKeyScopes     = "ApiKey.Scopes"
+	BearerAuthScopes = "BearerAuth.Scopes"
+)
+
+// Defines values for ResponseFormat.
+const (
+	Csv      ResponseFormat = "csv"
+	Json     ResponseFormat = "json"
+	Msgpack  ResponseFormat = "msgpack"
+	Pretty   ResponseFormat = "pretty"
+	Protobuf ResponseFormat = "protobuf"
+)
+
+// Defines values for WeatherMetaSource.
+const (
+	Local    WeatherMetaSource = "local"
+	Redis    WeatherMetaSource = "redis"
+	Upstream WeatherMetaSource = "upstream"
+)
+
+// Defines values for ViewQuery.
+const (
+	ViewQueryFull    ViewQuery = "full"
+	ViewQuerySummary ViewQuery = "summary"
+)
+
+// Defines values for GetWeatherParamsView.
+const (
+	GetWeatherParamsViewFull    GetWeatherParamsView = "full"
+	GetWeatherParamsViewSummary GetWeatherParamsView = "summary"
+)
+
+// Defines values for GetCachedWeatherParamsView.
+const (
+	Full    GetCachedWeatherParamsView = "full"
+	Summary GetCachedWeatherParamsView = "summary"
+)
+
+// City Times ending in _local and _utc, day_length, is_day and until_sunset are worked out by the api and aren't kept in history snapshots
+type City struct {
+	Coord   *Coord  `json:"coord,omitempty"`
+	Country *string `json:"country,omitempty"`
+
+	// DayLength Seconds from sunrise to sunset
+	DayLength *int64 `json:"day_length,omitempty"`
+	Id        *int32 `json:"id,omitempty"`
+
+	// IsDay Whether the sun is up now, only with live=true
+	IsDay        *bool      `json:"is_day,omitempty"`
+	Name         *string    `json:"name,omitempty"`
+	Population   *int64     `json:"population,omitempty"`
+	Sunrise      *int64     `json:"sunrise,omitempty"`
+	SunriseLocal *time.Time `json:"sunrise_local,omitempty"`
+	SunriseUtc   *time.Time `json:"sunrise_utc,omitempty"`
+	Sunset       *int64     `json:"sunset,omitempty"`
+	SunsetLocal  *time.Time `json:"sunset_local,omitempty"`
+	SunsetUtc    *time.Time `json:"sunset_utc,omitempty"`
+
+	// TimeZone Zone the local times are in: the city's offset like UTC-07:00, or the tz asked for
+	TimeZone *string `json:"time_zone,omitempty"`
+
+	// Timezone Offset from UTC in seconds
+	Timezone *int32 `json:"timezone,omitempty"`
+
+	// UntilSunset Seconds from now until the next sunset, only with live=true
+	UntilSunset *int64 `json:"until_sunset,omitempty"`
+}
+
+// Clouds defines model for Clouds.
+type Clouds struct {
+	All *int32 `json:"all,omitempty"`
+}
+
+// Condition defines model for Condition.
+type Condition struct {
+	Description *string `json:"description,omitempty"`
+	Icon        *string `json:"icon,omitempty"`
+	Id          *int32  `json:"id,omitempty"`
+	Main        *string `json:"main,omitempty"`
+}
+
+// Coord defines model for Coord.
+type Coord struct {
+	Lat *float64 `json:"lat,omitempty"`
+	Lon *float64 `json:"lon,omitempty"`
+}
+
+// DailyTemperature defines model for DailyTemperature.
+type DailyTemperature struct {
+	Date    openapi_types.Date `json:"date"`
+	Samples int                `json:"samples"`
+	TempAvg float32            `json:"temp_avg"`
+	TempMax float32            `json:"temp_max"`
+	TempMin float32            `json:"temp_min"`
+}
+
+// Error defines model for Error.
+type Error struct {
+	Code      int     `json:"code"`
+	Message   string  `json:"message"`
+	RequestId *string `json:"request_id,omitempty"`
+}
+
+// ForecastEntry The nearest forecast when the snapshot was taken, in Celsius and meters per second
+type ForecastEntry struct {
+	Clouds      int32   `json:"clouds"`
+	Condition   string  `json:"condition"`
+	ConditionId int32   `json:"condition_id"`
+	Description string  `json:"description"`
+	FeelsLike   float32 `json:"feels_like"`
+	Humidity    int32   `json:"humidity"`
+	Icon        string  `json:"icon"`
+
+	// PrecipitationChance Chance of precipitation from 0 to 1
+	PrecipitationChance float32 `json:"precipitation_chance"`
+	Pressure            int32   `json:"pressure"`
+	Temp                float32 `json:"temp"`
+	TempMax             float32 `json:"temp_max"`
+	TempMin             float32 `json:"temp_min"`
+
+	// Time Unix time the forecast is for
+	Time       int64   `json:"time"`
+	Visibility int32   `json:"visibility"`
+	WindDeg    float32 `json:"wind_deg"`
+	WindGust   float32 `json:"wind_gust"`
+	WindSpeed  float32 `json:"wind_speed"`
+}
+
+// ForecastItem defines model for ForecastItem.
+type ForecastItem struct {
+	Clouds *Clouds `json:"clouds,omitempty"`
+
+	// Dt Unix time in seconds
+	Dt *int64 `json:"dt,omitempty"`
+
+	// DtLocal dt in the city's time zone, or the tz asked for
+	DtLocal *time.Time `json:"dt_local,omitempty"`
+	DtTxt   *string    `json:"dt_txt,omitempty"`
+	DtUtc   *time.Time `json:"dt_utc,omitempty"`
+
+	// IsDay Whether the sun is up, going by the city's sunrise and sunset times of day
+	IsDay *bool `json:"is_day,omitempty"`
+	Main  *Main `json:"main,omitempty"`
+
+	// Pop Chance of precipitation from 0 to 1
+	Pop        *float32     `json:"pop,omitempty"`
+	Sys        *Sys         `json:"sys,omitempty"`
+	Visibility *int32       `json:"visibility,omitempty"`
+	Weather    *[]Condition `json:"weather"`
+	Wind       *Wind        `json:"wind,omitempty"`
+}
+
+// GeoLocation defines model for GeoLocation.
+type GeoLocation struct {
+	// Country ISO 3166 country code
+	Country string `json:"country"`
+
+	// Id The coordinates to four decimal places. Pass it to the weather api as location.
+	Id   string  `json:"id"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+	Name string  `json:"name"`
+
+	// State Only for some countries, like the US
+	State *string `json:"state,omitempty"`
+}
+
+// Main defines model for Main.
+type Main struct {
+	FeelsLike *float32 `json:"feels_like,omitempty"`
+	GrndLevel *int32   `json:"grnd_level,omitempty"`
+	Humidity  *int32   `json:"humidity,omitempty"`
+	Pressure  *int32   `json:"pressure,omitempty"`
+	SeaLevel  *int32   `json:"sea_level,omitempty"`
+	Temp      *float32 `json:"temp,omitempty"`
+	TempKf    *float32 `json:"temp_kf,omitempty"`
+	TempMax   *float32 `json:"temp_max,omitempty"`
+	TempMin   *float32 `json:"temp_min,omitempty"`
+}
+
+// ResponseFormat defines model for ResponseFormat.
+type ResponseFormat string
+
+// SparseWeather The fields picked with fields=, shaped like WeatherResponse (or its envelope) with everything else left out
+type SparseWeather map[string]interface{}
+
+// SummaryForecast defines model for SummaryForecast.
+type SummaryForecast struct {
+	Condition string `json:"condition"`
+
+	// FeelsLike Celsius
+	FeelsLike float32 `json:"feels_like"`
+	IconUrl   *string `json:"icon_url,omitempty"`
+
+	// IsDay Whether the sun is up
+	IsDay *bool `json:"is_day,omitempty"`
+
+	// LocalTime The same time in the city's time zone, or the tz asked for
+	LocalTime           time.Time `json:"local_time"`
+	PrecipitationChance float32   `json:"precipitation_chance"`
+
+	// Temp Celsius
+	Temp float32   `json:"temp"`
+	Time time.Time `json:"time"`
+
+	// WindDeg Where the wind comes from, in degrees
+	WindDeg float32 `json:"wind_deg"`
+
+	// WindGust Meters per second
+	WindGust float32 `json:"wind_gust"`
+
+	// WindSpeed Meters per second
+	WindSpeed float32 `json:"wind_speed"`
+}
+
+// Sys defines model for Sys.
+type Sys struct {
+	Pod *string `json:"pod,omitempty"`
+}
+
+// WeatherEnvelope defines model for WeatherEnvelope.
+type WeatherEnvelope struct {
+	Data WeatherResponse `json:"data"`
+	Meta WeatherMeta     `json:"meta"`
+}
+
+// WeatherHistory defines model for WeatherHistory.
+type WeatherHistory struct {
+	City      string             `json:"city"`
+	Daily     []DailyTemperature `json:"daily"`
+	From      time.Time          `json:"from"`
+	Snapshots []WeatherSnapshot  `json:"snapshots"`
+	To        time.Time          `json:"to"`
+
+	// Total How many snapshots were in the range
+	Total int `json:"total"`
+
+	// Truncated More snapshots were in the range than were returned
+	Truncated bool `json:"truncated"`
+}
+
+// WeatherMeta defines model for WeatherMeta.
+type WeatherMeta struct {
+	ExpiresAt time.Time         `json:"expires_at"`
+	FetchedAt time.Time         `json:"fetched_at"`
+	Provider  string            `json:"provider"`
+	Source    WeatherMetaSource `json:"source"`
+	Stale     bool              `json:"stale"`
+}
+
+// WeatherMetaSource defines model for WeatherMeta.Source.
+type WeatherMetaSource string
+
+// WeatherResponse defines model for WeatherResponse.
+type WeatherResponse struct {
+	// City Times ending in _local and _utc, day_length, is_day and until_sunset are worked out by the api and aren't kept in history snapshots
+	City City            `json:"city"`
+	List *[]ForecastItem `json:"list"`
+}
+
+// WeatherResult The weather, its summary with view=summary, or the fields picked with fields=, in an envelope when envelope=true
+type WeatherResult struct {
+	union json.RawMessage
+}
+
+// WeatherSnapshot defines model for WeatherSnapshot.
+type WeatherSnapshot struct {
+	FetchedAt time.Time `json:"fetched_at"`
+
+	// Forecast The nearest forecast when the snapshot was taken, in Celsius and meters per second
+	Forecast ForecastEntry `json:"forecast"`
+	Provider string        `json:"provider"`
+
+	// Timezone The city's offset from UTC in seconds
+	Timezone int32 `json:"timezone"`
+}
+
+// WeatherSummary defines model for WeatherSummary.
+type WeatherSummary struct {
+	City      string            `json:"city"`
+	Country   string            `json:"country"`
+	Forecasts []SummaryForecast `json:"forecasts"`
+
+	// TimeZone Zone the local times are in: the city's offset like UTC-07:00, or the tz asked for
+	TimeZone string `json:"time_zone"`
+}
+
+// WeatherSummaryEnvelope defines model for WeatherSummaryEnvelope.
+type WeatherSummaryEnvelope struct {
+	Data WeatherSummary `json:"data"`
+	Meta WeatherMeta    `json:"meta"`
+}
+
+// Wind defines model for Wind.
+type Wind struct {
+	Deg   *float32 `json:"deg,omitempty"`
+	Gust  *float32 `json:"gust,omitempty"`
+	Speed *float32 `json:"speed,omitempty"`
+}
+
+// CityQuery defines model for CityQuery.
+type CityQuery = string
+
+// CountryQuery defines model for CountryQuery.
+type CountryQuery = string
+
+// EnvelopeQuery defines model for EnvelopeQuery.
+type EnvelopeQuery = bool
+
+// FieldsQuery defines model for FieldsQuery.
+type FieldsQuery = string
+
+// FormatQuery defines model for FormatQuery.
+type FormatQuery = ResponseFormat
+
+// IfModifiedSince defines model for IfModifiedSince.
+type IfModifiedSince = string
+
+// IfNoneMatch defines model for IfNoneMatch.
+type IfNoneMatch = string
+
+// IndexQuery defines model for IndexQuery.
+type IndexQuery = int
+
+// LiveQuery defines model for LiveQuery.
+type LiveQuery = bool
+
+// LocationQuery defines model for LocationQuery.
+type LocationQuery = string
+
+// StateQuery defines model for StateQuery.
+type StateQuery = string
+
+// TzQuery defines model for TzQuery.
+type TzQuery = string
+
+// ViewQuery defines model for ViewQuery.
+type ViewQuery string
+
+// WeatherCityQuery defines model for WeatherCityQuery.
+type WeatherCityQuery = string
+
+// BadRequest defines model for BadRequest.
+type BadRequest = Error
+
+// Forbidden defines model for Forbidden.
+type Forbidden = Error
+
+// GatewayTimeout defines model for GatewayTimeout.
+type GatewayTimeout = Error
+
+// NotAcceptable defines model for NotAcceptable.
+type NotAcceptable = Error
+
+// NotFound defines model for NotFound.
+type NotFound = Error
+
+// ServiceUnavailable defines model for ServiceUnavailable.
+type ServiceUnavailable = Error
+
+// TooManyRequests defines model for TooManyRequests.
+type TooManyRequests = Error
+
+// Unauthorized defines model for Unauthorized.
+type Unauthorized = Error
+
+// ReverseGeocodeParams defines parameters for ReverseGeocode.
+type ReverseGeocodeParams struct {
+	// Lat Latitude
+	Lat float64 `form:"lat" json:"lat"`
+
+	// Lon Longitude
+	Lon float64 `form:"lon" json:"lon"`
+
+	// Limit Most places to return
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Format Response format, taking precedence over the Accept header. csv is one row per forecast and protobuf is the weather.v1 WeatherResponse, or GetWeatherResponse with envelope=true.
+	Format *FormatQuery `form:"format,omitempty" json:"format,omitempty"`
+}
+
+// SearchLocationsParams defines parameters for SearchLocations.
+type SearchLocationsParams struct {
+	// Q Place name
+	Q string `form:"q" json:"q"`
+
+	// State State code to narrow the places matching a name, only for the US. Without a country, the country is taken to be us.
+	State *StateQuery `form:"state,omitempty" json:"state,omitempty"`
+
+	// Country ISO 3166 country code to narrow the places matching a name
+	Country *CountryQuery `form:"country,omitempty" json:"country,omitempty"`
+
+	// Limit Most places to return
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Format Response format, taking precedence over the Accept header. csv is one row per forecast and protobuf is the weather.v1 WeatherResponse, or GetWeatherResponse with envelope=true.
+	Format *FormatQuery `form:"format,omitempty" json:"format,omitempty"`
+}
+
+// GetWeatherParams defines parameters for GetWeather.
+type GetWeatherParams struct {
+	// City City name, case insensitive. Required unless location is given. A name can match several places, which index, state and country pick between.
+	City *WeatherCityQuery `form:"city,omitempty" json:"city,omitempty"`
+
+	// Location Location id from /api/geo/search or /api/geo/reverse, used instead of city to get the weather for exactly that place
+	Location *LocationQuery `form:"location,omitempty" json:"location,omitempty"`
+
+	// Index Which of the places matching city to use, in the order /api/geo/search returns them. Defaults to the first when state or country is given.
+	Index *IndexQuery `form:"index,omitempty" json:"index,omitempty"`
+
+	// State State code to narrow the places matching a name, only for the US. Without a country, the country is taken to be us.
+	State *StateQuery `form:"state,omitempty" json:"state,omitempty"`
+
+	// Country ISO 3166 country code to narrow the places matching a name
+	Country *CountryQuery `form:"country,omitempty" json:"country,omitempty"`
+
+	// Envelope Wrap the weather with where it came from and how fresh it is
+	Envelope *EnvelopeQuery `form:"envelope,omitempty" json:"envelope,omitempty"`
+
+	// Format Response format, taking precedence over the Accept header. csv is one row per forecast and protobuf is the weather.v1 WeatherResponse, or GetWeatherResponse with envelope=true.
+	Format *FormatQuery `form:"format,omitempty" json:"format,omitempty"`
+
+	// View full is the weather as the provider shapes it. summary is a flattened forecast list in Celsius with local times and icon urls.
+	View *GetWeatherParamsView `form:"view,omitempty" json:"view,omitempty"`
+
+	// Fields Comma separated fields to return from the full view, e.g. city.name,list.dt,list.main.temp. A dot selects a field of an object, or of every item in a list.
+	Fields *FieldsQuery `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// Tz IANA time zone to render local times in, e.g. America/Denver. Defaults to the city's own offset from UTC.
+	Tz *TzQuery `form:"tz,omitempty" json:"tz,omitempty"`
+
+	// Live Add the city's is_day and until_sunset as of the request. They change with the time, so the response is sent with Cache-Control: no-cache and no Last-Modified.
+	Live *LiveQuery `form:"live,omitempty" json:"live,omitempty"`
+
+	// IfNoneMatch ETags of the client's copies
+	IfNoneMatch *IfNoneMatch `json:"If-None-Match,omitempty"`
+
+	// IfModifiedSince When the client's copy was fetched
+	IfModifiedSince *IfModifiedSince `json:"If-Modified-Since,omitempty"`
+}
+
+// GetWeatherParamsView defines parameters for GetWeather.
+type GetWeatherParamsView string
+
+// GetCachedWeatherParams defines parameters for GetCachedWeather.
+type GetCachedWeatherParams struct {
+	// City City name, case insensitive. Required unless location is given. A name can match several places, which index, state and country pick between.
+	City *WeatherCityQuery `form:"city,omitempty" json:"city,omitempty"`
+
+	// Location Location id from /api/geo/search or /api/geo/reverse, used instead of city to get the weather for exactly that place
+	Location *LocationQuery `form:"location,omitempty" json:"location,omitempty"`
+
+	// Index Which of the places matching city to use, in the order /api/geo/search returns them. Defaults to the first when state or country is given.
+	Index *IndexQuery `form:"index,omitempty" json:"index,omitempty"`
+
+	// State State code to narrow the places matching a name, only for the US. Without a country, the country is taken to be us.
+	State *StateQuery `form:"state,omitempty" json:"state,omitempty"`
+
+	// Country ISO 3166 country code to narrow the places matching a name
+	Country *CountryQuery `form:"country,omitempty" json:"country,omitempty"`
+
+	// Envelope Wrap the weather with where it came from and how fresh it is
+	Envelope *EnvelopeQuery `form:"envelope,omitempty" json:"envelope,omitempty"`
+
+	// Format Response format, taking precedence over the Accept header. csv is one row per forecast and protobuf is the weather.v1 WeatherResponse, or GetWeatherResponse with envelope=true.
+	Format *FormatQuery `form:"format,omitempty" json:"format,omitempty"`
+
+	// View full is the weather as the provider shapes it. summary is a flattened forecast list in Celsius with local times and icon urls.
+	View *GetCachedWeatherParamsView `form:"view,omitempty" json:"view,omitempty"`
+
+	// Fields Comma separated fields to return from the full view, e.g. city.name,list.dt,list.main.temp. A dot selects a field of an object, or of every item in a list.
+	Fields *FieldsQuery `form:"fields,omitempty" json:"fields,omitempty"`
+
+	// Tz IANA time zone to render local times in, e.g. America/Denver. Defaults to the city's own offset from UTC.
+	Tz *TzQuery `form:"tz,omitempty" json:"tz,omitempty"`
+
+	// Live Add the city's is_day and until_sunset as of the request. They change with the time, so the response is sent with Cache-Control: no-cache and no Last-Modified.
+	Live *LiveQuery `form:"live,omitempty" json:"live,omitempty"`
+
+	// IfNoneMatch ETags of the client's copies
+	IfNoneMatch *IfNoneMatch `json:"If-None-Match,omitempty"`
+
+	// IfModifiedSince When the client's copy was fetched
+	IfModifiedSince *IfModifiedSince `json:"If-Modified-Since,omitempty"`
+}
+
+// GetCachedWeatherParamsView defines parameters for GetCachedWeather.
+type GetCachedWeatherParamsView string
+
+// GetWeatherHistoryParams defines parameters for GetWeatherHistory.
+type GetWeatherHistoryParams struct {
+	// City City name, case insensitive
+	City CityQuery `form:"city" json:"city"`
+
+	// From Start of the range, an RFC 3339 time or a date for the start of that day (UTC). Defaults to a day before to.
+	From *string `form:"from,omitempty" json:"from,omitempty"`
+
+	// To End of the range, an RFC 3339 time or a date for the end of that day (UTC). Defaults to now.
+	To *string `form:"to,omitempty" json:"to,omitempty"`
+
+	// Limit Most snapshots to return, newest first
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// StreamWeatherParams defines parameters for StreamWeather.
+type StreamWeatherParams struct {
+	// City City name, case insensitive
+	City CityQuery `form:"city" json:"city"`
+
+	// LastEventID Id of the last event received, from a previous stream
+	LastEventID *string `json:"Last-Event-ID,omitempty"`
+}
+
+// AsWeatherResponse returns the union data inside the WeatherResult as a WeatherResponse
+func (t WeatherResult) AsWeatherResponse() (WeatherResponse, error) {
+	var body WeatherResponse
+	err := json.Unmarshal(t.union, &body)
+	return body, err
+}
+
+// FromWeatherResponse overwrites any union data inside the WeatherResult as the provided WeatherResponse
+func (t *WeatherResult) FromWeatherResponse(v WeatherResponse) error {
+	b, err := json.Marshal(v)
+	t.union = b
+	return err
+}
+
+// MergeWeatherResponse performs a merge with any union data inside the WeatherResult, using the provided WeatherResponse
+func (t *WeatherResult) MergeWeatherResponse(v WeatherResponse) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	merged, err := runtime.JSONMerge(t.union, b)
+	t.union = merged
+	return err
+}
+
+// AsWeatherEnvelope returns the union data inside the WeatherResult as a WeatherEnvelope
+func (t WeatherResult) AsWeatherEnvelope() (WeatherEnvelope, error) {
+	var body WeatherEnvelope
+	err := json.Unmarshal(t.union, &body)
+	return body, err
+}
+
+// FromWeatherEnvelope overwrites any union data inside the WeatherResult as the provided WeatherEnvelope
+func (t *WeatherResult) FromWeatherEnvelope(v WeatherEnvelope) error {
+	b, err := json.Marshal(v)
+	t.union = b
+	return err
+}
+
+// MergeWeatherEnvelope performs a merge with any union data inside the WeatherResult, using the provided WeatherEnvelope
+func (t *WeatherResult) MergeWeatherEnvelope(v WeatherEnvelope) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	merged, err := runtime.JSONMerge(t.union, b)
+	t.union = merged
+	return err
+}
+
+// AsWeatherSummary returns the union data inside the WeatherResult as a WeatherSummary
+func (t WeatherResult) AsWeatherSummary() (WeatherSummary, error) {
+	var body WeatherSummary
+	err := json.Unmarshal(t.union, &body)
+	return body, err
+}
+
+// FromWeatherSummary overwrites any union data inside the WeatherResult as the provided WeatherSummary
+func (t *WeatherResult) FromWeatherSummary(v WeatherSummary) error {
+	b, err := json.Marshal(v)
+	t.union = b
+	return err
+}
+
+// MergeWeatherSummary performs a merge with any union data inside the WeatherResult, using the provided WeatherSummary
+func (t *WeatherResult) MergeWeatherSummary(v WeatherSummary) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	merged, err := runtime.JSONMerge(t.union, b)
+	t.union = merged
+	return err
+}
+
+// AsWeatherSummaryEnvelope returns the union data inside the WeatherResult as a WeatherSummaryEnvelope
+func (t WeatherResult) AsWeatherSummaryEnvelope() (WeatherSummaryEnvelope, error) {
+	var body WeatherSummaryEnvelope
+	err := json.Unmarshal(t.union, &body)
+	return body, err
+}
+
+// FromWeatherSummaryEnvelope overwrites any union data inside the WeatherResult as the provided WeatherSummaryEnvelope
+func (t *WeatherResult) FromWeatherSummaryEnvelope(v WeatherSummaryEnvelope) error {
+	b, err := json.Marshal(v)
+	t.union = b
+	return err
+}
+
+// MergeWeatherSummaryEnvelope performs a merge with any union data inside the WeatherResult, using the provided WeatherSummaryEnvelope
+func (t *WeatherResult) MergeWeatherSummaryEnvelope(v WeatherSummaryEnvelope) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	merged, err := runtime.JSONMerge(t.union, b)
+	t.union = merged
+	return err
+}
+
+// AsSparseWeather returns the union data inside the WeatherResult as a SparseWeather
+func (t WeatherResult) AsSparseWeather() (SparseWeather, error) {
+	var body SparseWeather
+	err := json.Unmarshal(t.union, &body)
+	return body, err
+}
+
+// FromSparseWeather overwrites any union data inside the WeatherResult as the provided SparseWeather
+func (t *WeatherResult) FromSparseWeather(v SparseWeather) error {
+	b, err := json.Marshal(v)
+	t.union = b
+	return err
+}
+
+// MergeSparseWeather performs a merge with any union data inside the WeatherResult, using the provided SparseWeather
+func (t *WeatherResult) MergeSparseWeather(v SparseWeather) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	merged, err := runtime.JSONMerge(t.union, b)
+	t.union = merged
+	return err
+}
+
+func (t WeatherResult) MarshalJSON() ([]byte, error) {
+	b, err := t.union.MarshalJSON()
+	return b, err
+}
+
+func (t *WeatherResult) UnmarshalJSON(b []byte) error {
+	err := t.union.UnmarshalJSON(b)
+	return err
+}
+
+// RequestEditorFn  is the function signature for the RequestEditor callback function
+type RequestEditorFn func(ctx context.Context, req *http.Request) error
+
+// Doer performs HTTP requests.
+//
+// The standard http.Client implements this interface.
+type HttpRequestDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client which conforms to the OpenAPI3 specification for this service.
+type Client struct {
+	// The endpoint of the server conforming to this interface, with scheme,
+	// https://api.deepmap.com for example. This can contain a path relative
+	// to the server, such as https://api.deepmap.com/dev-test, and all the
+	// paths in the swagger spec will be appended to the server.
+	Server string
+
+	// Doer for performing requests, typically a *http.Client with any
+	// customized settings, such as certificate chains.
+	Client HttpRequestDoer
+
+	// A list of callbacks for modifying requests which are generated before sending over
+	// the network.
+	RequestEditors []RequestEditorFn
+}
+
+// ClientOption allows setting custom parameters during construction
+type ClientOption func(*Client) error
+
+// Creates a new Client, with reasonable defaults
+func NewClient(server string, opts ...ClientOption) (*Client, error) {
+	// create a client with sane default values
+	client := Client{
+		Server: server,
+	}
+	// mutate client and add all optional params
+	for _, o := range opts {
+		if err := o(&client); err != nil {
+			return nil, err
+		}
+	}
+	// ensure the server URL always has a trailing slash
+	if !strings.HasSuffix(client.Server, "/") {
+		client.Server += "/"
+	}
+	// create httpClient, if not already present
+	if client.Client == nil {
+		client.Client = &http.Client{}
+	}
+	return &client, nil
+}
+
+// WithHTTPClient allows overriding the default Doer, which is
+// automatically created using http.Client. This is useful for tests.
+func WithHTTPClient(doer HttpRequestDoer) ClientOption {
+	return func(c *Client) error {
+		c.Client = doer
+		return nil
+	}
+}
+
+// WithRequestEditorFn allows setting up a callback function, which will be
+// called right before sending the request. This can be used to mutate the request.
+func WithRequestEditorFn(fn RequestEditorFn) ClientOption {
+	return func(c *Client) error {
+		c.RequestEditors = append(c.RequestEditors, fn)
+		return nil
+	}
+}
+
+// The interface specification for the client above.
+type ClientInterface interface {
+	// ReverseGeocode request
+	ReverseGeocode(ctx context.Context, params *ReverseGeocodeParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// SearchLocations request
+	SearchLocations(ctx context.Context, params *SearchLocationsParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetWeather request
+	GetWeather(ctx context.Context, params *GetWeatherParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetCachedWeather request
+	GetCachedWeather(ctx context.Context, params *GetCachedWeatherParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// GetWeatherHistory request
+	GetWeatherHistory(ctx context.Context, params *GetWeatherHistoryParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+
+	// StreamWeather request
+	StreamWeather(ctx context.Context, params *StreamWeatherParams, reqEditors ...RequestEditorFn) (*http.Response, error)
+}
+
+func (c *Client) ReverseGeocode(ctx context.Context, params *ReverseGeocodeParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewReverseGeocodeRequest(c.Server, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) SearchLocations(ctx context.Context, params *SearchLocationsParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewSearchLocationsRequest(c.Server, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetWeather(ctx context.Context, params *GetWeatherParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetWeatherRequest(c.Server, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetCachedWeather(ctx context.Context, params *GetCachedWeatherParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetCachedWeatherRequest(c.Server, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetWeatherHistory(ctx context.Context, params *GetWeatherHistoryParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewGetWeatherHistoryRequest(c.Server, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) StreamWeather(ctx context.Context, params *StreamWeatherParams, reqEditors ...RequestEditorFn) (*http.Response, error) {
+	req, err := NewStreamWeatherRequest(c.Server, params)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if err := c.applyEditors(ctx, req, reqEditors); err != nil {
+		return nil, err
+	}
+	return c.Client.Do(req)
+}
+
+// NewReverseGeocodeRequest generates requests for ReverseGeocode
+func NewReverseGeocodeRequest(server string, params *ReverseGeocodeParams) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/api/geo/reverse")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if queryFrag, err := runtime.StyleParamWithLocation("form", true, "lat", runtime.ParamLocationQuery, params.Lat); err != nil {
+			return nil, err
+		} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+			return nil, err
+		} else {
+			for k, v := range parsed {
+				for _, v2 := range v {
+					queryValues.Add(k, v2)
+				}
+			}
+		}
+
+		if queryFrag, err := runtime.StyleParamWithLocation("form", true, "lon", runtime.ParamLocationQuery, params.Lon); err != nil {
+			return nil, err
+		} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+			return nil, err
+		} else {
+			for k, v := range parsed {
+				for _, v2 := range v {
+					queryValues.Add(k, v2)
+				}
+			}
+		}
+
+		if params.Limit != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "limit", runtime.ParamLocationQuery, *params.Limit); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Format != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "format", runtime.ParamLocationQuery, *params.Format); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewSearchLocationsRequest generates requests for SearchLocations
+func NewSearchLocationsRequest(server string, params *SearchLocationsParams) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/api/geo/search")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if queryFrag, err := runtime.StyleParamWithLocation("form", true, "q", runtime.ParamLocationQuery, params.Q); err != nil {
+			return nil, err
+		} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+			return nil, err
+		} else {
+			for k, v := range parsed {
+				for _, v2 := range v {
+					queryValues.Add(k, v2)
+				}
+			}
+		}
+
+		if params.State != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "state", runtime.ParamLocationQuery, *params.State); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Country != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "country", runtime.ParamLocationQuery, *params.Country); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Limit != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "limit", runtime.ParamLocationQuery, *params.Limit); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Format != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "format", runtime.ParamLocationQuery, *params.Format); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewGetWeatherRequest generates requests for GetWeather
+func NewGetWeatherRequest(server string, params *GetWeatherParams) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/api/weather")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if params.City != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "city", runtime.ParamLocationQuery, *params.City); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Location != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "location", runtime.ParamLocationQuery, *params.Location); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Index != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "index", runtime.ParamLocationQuery, *params.Index); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.State != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "state", runtime.ParamLocationQuery, *params.State); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Country != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "country", runtime.ParamLocationQuery, *params.Country); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Envelope != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "envelope", runtime.ParamLocationQuery, *params.Envelope); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Format != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "format", runtime.ParamLocationQuery, *params.Format); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.View != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "view", runtime.ParamLocationQuery, *params.View); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Fields != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "fields", runtime.ParamLocationQuery, *params.Fields); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Tz != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "tz", runtime.ParamLocationQuery, *params.Tz); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Live != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "live", runtime.ParamLocationQuery, *params.Live); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+
+		if params.IfNoneMatch != nil {
+			var headerParam0 string
+
+			headerParam0, err = runtime.StyleParamWithLocation("simple", false, "If-None-Match", runtime.ParamLocationHeader, *params.IfNoneMatch)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Header.Set("If-None-Match", headerParam0)
+		}
+
+		if params.IfModifiedSince != nil {
+			var headerParam1 string
+
+			headerParam1, err = runtime.StyleParamWithLocation("simple", false, "If-Modified-Since", runtime.ParamLocationHeader, *params.IfModifiedSince)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Header.Set("If-Modified-Since", headerParam1)
+		}
+
+	}
+
+	return req, nil
+}
+
+// NewGetCachedWeatherRequest generates requests for GetCachedWeather
+func NewGetCachedWeatherRequest(server string, params *GetCachedWeatherParams) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/api/weather/cached")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if params.City != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "city", runtime.ParamLocationQuery, *params.City); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Location != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "location", runtime.ParamLocationQuery, *params.Location); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Index != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "index", runtime.ParamLocationQuery, *params.Index); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.State != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "state", runtime.ParamLocationQuery, *params.State); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Country != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "country", runtime.ParamLocationQuery, *params.Country); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Envelope != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "envelope", runtime.ParamLocationQuery, *params.Envelope); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Format != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "format", runtime.ParamLocationQuery, *params.Format); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.View != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "view", runtime.ParamLocationQuery, *params.View); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Fields != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "fields", runtime.ParamLocationQuery, *params.Fields); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Tz != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "tz", runtime.ParamLocationQuery, *params.Tz); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Live != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "live", runtime.ParamLocationQuery, *params.Live); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+
+		if params.IfNoneMatch != nil {
+			var headerParam0 string
+
+			headerParam0, err = runtime.StyleParamWithLocation("simple", false, "If-None-Match", runtime.ParamLocationHeader, *params.IfNoneMatch)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Header.Set("If-None-Match", headerParam0)
+		}
+
+		if params.IfModifiedSince != nil {
+			var headerParam1 string
+
+			headerParam1, err = runtime.StyleParamWithLocation("simple", false, "If-Modified-Since", runtime.ParamLocationHeader, *params.IfModifiedSince)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Header.Set("If-Modified-Since", headerParam1)
+		}
+
+	}
+
+	return req, nil
+}
+
+// NewGetWeatherHistoryRequest generates requests for GetWeatherHistory
+func NewGetWeatherHistoryRequest(server string, params *GetWeatherHistoryParams) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/api/weather/history")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if queryFrag, err := runtime.StyleParamWithLocation("form", true, "city", runtime.ParamLocationQuery, params.City); err != nil {
+			return nil, err
+		} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+			return nil, err
+		} else {
+			for k, v := range parsed {
+				for _, v2 := range v {
+					queryValues.Add(k, v2)
+				}
+			}
+		}
+
+		if params.From != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "from", runtime.ParamLocationQuery, *params.From); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.To != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "to", runtime.ParamLocationQuery, *params.To); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		if params.Limit != nil {
+
+			if queryFrag, err := runtime.StyleParamWithLocation("form", true, "limit", runtime.ParamLocationQuery, *params.Limit); err != nil {
+				return nil, err
+			} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+				return nil, err
+			} else {
+				for k, v := range parsed {
+					for _, v2 := range v {
+						queryValues.Add(k, v2)
+					}
+				}
+			}
+
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewStreamWeatherRequest generates requests for StreamWeather
+func NewStreamWeatherRequest(server string, params *StreamWeatherParams) (*http.Request, error) {
+	var err error
+
+	serverURL, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	operationPath := fmt.Sprintf("/api/weather/stream")
+	if operationPath[0] == '/' {
+		operationPath = "." + operationPath
+	}
+
+	queryURL, err := serverURL.Parse(operationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		queryValues := queryURL.Query()
+
+		if queryFrag, err := runtime.StyleParamWithLocation("form", true, "city", runtime.ParamLocationQuery, params.City); err != nil {
+			return nil, err
+		} else if parsed, err := url.ParseQuery(queryFrag); err != nil {
+			return nil, err
+		} else {
+			for k, v := range parsed {
+				for _, v2 := range v {
+					queryValues.Add(k, v2)
+				}
+			}
+		}
+
+		queryURL.RawQuery = queryValues.Encode()
+	}
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+
+		if params.LastEventID != nil {
+			var headerParam0 string
+
+			headerParam0, err = runtime.StyleParamWithLocation("simple", false, "Last-Event-ID", runtime.ParamLocationHeader, *params.LastEventID)
+			if err != nil {
+				return nil, err
+			}
+
+			req.Header.Set("Last-Event-ID", headerParam0)
+		}
+
+	}
+
+	return req, nil
+}
+
+func (c *Client) applyEditors(ctx context.Context, req *http.Request, additionalEditors []RequestEditorFn) error {
+	for _, r := range c.RequestEditors {
+		if err := r(ctx, req); err != nil {
+			return err
+		}
+	}
+	for _, r := range additionalEditors {
+		if err := r(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClientWithResponses builds on ClientInterface to offer response payloads
+type ClientWithResponses struct {
+	ClientInterface
+}
+
+// NewClientWithResponses creates a new ClientWithResponses, which wraps
+// Client with return type handling
+func NewClientWithResponses(server string, opts ...ClientOption) (*ClientWithResponses, error) {
+	client, err := NewClient(server, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientWithResponses{client}, nil
+}
+
+// WithBaseURL overrides the baseURL.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) error {
+		newBaseURL, err := url.Parse(baseURL)
+		if err != nil {
+			return err
+		}
+		c.Server = newBaseURL.String()
+		return nil
+	}
+}
+
+// ClientWithResponsesInterface is the interface specification for the client with responses above.
+type ClientWithResponsesInterface interface {
+	// ReverseGeocodeWithResponse request
+	ReverseGeocodeWithResponse(ctx context.Context, params *ReverseGeocodeParams, reqEditors ...RequestEditorFn) (*ReverseGeocodeResponse, error)
+
+	// SearchLocationsWithResponse request
+	SearchLocationsWithResponse(ctx context.Context, params *SearchLocationsParams, reqEditors ...RequestEditorFn) (*SearchLocationsResponse, error)
+
+	// GetWeatherWithResponse request
+	GetWeatherWithResponse(ctx context.Context, params *GetWeatherParams, reqEditors ...RequestEditorFn) (*GetWeatherResponse, error)
+
+	// GetCachedWeatherWithResponse request
+	GetCachedWeatherWithResponse(ctx context.Context, params *GetCachedWeatherParams, reqEditors ...RequestEditorFn) (*GetCachedWeatherResponse, error)
+
+	// GetWeatherHistoryWithResponse request
+	GetWeatherHistoryWithResponse(ctx context.Context, params *GetWeatherHistoryParams, reqEditors ...RequestEditorFn) (*GetWeatherHistoryResponse, error)
+
+	// StreamWeatherWithResponse request
+	StreamWeatherWithResponse(ctx context.Context, params *StreamWeatherParams, reqEditors ...RequestEditorFn) (*StreamWeatherResponse, error)
+}
+
+type ReverseGeocodeResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *[]GeoLocation
+	JSON400      *BadRequest
+	JSON401      *Unauthorized
+	JSON403      *Forbidden
+	JSON406      *NotAcceptable
+	JSON429      *TooManyRequests
+	JSON503      *ServiceUnavailable
+	JSON504      *GatewayTimeout
+}
+
+// Status returns HTTPResponse.Status
+func (r ReverseGeocodeResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r ReverseGeocodeResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type SearchLocationsResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *[]GeoLocation
+	JSON400      *BadRequest
+	JSON401      *Unauthorized
+	JSON403      *Forbidden
+	JSON406      *NotAcceptable
+	JSON429      *TooManyRequests
+	JSON503      *ServiceUnavailable
+	JSON504      *GatewayTimeout
+}
+
+// Status returns HTTPResponse.Status
+func (r SearchLocationsResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r SearchLocationsResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetWeatherResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *WeatherResult
+	JSON400      *BadRequest
+	JSON401      *Unauthorized
+	JSON403      *Forbidden
+	JSON404      *NotFound
+	JSON406      *NotAcceptable
+	JSON429      *TooManyRequests
+	JSON503      *ServiceUnavailable
+	JSON504      *GatewayTimeout
+}
+
+// Status returns HTTPResponse.Status
+func (r GetWeatherResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetWeatherResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetCachedWeatherResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *WeatherResult
+	JSON400      *BadRequest
+	JSON401      *Unauthorized
+	JSON403      *Forbidden
+	JSON404      *NotFound
+	JSON406      *NotAcceptable
+	JSON429      *TooManyRequests
+	JSON503      *ServiceUnavailable
+	JSON504      *GatewayTimeout
+}
+
+// Status returns HTTPResponse.Status
+func (r GetCachedWeatherResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetCachedWeatherResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type GetWeatherHistoryResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON200      *WeatherHistory
+	JSON400      *BadRequest
+	JSON401      *Unauthorized
+	JSON403      *Forbidden
+	JSON429      *TooManyRequests
+	JSON503      *ServiceUnavailable
+	JSON504      *GatewayTimeout
+}
+
+// Status returns HTTPResponse.Status
+func (r GetWeatherHistoryResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r GetWeatherHistoryResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+type StreamWeatherResponse struct {
+	Body         []byte
+	HTTPResponse *http.Response
+	JSON400      *BadRequest
+	JSON401      *Unauthorized
+	JSON403      *Forbidden
+	JSON429      *TooManyRequests
+	JSON503      *ServiceUnavailable
+}
+
+// Status returns HTTPResponse.Status
+func (r StreamWeatherResponse) Status() string {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.Status
+	}
+	return http.StatusText(0)
+}
+
+// StatusCode returns HTTPResponse.StatusCode
+func (r StreamWeatherResponse) StatusCode() int {
+	if r.HTTPResponse != nil {
+		return r.HTTPResponse.StatusCode
+	}
+	return 0
+}
+
+// ReverseGeocodeWithResponse request returning *ReverseGeocodeResponse
+func (c *ClientWithResponses) ReverseGeocodeWithResponse(ctx context.Context, params *ReverseGeocodeParams, reqEditors ...RequestEditorFn) (*ReverseGeocodeResponse, error) {
+	rsp, err := c.ReverseGeocode(ctx, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseReverseGeocodeResponse(rsp)
+}
+
+// SearchLocationsWithResponse request returning *SearchLocationsResponse
+func (c *ClientWithResponses) SearchLocationsWithResponse(ctx context.Context, params *SearchLocationsParams, reqEditors ...RequestEditorFn) (*SearchLocationsResponse, error) {
+	rsp, err := c.SearchLocations(ctx, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSearchLocationsResponse(rsp)
+}
+
+// GetWeatherWithResponse request returning *GetWeatherResponse
+func (c *ClientWithResponses) GetWeatherWithResponse(ctx context.Context, params *GetWeatherParams, reqEditors ...RequestEditorFn) (*GetWeatherResponse, error) {
+	rsp, err := c.GetWeather(ctx, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetWeatherResponse(rsp)
+}
+
+// GetCachedWeatherWithResponse request returning *GetCachedWeatherResponse
+func (c *ClientWithResponses) GetCachedWeatherWithResponse(ctx context.Context, params *GetCachedWeatherParams, reqEditors ...RequestEditorFn) (*GetCachedWeatherResponse, error) {
+	rsp, err := c.GetCachedWeather(ctx, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetCachedWeatherResponse(rsp)
+}
+
+// GetWeatherHistoryWithResponse request returning *GetWeatherHistoryResponse
+func (c *ClientWithResponses) GetWeatherHistoryWithResponse(ctx context.Context, params *GetWeatherHistoryParams, reqEditors ...RequestEditorFn) (*GetWeatherHistoryResponse, error) {
+	rsp, err := c.GetWeatherHistory(ctx, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGetWeatherHistoryResponse(rsp)
+}
+
+// StreamWeatherWithResponse request returning *StreamWeatherResponse
+func (c *ClientWithResponses) StreamWeatherWithResponse(ctx context.Context, params *StreamWeatherParams, reqEditors ...RequestEditorFn) (*StreamWeatherResponse, error) {
+	rsp, err := c.StreamWeather(ctx, params, reqEditors...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStreamWeatherResponse(rsp)
+}
+
+// ParseReverseGeocodeResponse parses an HTTP response from a ReverseGeocodeWithResponse call
+func ParseReverseGeocodeResponse(rsp *http.Response) (*ReverseGeocodeResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &ReverseGeocodeResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest []GeoLocation
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest BadRequest
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest Unauthorized
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 403:
+		var dest Forbidden
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON403 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 406:
+		var dest NotAcceptable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON406 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 429:
+		var dest TooManyRequests
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON429 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 503:
+		var dest ServiceUnavailable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON503 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 504:
+		var dest GatewayTimeout
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON504 = &dest
+
+	case rsp.StatusCode == 200:
+		// Content-type (application/msgpack) unsupported
+
+	}
+
+	return response, nil
+}
+
+// ParseSearchLocationsResponse parses an HTTP response from a SearchLocationsWithResponse call
+func ParseSearchLocationsResponse(rsp *http.Response) (*SearchLocationsResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &SearchLocationsResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest []GeoLocation
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest BadRequest
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest Unauthorized
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 403:
+		var dest Forbidden
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON403 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 406:
+		var dest NotAcceptable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON406 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 429:
+		var dest TooManyRequests
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON429 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 503:
+		var dest ServiceUnavailable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON503 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 504:
+		var dest GatewayTimeout
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON504 = &dest
+
+	case rsp.StatusCode == 200:
+		// Content-type (application/msgpack) unsupported
+
+	}
+
+	return response, nil
+}
+
+// ParseGetWeatherResponse parses an HTTP response from a GetWeatherWithResponse call
+func ParseGetWeatherResponse(rsp *http.Response) (*GetWeatherResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetWeatherResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest WeatherResult
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest BadRequest
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest Unauthorized
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 403:
+		var dest Forbidden
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON403 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest NotFound
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 406:
+		var dest NotAcceptable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON406 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 429:
+		var dest TooManyRequests
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON429 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 503:
+		var dest ServiceUnavailable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON503 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 504:
+		var dest GatewayTimeout
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON504 = &dest
+
+	case rsp.StatusCode == 200:
+		// Content-type (text/csv) unsupported
+
+	}
+
+	return response, nil
+}
+
+// ParseGetCachedWeatherResponse parses an HTTP response from a GetCachedWeatherWithResponse call
+func ParseGetCachedWeatherResponse(rsp *http.Response) (*GetCachedWeatherResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetCachedWeatherResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest WeatherResult
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest BadRequest
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest Unauthorized
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 403:
+		var dest Forbidden
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON403 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 404:
+		var dest NotFound
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON404 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 406:
+		var dest NotAcceptable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON406 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 429:
+		var dest TooManyRequests
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON429 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 503:
+		var dest ServiceUnavailable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON503 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 504:
+		var dest GatewayTimeout
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON504 = &dest
+
+	case rsp.StatusCode == 200:
+		// Content-type (text/csv) unsupported
+
+	}
+
+	return response, nil
+}
+
+// ParseGetWeatherHistoryResponse parses an HTTP response from a GetWeatherHistoryWithResponse call
+func ParseGetWeatherHistoryResponse(rsp *http.Response) (*GetWeatherHistoryResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &GetWeatherHistoryResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 200:
+		var dest WeatherHistory
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON200 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest BadRequest
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest Unauthorized
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 403:
+		var dest Forbidden
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON403 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 429:
+		var dest TooManyRequests
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON429 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 503:
+		var dest ServiceUnavailable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON503 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 504:
+		var dest GatewayTimeout
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON504 = &dest
+
+	}
+
+	return response, nil
+}
+
+// ParseStreamWeatherResponse parses an HTTP response from a StreamWeatherWithResponse call
+func ParseStreamWeatherResponse(rsp *http.Response) (*StreamWeatherResponse, error) {
+	bodyBytes, err := io.ReadAll(rsp.Body)
+	defer func() { _ = rsp.Body.Close() }()
+	if err != nil {
+		return nil, err
+	}
+
+	response := &StreamWeatherResponse{
+		Body:         bodyBytes,
+		HTTPResponse: rsp,
+	}
+
+	switch {
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 400:
+		var dest BadRequest
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON400 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 401:
+		var dest Unauthorized
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON401 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 403:
+		var dest Forbidden
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON403 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 429:
+		var dest TooManyRequests
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON429 = &dest
+
+	case strings.Contains(rsp.Header.Get("Content-Type"), "json") && rsp.StatusCode == 503:
+		var dest ServiceUnavailable
+		if err := json.Unmarshal(bodyBytes, &dest); err != nil {
+			return nil, err
+		}
+		response.JSON503 = &dest
+
+	}
+
+	return response, nil
+}
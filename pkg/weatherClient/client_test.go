@@ -0,0 +1,34 @@
+package weatherClient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bengimbel/go_redis_api/pkg/weatherClient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWeatherWithResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.EqualValues(t, "/api/weather", r.URL.Path)
+		assert.EqualValues(t, "chicago", r.URL.Query().Get("city"))
+		assert.EqualValues(t, "secret", r.Header.Get(weatherClient.API_KEY_HEADER))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"city":{"name":"chicago"},"list":[{"dt":123}]}`))
+	}))
+	defer server.Close()
+
+	client, err := weatherClient.NewClientWithResponses(server.URL, weatherClient.WithRequestEditorFn(weatherClient.WithApiKey("secret")))
+	assert.Nil(t, err)
+	city := "chicago"
+	resp, err := client.GetWeatherWithResponse(context.Background(), &weatherClient.GetWeatherParams{City: &city})
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode())
+
+	weather, err := resp.JSON200.AsWeatherResponse()
+	assert.Nil(t, err)
+	assert.EqualValues(t, "chicago", *weather.City.Name)
+	assert.EqualValues(t, int64(123), *(*weather.List)[0].Dt)
+}
@@ -0,0 +1,12 @@
+// Typed client for the weather api, generated from its OpenAPI
+// document so other services don't hand write requests.
+//
+//	client, err := weatherClient.NewClientWithResponses("http://localhost:3000",
+//		weatherClient.WithRequestEditorFn(weatherClient.WithApiKey(key)))
+//	city := "chicago"
+//	resp, err := client.GetWeatherWithResponse(ctx, &weatherClient.GetWeatherParams{City: &city})
+//
+// Run go generate after changing internal/openapi/openapi.json.
+package weatherClient
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.3.0 -config oapi-codegen.yaml ../../internal/openapi/openapi.json
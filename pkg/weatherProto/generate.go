@@ -0,0 +1,11 @@
+// Protobuf messages and the gRPC client and server for the
+// weather api, so other Go services can call it over gRPC.
+//
+//	conn, err := grpc.NewClient("localhost:9090", grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	client := weatherProto.NewWeatherServiceClient(conn)
+//	resp, err := client.GetWeather(ctx, &weatherProto.GetWeatherRequest{City: "chicago"})
+//
+// Run go generate after changing weather.proto.
+package weatherProto
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative weather.proto
@@ -0,0 +1,64 @@
+// Signing for the webhooks sent by weather alerts. Receivers
+// can import this to check a delivery came from us:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	err := webhookSignature.Verify(secret, r.Header.Get(webhookSignature.HEADER), body, 5*time.Minute)
+package webhookSignature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HEADER string = "X-Weather-Signature"
+)
+
+// Signature header for a webhook body, in the form t=<unix>,v1=<hex>.
+// v1 is the HMAC-SHA256, keyed by the rule's secret, of the
+// timestamp, a dot and the body.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + signature(secret, t, body)
+}
+
+// Check a signature header for a webhook body. It is rejected if
+// it is older than tolerance, so a captured delivery can't be replayed.
+func Verify(secret string, header string, body []byte, tolerance time.Duration) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || v1 == "" {
+		return errors.New("malformed webhook signature")
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return errors.New("webhook signature has expired")
+	}
+	if !hmac.Equal([]byte(v1), []byte(signature(secret, t, body))) {
+		return errors.New("webhook signature does not match")
+	}
+
+	return nil
+}
+
+func signature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,20 @@
+package webhookSignature_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bengimbel/go_redis_api/pkg/webhookSignature"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"delivery"}`)
+	header := webhookSignature.Sign("whsec_test", time.Now(), body)
+
+	assert.Nil(t, webhookSignature.Verify("whsec_test", header, body, time.Minute))
+	assert.NotNil(t, webhookSignature.Verify("whsec_other", header, body, time.Minute))
+	assert.NotNil(t, webhookSignature.Verify("whsec_test", header, []byte(`{"id":"other"}`), time.Minute))
+	assert.NotNil(t, webhookSignature.Verify("whsec_test", webhookSignature.Sign("whsec_test", time.Now().Add(-time.Hour), body), body, time.Minute))
+	assert.NotNil(t, webhookSignature.Verify("whsec_test", "garbage", body, time.Minute))
+}